@@ -10,11 +10,25 @@ if [ -f "$GPU_TRACKER_LOCK_FILE" ]; then
     rm -f "$GPU_TRACKER_LOCK_FILE"
 fi
 
-# Remove default CDI directory if present
-CDI_DIR="/etc/cdi"
-if [ -d "$CDI_DIR" ]; then
-    rm -rf "$CDI_DIR"
-fi
+# Remove the CDI specs generated by amd-ctk, listed in the manifest of
+# each CDI directory. Specs changed since, and the specs of other
+# vendors, are kept.
+for CDI_DIR in /etc/cdi /var/run/cdi; do
+    CDI_MANIFEST="$CDI_DIR/.amd-ctk.sha256"
+    if [ ! -f "$CDI_MANIFEST" ]; then
+        continue
+    fi
+    while read -r sum name; do
+        spec="$CDI_DIR/$name"
+        case "$name" in
+            ""|*/*) continue ;;
+        esac
+        if [ -f "$spec" ] && [ ! -L "$spec" ] && [ "$(sha256sum "$spec" | cut -d' ' -f1)" == "$sum" ]; then
+            rm -f "$spec"
+        fi
+    done < "$CDI_MANIFEST"
+    rm -f "$CDI_MANIFEST"
+done
 
 # Default Docker config file
 DOCKER_CONFIG="/etc/docker/daemon.json"
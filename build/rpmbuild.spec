@@ -25,21 +25,23 @@ echo "AMD GPU driver found."
 
 %post
 # Initialize GPU tracker after install
-/usr/bin/amd-ctk gpu-tracker init || true
+/usr/local/bin/amd-ctk gpu-tracker init || true
 
 %preun
 /bin/bash /usr/share/amd-container-toolkit/cleanup.sh
 
 %install
 base_dir=${CONTAINER_WORKDIR}
-install -D -m 0755 ${base_dir}/bin/rpmbuild/amd-ctk  %{buildroot}/usr/bin/amd-ctk
-install -D -m 0755 ${base_dir}/bin/rpmbuild/amd-container-runtime  %{buildroot}/usr/bin/amd-container-runtime
+install -D -m 0755 ${base_dir}/bin/rpmbuild/amd-ctk  %{buildroot}/usr/local/bin/amd-ctk
+install -D -m 0755 ${base_dir}/bin/rpmbuild/amd-container-runtime  %{buildroot}/usr/local/bin/amd-container-runtime
+install -D -m 0755 ${base_dir}/bin/rpmbuild/amd-container-runtime-hook  %{buildroot}/usr/local/bin/amd-container-runtime-hook
 install -D -m 0644 ${base_dir}/README.md %{buildroot}/usr/share/doc/my-binary-package/README.md
 install -D -m 0755 ${base_dir}/build/cleanup.sh %{buildroot}/usr/share/amd-container-toolkit/cleanup.sh
 
 %files
-/usr/bin/amd-ctk
-/usr/bin/amd-container-runtime
+/usr/local/bin/amd-ctk
+/usr/local/bin/amd-container-runtime
+/usr/local/bin/amd-container-runtime-hook
 /usr/share/doc/my-binary-package/README.md
 /usr/share/amd-container-toolkit/cleanup.sh
 
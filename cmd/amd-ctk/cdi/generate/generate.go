@@ -21,6 +21,7 @@ import (
 	"path/filepath"
 
 	"github.com/ROCm/container-toolkit/internal/cdi"
+	"github.com/ROCm/container-toolkit/internal/rocm"
 	"github.com/urfave/cli/v2"
 )
 
@@ -30,7 +31,8 @@ const (
 )
 
 type generateOptions struct {
-	output string
+	output       string
+	capabilities string
 }
 
 func AddNewCommand() *cli.Command {
@@ -55,6 +57,11 @@ func AddNewCommand() *cli.Command {
 			Value:       defaultOutputPath + "/" + defaultOutputFile,
 			Destination: &genOptions.output,
 		},
+		&cli.StringFlag{
+			Name:        "driver-capabilities",
+			Usage:       "comma separated ROCm user-space components to mount into containers (compute, utility, all)",
+			Destination: &genOptions.capabilities,
+		},
 	}
 
 	return &cdiGenerateCmd
@@ -66,6 +73,10 @@ func validateGenOptions(c *cli.Context, genOptions *generateOptions) error {
 		return fmt.Errorf("incorrect output file: %w", err)
 	}
 
+	if _, err := rocm.ParseCapabilities(genOptions.capabilities); err != nil {
+		return fmt.Errorf("incorrect driver capabilities: %w", err)
+	}
+
 	return nil
 }
 
@@ -75,6 +86,9 @@ func performAction(c *cli.Context, genOptions *generateOptions) error {
 		return fmt.Errorf("failed to create CDI handler: %w", err)
 	}
 
+	caps, _ := rocm.ParseCapabilities(genOptions.capabilities)
+	cdi.SetDriverCapabilities(caps)
+
 	// Generate CDI spec
 	err = cdi.GenerateSpec()
 	if err != nil {
@@ -18,9 +18,10 @@ package list
 
 import (
 	"fmt"
-	"strings"
+	"os"
 
 	"github.com/ROCm/container-toolkit/internal/amdgpu"
+	"github.com/ROCm/container-toolkit/internal/output"
 	"github.com/urfave/cli/v2"
 )
 
@@ -44,21 +45,5 @@ func performAction(c *cli.Context) error {
 		return fmt.Errorf("failed to list AMD devices: %v", err)
 	}
 
-	suffix := "devices"
-	if len(devs) == 1 {
-		suffix = "device"
-	}
-	fmt.Printf("Found %v AMD GPU %s\n", len(devs), suffix)
-	for cnt, dev := range devs {
-		if cnt == 0 {
-			fmt.Printf("amd.com/gpu=all\n")
-		}
-		fmt.Printf("amd.com/gpu=%v\n", cnt)
-		for _, dd := range dev.DrmDevices {
-			if !strings.HasPrefix(dd, "/dev/dri/card") {
-				fmt.Printf("  %s\n", dd)
-			}
-		}
-	}
-	return nil
+	return output.PrintCDIDevices(os.Stdout, c.String("output"), output.NewCDIDeviceList(devs))
 }
@@ -0,0 +1,42 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package config
+
+import (
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/config/get"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/config/initialize"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/config/set"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/config/show"
+	"github.com/urfave/cli/v2"
+)
+
+func AddNewCommand() *cli.Command {
+	configCmd := cli.Command{
+		Name:      "config",
+		Usage:     "AMD Container Toolkit config file related commands",
+		UsageText: "amd-ctk config [command] [options]",
+	}
+
+	configCmd.Subcommands = []*cli.Command{
+		initialize.AddNewCommand(),
+		show.AddNewCommand(),
+		get.AddNewCommand(),
+		set.AddNewCommand(),
+	}
+
+	return &configCmd
+}
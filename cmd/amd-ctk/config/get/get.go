@@ -0,0 +1,64 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package get
+
+import (
+	"fmt"
+
+	"github.com/ROCm/container-toolkit/internal/config"
+	"github.com/urfave/cli/v2"
+)
+
+func AddNewCommand() *cli.Command {
+	// Add the config get command
+	configGetCmd := cli.Command{
+		Name:      "get",
+		Usage:     "Show the effective value of a config key",
+		UsageText: "amd-ctk config get <key>",
+		Before: func(c *cli.Context) error {
+			return validateGenOptions(c)
+		},
+		Action: func(c *cli.Context) error {
+			return performAction(c)
+		},
+	}
+
+	return &configGetCmd
+}
+
+func validateGenOptions(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("Error: Expected one config key. Valid keys: %v", config.Keys())
+	}
+
+	return nil
+}
+
+func performAction(c *cli.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	value, err := cfg.Get(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(value)
+	return nil
+}
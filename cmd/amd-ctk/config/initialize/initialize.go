@@ -0,0 +1,67 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package initialize
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ROCm/container-toolkit/internal/config"
+	"github.com/urfave/cli/v2"
+)
+
+type initOptions struct {
+	force bool
+}
+
+func AddNewCommand() *cli.Command {
+	// Add the config init command
+	initOpts := initOptions{}
+	configInitCmd := cli.Command{
+		Name:      "init",
+		Usage:     "Write the default config file",
+		UsageText: "amd-ctk config init [options]",
+		Action: func(c *cli.Context) error {
+			return performAction(c, &initOpts)
+		},
+	}
+
+	configInitCmd.Flags = []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "force",
+			Usage:       "Overwrite the config file if it already exists",
+			Destination: &initOpts.force,
+		},
+	}
+
+	return &configInitCmd
+}
+
+func performAction(c *cli.Context, initOpts *initOptions) error {
+	path := config.Path()
+
+	if _, err := os.Stat(path); err == nil && !initOpts.force {
+		return fmt.Errorf("config file %v already exists, use --force to overwrite it", path)
+	}
+
+	if err := config.Default().Save(path); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	fmt.Printf("Generated config file: %v\n", path)
+	return nil
+}
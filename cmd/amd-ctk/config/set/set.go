@@ -0,0 +1,79 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package set
+
+import (
+	"fmt"
+
+	"github.com/ROCm/container-toolkit/internal/config"
+	"github.com/urfave/cli/v2"
+)
+
+func AddNewCommand() *cli.Command {
+	// Add the config set command
+	configSetCmd := cli.Command{
+		Name:  "set",
+		Usage: "Set the value of a config key in the config file",
+		UsageText: `amd-ctk config set <key> <value>
+
+	Lists are given as comma separated values.
+
+	Examples:
+		amd-ctk config set amd-ctk.path /usr/bin/amd-ctk
+		amd-ctk config set runtime.runtimes crun,runc`,
+		Before: func(c *cli.Context) error {
+			return validateGenOptions(c)
+		},
+		Action: func(c *cli.Context) error {
+			return performAction(c)
+		},
+	}
+
+	return &configSetCmd
+}
+
+func validateGenOptions(c *cli.Context) error {
+	if c.Args().Len() != 2 {
+		return fmt.Errorf("Error: Expected a config key and a value. Valid keys: %v", config.Keys())
+	}
+
+	return nil
+}
+
+func performAction(c *cli.Context) error {
+	path := config.Path()
+	key := c.Args().Get(0)
+
+	// Environment overrides are not applied so that they are not
+	// persisted in the file
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if err := cfg.Set(key, c.Args().Get(1)); err != nil {
+		return err
+	}
+
+	if err := cfg.Save(path); err != nil {
+		return fmt.Errorf("failed to update config file: %w", err)
+	}
+
+	value, _ := cfg.Get(key)
+	fmt.Printf("Set %v to %v in %v\n", key, value, path)
+	return nil
+}
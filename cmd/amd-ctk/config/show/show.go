@@ -0,0 +1,53 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package show
+
+import (
+	"fmt"
+
+	"github.com/ROCm/container-toolkit/internal/config"
+	"github.com/urfave/cli/v2"
+)
+
+func AddNewCommand() *cli.Command {
+	// Add the config show command
+	configShowCmd := cli.Command{
+		Name:      "show",
+		Usage:     "Show the effective configuration, including environment overrides",
+		UsageText: "amd-ctk config show [options]",
+		Action: func(c *cli.Context) error {
+			return performAction(c)
+		},
+	}
+
+	return &configShowCmd
+}
+
+func performAction(c *cli.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	data, err := cfg.Format()
+	if err != nil {
+		return fmt.Errorf("failed to format config: %w", err)
+	}
+
+	fmt.Print(data)
+	return nil
+}
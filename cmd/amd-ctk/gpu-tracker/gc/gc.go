@@ -0,0 +1,94 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package gc
+
+import (
+	"fmt"
+	"os/user"
+	"strings"
+
+	"github.com/ROCm/container-toolkit/internal/gpu-tracker"
+	"github.com/urfave/cli/v2"
+)
+
+type gcOptions struct {
+	releaseUnknown bool
+}
+
+func AddNewCommand() *cli.Command {
+	gcOpts := gcOptions{}
+
+	// Add the gpu-tracker gc command
+	gpuTrackerGCCmd := cli.Command{
+		Name:  "gc",
+		Usage: "Release the GPUs reserved for containers that no longer exist",
+		UsageText: `amd-ctk gpu-tracker gc [options]
+
+	A container no longer exists when none of the state directories of the
+	low-level runtimes, set by gpu-tracker.runtime-roots in the config file,
+	has a directory for it and its bundle directory is gone. Containers that
+	are not found in the state directories but whose bundle is unknown or
+	still exists are kept, unless --release-unknown is set.`,
+		Before: func(c *cli.Context) error {
+			return validateGenOptions(c)
+		},
+		Action: func(c *cli.Context) error {
+			return performAction(c, &gcOpts)
+		},
+	}
+
+	gpuTrackerGCCmd.Flags = []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "release-unknown",
+			Usage:       "also release the GPUs of the containers not found in the state directories of the low-level runtimes",
+			Destination: &gcOpts.releaseUnknown,
+		},
+	}
+
+	return &gpuTrackerGCCmd
+}
+
+func validateGenOptions(c *cli.Context) error {
+	curUser, err := user.Current()
+	if err != nil || curUser.Uid != "0" {
+		return fmt.Errorf("Permission denied: Not running as root")
+	}
+
+	return nil
+}
+
+func performAction(c *cli.Context, gcOpts *gcOptions) error {
+	gpuTracker, err := gpuTracker.New()
+	if err != nil {
+		return fmt.Errorf("failed to create GPU Tracker: %w", err)
+	}
+
+	released, unknown, err := gpuTracker.Reconcile(gcOpts.releaseUnknown)
+	if err != nil {
+		return fmt.Errorf("failed to garbage collect GPU Tracker reservations: %w", err)
+	}
+
+	if len(released) == 0 {
+		fmt.Println("No reservations of removed containers found")
+	} else {
+		fmt.Printf("Released GPUs of containers that no longer exist: %v\n", strings.Join(released, ", "))
+	}
+	if len(unknown) > 0 && !gcOpts.releaseUnknown {
+		fmt.Printf("Kept GPUs of containers not found in the runtime roots: %v, use --release-unknown to release them\n", strings.Join(unknown, ", "))
+	}
+	return nil
+}
@@ -19,11 +19,15 @@ package gpuTracker
 import (
 	"fmt"
 	"os/user"
+	"strconv"
+	"strings"
 
 	"github.com/ROCm/container-toolkit/cmd/amd-ctk/gpu-tracker/disable"
 	"github.com/ROCm/container-toolkit/cmd/amd-ctk/gpu-tracker/enable"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/gpu-tracker/gc"
 	"github.com/ROCm/container-toolkit/cmd/amd-ctk/gpu-tracker/initialize"
 	"github.com/ROCm/container-toolkit/cmd/amd-ctk/gpu-tracker/release"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/gpu-tracker/renew"
 	"github.com/ROCm/container-toolkit/cmd/amd-ctk/gpu-tracker/reset"
 	"github.com/ROCm/container-toolkit/cmd/amd-ctk/gpu-tracker/status"
 	gpuTrackerLib "github.com/ROCm/container-toolkit/internal/gpu-tracker"
@@ -38,11 +42,13 @@ func AddNewCommand() *cli.Command {
 
 	Arguments:
 		gpu-ids        Comma-separated list of GPU IDs (comma separated list, range operator, all)
-		accessibility  Must be either 'exclusive' or 'shared'
+		accessibility  Must be either 'exclusive', 'shared' or 'shared:N' to share
+		               the GPUs with at most N containers
 
 	Examples:
 		amd-ctk gpu-tracker 0,1,2 exclusive
 		amd-ctk gpu-tracker 0,1-2 shared
+		amd-ctk gpu-tracker 0-3 shared:4
 		amd-ctk gpu-tracker all shared
 
 OR
@@ -59,9 +65,11 @@ amd-ctk gpu-tracker [command] [options]`,
 	gpuTrackerCmd.Subcommands = []*cli.Command{
 		disable.AddNewCommand(),
 		enable.AddNewCommand(),
+		gc.AddNewCommand(),
 		initialize.AddNewCommand(),
 		reset.AddNewCommand(),
 		release.AddNewCommand(),
+		renew.AddNewCommand(),
 		status.AddNewCommand(),
 	}
 
@@ -104,8 +112,8 @@ func performAction(c *cli.Context) error {
 	}
 
 	var res *gpuTrackerLib.AccessibilityResult
-	switch operation {
-	case "exclusive":
+	switch {
+	case operation == "exclusive":
 		res, err = gpuTracker.MakeGPUsExclusive(gpuIDs)
 		if err != nil {
 			return fmt.Errorf("failed to make GPUs %s exclusive: %w", gpuIDs, err)
@@ -116,7 +124,7 @@ func performAction(c *cli.Context) error {
 		if len(res.NotChanged) > 0 {
 			fmt.Printf("GPUs %v have not been made exclusive because more than one container is currently using it\n", res.NotChanged)
 		}
-	case "shared":
+	case operation == "shared":
 		res, err = gpuTracker.MakeGPUsShared(gpuIDs)
 		if err != nil {
 			return fmt.Errorf("failed to make GPUs %s shared: %w", gpuIDs, err)
@@ -124,8 +132,23 @@ func performAction(c *cli.Context) error {
 		if len(res.Changed) > 0 {
 			fmt.Printf("GPUs %v have been made shared\n", res.Changed)
 		}
+	case strings.HasPrefix(operation, "shared:"):
+		maxContainers, convErr := strconv.Atoi(strings.TrimPrefix(operation, "shared:"))
+		if convErr != nil || maxContainers < 1 {
+			return cli.Exit("Error: Invalid operation. N of 'shared:N' must be a positive number", 1)
+		}
+		res, err = gpuTracker.MakeGPUsSharedLimited(gpuIDs, maxContainers)
+		if err != nil {
+			return fmt.Errorf("failed to make GPUs %s shared with at most %d containers: %w", gpuIDs, maxContainers, err)
+		}
+		if len(res.Changed) > 0 {
+			fmt.Printf("GPUs %v have been made %s\n", res.Changed, res.Accessibility)
+		}
+		if len(res.NotChanged) > 0 {
+			fmt.Printf("GPUs %v have not been made %s because more than %d containers are currently using them\n", res.NotChanged, res.Accessibility, maxContainers)
+		}
 	default:
-		return cli.Exit("Error: Invalid operation. Must be 'exclusive' or 'shared'", 1)
+		return cli.Exit("Error: Invalid operation. Must be 'exclusive', 'shared' or 'shared:N'", 1)
 	}
 
 	if len(res.InvalidRanges) > 0 {
@@ -18,10 +18,11 @@ package status
 
 import (
 	"fmt"
+	"os"
 	"os/user"
-	"strings"
 
 	gpuTracker "github.com/ROCm/container-toolkit/internal/gpu-tracker"
+	"github.com/ROCm/container-toolkit/internal/output"
 	"github.com/urfave/cli/v2"
 )
 
@@ -61,32 +62,20 @@ func performAction(c *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to check GPU Tracker status: %w", err)
 	}
-	if !enabled {
-		fmt.Println("GPU Tracker is disabled")
-		return nil
-	}
 
-	entries, err := tracker.ShowStatus()
-	if err != nil {
-		return fmt.Errorf("failed to show GPU Tracker status: %w", err)
-	}
+	var entries []gpuTracker.GPUStatusEntry
+	var queue []gpuTracker.QueueEntry
+	if enabled {
+		entries, err = tracker.ShowStatus()
+		if err != nil {
+			return fmt.Errorf("failed to show GPU Tracker status: %w", err)
+		}
 
-	fmt.Println(strings.Repeat("-", 120))
-	fmt.Printf("%-10s%-25s%-20s%-65s\n", "GPU Id", "UUID", "Accessibility", "Container Ids")
-	fmt.Println(strings.Repeat("-", 120))
-	for _, entry := range entries {
-		if len(entry.ContainerIds) > 0 {
-			for idx, id := range entry.ContainerIds {
-				if idx == 0 {
-					fmt.Printf("%-10v%-25v%-20v%-65v\n", entry.GPUId, entry.UUID, entry.Accessibility, id)
-				} else {
-					fmt.Printf("%-10v%-25v%-20v%-65v\n", "", "", "", id)
-				}
-			}
-		} else {
-			fmt.Printf("%-10v%-25v%-20v%-65v\n", entry.GPUId, entry.UUID, entry.Accessibility, "-")
+		queue, err = tracker.ShowQueue()
+		if err != nil {
+			return fmt.Errorf("failed to show GPU Tracker queue: %w", err)
 		}
 	}
 
-	return nil
+	return output.PrintGPUTrackerStatus(os.Stdout, c.String("output"), enabled, entries, queue)
 }
@@ -18,9 +18,10 @@ package list
 
 import (
 	"fmt"
-	"strings"
+	"os"
 
 	"github.com/ROCm/container-toolkit/internal/amdgpu"
+	"github.com/ROCm/container-toolkit/internal/output"
 	"github.com/urfave/cli/v2"
 )
 
@@ -28,7 +29,7 @@ func AddNewCommand() *cli.Command {
 	gpuListCmd := cli.Command{
 		Name:      "list",
 		Usage:     "List AMD GPUs with their UUIDs",
-		UsageText: "amd-ctk gpu list",
+		UsageText: "amd-ctk [--output FORMAT] gpu list",
 		Action: func(c *cli.Context) error {
 			return performAction(c)
 		},
@@ -38,52 +39,10 @@ func AddNewCommand() *cli.Command {
 }
 
 func performAction(c *cli.Context) error {
-	devs, err := amdgpu.GetAMDGPUs()
+	gpus, err := amdgpu.GetGPUInventory()
 	if err != nil {
 		return fmt.Errorf("failed to list AMD devices: %v", err)
 	}
 
-	uuidToGPUIdMap, err := amdgpu.GetUniqueIdToDeviceIndexMap()
-	if err != nil {
-		uuidToGPUIdMap = make(map[string][]int)
-	}
-
-	gpuIdToUUIDMap := make(map[int]string)
-	for uuid, gpuIds := range uuidToGPUIdMap {
-		if strings.HasPrefix(uuid, "0x") || strings.HasPrefix(uuid, "0X") {
-			uuid = uuid[2:]
-		}
-		uuid = "0x" + strings.ToUpper(uuid)
-		for _, gpuId := range gpuIds {
-			gpuIdToUUIDMap[gpuId] = uuid
-		}
-	}
-
-	suffix := "devices"
-	if len(devs) == 1 {
-		suffix = "device"
-	}
-	fmt.Printf("Found %v AMD GPU %s\n", len(devs), suffix)
-
-	fmt.Println(strings.Repeat("-", 75))
-	fmt.Printf("%-10s%-25s%-40s\n", "GPU Id", "UUID", "DRM Devices")
-	fmt.Println(strings.Repeat("-", 75))
-	for idx, dev := range devs {
-		uuid := gpuIdToUUIDMap[idx]
-		if uuid == "" {
-			uuid = "N/A"
-		}
-
-		var renderDevs []string
-		for _, dd := range dev.DrmDevices {
-			if !strings.HasPrefix(dd, "/dev/dri/card") {
-				renderDevs = append(renderDevs, dd)
-			}
-		}
-
-		drmStr := strings.Join(renderDevs, ", ")
-		fmt.Printf("%-10v%-25s%-40s\n", idx, uuid, drmStr)
-	}
-
-	return nil
+	return output.PrintGPUList(os.Stdout, c.String("output"), gpus)
 }
@@ -20,11 +20,15 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/ROCm/container-toolkit/cmd/amd-ctk/cdi"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/config"
 	"github.com/ROCm/container-toolkit/cmd/amd-ctk/gpu"
 	gpuTracker "github.com/ROCm/container-toolkit/cmd/amd-ctk/gpu-tracker"
 	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime"
+	configLib "github.com/ROCm/container-toolkit/internal/config"
+	"github.com/ROCm/container-toolkit/internal/output"
 	"github.com/urfave/cli/v2"
 )
 
@@ -35,7 +39,9 @@ var (
 )
 
 type options struct {
-	debug bool
+	debug  bool
+	config string
+	output string
 }
 
 func showVersion() *cli.Command {
@@ -68,6 +74,18 @@ func main() {
 				Usage:       "Enable debug output",
 				Destination: &opts.debug,
 			},
+			&cli.StringFlag{
+				Name:        "config",
+				Usage:       fmt.Sprintf("Path to the config file (default: \"%v\")", configLib.DEFAULT_CONFIG_PATH),
+				Destination: &opts.config,
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       fmt.Sprintf("Output format of the list and status commands, one of %s", strings.Join(output.Formats(), ", ")),
+				Value:       output.FORMAT_TABLE,
+				Destination: &opts.output,
+			},
 		},
 		Before: func(c *cli.Context) error {
 			level := slog.LevelInfo
@@ -78,7 +96,10 @@ func main() {
 				Level: level,
 			})
 			slog.SetDefault(slog.New(handler))
-			return nil
+			if opts.config != "" {
+				configLib.SetPath(opts.config)
+			}
+			return output.ValidateFormat(opts.output)
 		},
 	}
 
@@ -89,6 +110,7 @@ func main() {
 		cdi.AddNewCommand(),
 		gpu.AddNewCommand(),
 		gpuTracker.AddNewCommand(),
+		config.AddNewCommand(),
 	}
 
 	err := amdCtkCli.Run(os.Args)
@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -38,6 +41,9 @@ func setup(t *testing.T) {
 	if _, err := os.Stat(cliPath); os.IsNotExist(err) {
 		t.Fatalf("amd-ctk is not built, please run 'make container-toolkit-ctk'")
 	}
+
+	// Keep the backups of the configuration files out of the host
+	t.Setenv("AMD_CTK_AMD_CTK_BACKUP_DIR", t.TempDir())
 }
 
 func verifyConfigFile(t *testing.T, isDefault bool, isEmpty bool) {
@@ -105,6 +111,12 @@ func Assert(t *testing.T, b bool, errString string) {
 func cleanUp() {
 	fmt.Printf("Deleting file: %v\n", configFile)
 	os.Remove(configFile)
+
+	// ledger and backups written by amd-ctk runtime configure
+	files, _ := filepath.Glob(configFile + ".amd-ctk*")
+	for _, f := range files {
+		os.Remove(f)
+	}
 }
 
 func TestConfigureRunTimeAddRemove(t *testing.T) {
@@ -201,3 +213,246 @@ func TestConfigureRuntimeMultiFlags(t *testing.T) {
 	Assert(t, strings.TrimSpace(out) == setUnsetDefErrMsg, fmt.Sprintf("stdout: %v should have been '%v'", out, setUnsetDefErrMsg))
 	cleanUp()
 }
+
+func TestConfigureRuntimeDryRun(t *testing.T) {
+	setup(t)
+	cfgPathArg := "--config-path=" + configFile
+	original := "{\n  \"data-root\": \"/var/lib/docker\"\n}\n"
+	Assert(t, os.WriteFile(configFile, []byte(original), 0644) == nil, "failed to write the config file")
+
+	out, outErr, err := runCLI("runtime", "configure", "--runtime=docker", cfgPathArg, "--dry-run")
+	Assert(t, outErr == "" && err == nil, fmt.Sprintf("amd-ctk runtime configure --dry-run failed: %v, %v", outErr, err))
+	Assert(t, strings.Contains(out, "--- "+configFile+"\n+++ "+configFile+"\n"), fmt.Sprintf("missing diff header in %v", out))
+	Assert(t, strings.Contains(out, "-  \"data-root\": \"/var/lib/docker\"\n+  \"data-root\": \"/var/lib/docker\",\n"),
+		fmt.Sprintf("unexpected diff %v", out))
+	Assert(t, strings.Contains(out, "+      \"path\": \"amd-container-runtime\"\n"), fmt.Sprintf("unexpected diff %v", out))
+
+	data, err := os.ReadFile(configFile)
+	Assert(t, err == nil && string(data) == original, fmt.Sprintf("the config file was changed by --dry-run: %s", data))
+
+	out, _, err = runCLI("runtime", "configure", "--runtime=docker", cfgPathArg, "--remove", "--dry-run")
+	Assert(t, err == nil && strings.Contains(out, "No changes to the config file"), fmt.Sprintf("unexpected output %v", out))
+	cleanUp()
+}
+
+func TestConfigureRuntimeRestore(t *testing.T) {
+	setup(t)
+	cleanUp()
+	cfgPathArg := "--config-path=" + configFile
+	original := "{\n  \"data-root\": \"/var/lib/docker\"\n}\n"
+	Assert(t, os.WriteFile(configFile, []byte(original), 0600) == nil, "failed to write the config file")
+
+	_, outErr, err := runCLI("runtime", "configure", "--runtime=docker", cfgPathArg, "--set-as-default")
+	Assert(t, outErr == "" && err == nil, fmt.Sprintf("amd-ctk runtime configure failed: %v, %v", outErr, err))
+	verifyConfigFile(t, true, false)
+
+	fi, err := os.Stat(configFile)
+	Assert(t, err == nil && fi.Mode().Perm() == 0600, fmt.Sprintf("the mode of the config file is not kept: %v, %v", fi, err))
+
+	out, outErr, err := runCLI("runtime", "configure", "--runtime=docker", cfgPathArg, "--restore")
+	Assert(t, outErr == "" && err == nil, fmt.Sprintf("amd-ctk runtime configure --restore failed: %v, %v", outErr, err))
+	Assert(t, strings.Contains(out, "Restored the config file"), fmt.Sprintf("unexpected output %v", out))
+
+	data, err := os.ReadFile(configFile)
+	Assert(t, err == nil && string(data) == original, fmt.Sprintf("the config file is not restored: %s", data))
+	_, err = os.Stat(configFile + ".amd-ctk.json")
+	Assert(t, os.IsNotExist(err), "the ledger is not rolled back")
+
+	out, _, err = runCLI("runtime", "configure", "--runtime=docker", cfgPathArg, "--restore", "--remove")
+	Assert(t, err != nil, "err shouldn't be nil")
+	Assert(t, strings.TrimSpace(out) == "restore flag cannot be used along with other configuration flags",
+		fmt.Sprintf("unexpected output %v", out))
+	cleanUp()
+}
+
+func TestConfigureRuntimeRestart(t *testing.T) {
+	setup(t)
+	cleanUp()
+	cfgPathArg := "--config-path=" + configFile
+
+	dir, err := os.MkdirTemp("", "amd-ctk")
+	Assert(t, err == nil, fmt.Sprintf("failed to create temp dir: %v", err))
+	defer os.RemoveAll(dir)
+
+	// fake restart command and docker daemon, which lists the runtimes of
+	// the config file
+	restarted := filepath.Join(dir, "restarted")
+	script := filepath.Join(dir, "restart")
+	Assert(t, os.WriteFile(script, []byte("#!/bin/sh\ntouch "+restarted+"\n"), 0755) == nil, "failed to write the restart command")
+
+	socket := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	Assert(t, err == nil, fmt.Sprintf("failed to listen on %v: %v", socket, err))
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := struct {
+			Runtimes map[string]interface{} `json:"runtimes"`
+		}{}
+		data, _ := os.ReadFile(configFile)
+		_ = json.Unmarshal(data, &cfg)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Runtimes": cfg.Runtimes})
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	out, outErr, err := runCLI("runtime", "configure", "--runtime=docker", cfgPathArg, "--restart",
+		"--restart-command="+script, "--socket="+socket, "--restart-timeout=5s")
+	Assert(t, outErr == "" && err == nil, fmt.Sprintf("amd-ctk runtime configure --restart failed: %v, %v, %v", out, outErr, err))
+	Assert(t, strings.Contains(out, "Verified amd runtime is registered with docker"), fmt.Sprintf("unexpected output %v", out))
+	_, err = os.Stat(restarted)
+	Assert(t, err == nil, "the restart command was not run")
+	verifyConfigFile(t, false, false)
+
+	out, _, err = runCLI("runtime", "configure", "--runtime=docker", cfgPathArg, "--restart", "--dry-run")
+	Assert(t, err != nil, "err shouldn't be nil")
+	Assert(t, strings.TrimSpace(out) == "restart flag cannot be used along with dry-run flag", fmt.Sprintf("unexpected output %v", out))
+	cleanUp()
+}
+
+func TestConfigureRuntimeOCIHook(t *testing.T) {
+	setup(t)
+	hooksDir := t.TempDir()
+	hooksDirArg := "--hooks-dir=" + hooksDir
+	descriptor := filepath.Join(hooksDir, "amd-container-runtime-hook.json")
+
+	out, outErr, err := runCLI("runtime", "configure", "--runtime=podman", "--oci-hook", hooksDirArg, "--dry-run")
+	Assert(t, outErr == "" && err == nil, fmt.Sprintf("amd-ctk runtime configure --oci-hook --dry-run failed: %v, %v", outErr, err))
+	Assert(t, strings.Contains(out, "+      \"--engine-hook\"\n"), fmt.Sprintf("unexpected diff %v", out))
+	_, err = os.Stat(descriptor)
+	Assert(t, os.IsNotExist(err), "the descriptor was written by --dry-run")
+
+	out, outErr, err = runCLI("runtime", "configure", "--runtime=podman", "--oci-hook", hooksDirArg)
+	Assert(t, outErr == "" && err == nil, fmt.Sprintf("amd-ctk runtime configure --oci-hook failed: %v, %v", outErr, err))
+	Assert(t, strings.Contains(out, "Updated the hook descriptor: "+descriptor), fmt.Sprintf("unexpected output %v", out))
+
+	data, err := os.ReadFile(descriptor)
+	Assert(t, err == nil && strings.Contains(string(data), "\"createRuntime\""), fmt.Sprintf("unexpected descriptor %s, %v", data, err))
+
+	out, outErr, err = runCLI("runtime", "configure", "--runtime=podman", "--oci-hook", hooksDirArg, "--remove")
+	Assert(t, outErr == "" && err == nil, fmt.Sprintf("amd-ctk runtime configure --oci-hook --remove failed: %v, %v", outErr, err))
+	Assert(t, strings.Contains(out, "Removed the hook descriptor: "+descriptor), fmt.Sprintf("unexpected output %v", out))
+	_, err = os.Stat(descriptor)
+	Assert(t, os.IsNotExist(err), "the descriptor was not removed")
+
+	// Docker and containerd do not read OCI hooks descriptors
+	_, _, err = runCLI("runtime", "configure", "--runtime=docker", "--oci-hook", hooksDirArg)
+	Assert(t, err != nil, "err shouldn't be nil")
+}
+
+func TestRuntimeStatus(t *testing.T) {
+	setup(t)
+	cleanUp()
+	cfgPathArg := "--config-path=" + configFile
+
+	_, outErr, err := runCLI("runtime", "configure", "--runtime=docker", cfgPathArg, "--set-as-default")
+	Assert(t, outErr == "" && err == nil, fmt.Sprintf("amd-ctk runtime configure failed: %v, %v", outErr, err))
+
+	out, _, err := runCLI("--output=json", "runtime", "status", "--runtime=docker", cfgPathArg)
+	status := struct {
+		Engines []struct {
+			Name       string `json:"name"`
+			Registered bool   `json:"registered"`
+			Default    bool   `json:"default"`
+			CDIEnabled bool   `json:"cdiEnabled"`
+		} `json:"engines"`
+		Problems []string `json:"problems"`
+	}{}
+	Assert(t, json.Unmarshal([]byte(out), &status) == nil, fmt.Sprintf("the output is not a JSON document: %v", out))
+	Assert(t, len(status.Engines) == 1 && status.Engines[0].Name == "docker", fmt.Sprintf("unexpected engines %+v", status.Engines))
+	e := status.Engines[0]
+	Assert(t, e.Registered && e.Default && e.CDIEnabled, fmt.Sprintf("unexpected docker status %+v", e))
+	// The exit code reports the problems of the host, e.g. a missing runc
+	Assert(t, (err != nil) == (len(status.Problems) > 0), fmt.Sprintf("unexpected exit status %v with problems %v", err, status.Problems))
+
+	out, _, err = runCLI("runtime", "status", cfgPathArg)
+	Assert(t, err != nil && strings.TrimSpace(out) == "config-path flag requires the runtime flag", fmt.Sprintf("unexpected output %v", out))
+	cleanUp()
+}
+
+func TestGPUTrackerGC(t *testing.T) {
+	setup(t)
+	dir := t.TempDir()
+	trackerFile := filepath.Join(dir, "gpu-tracker.json")
+	runtimeRoot := filepath.Join(dir, "runc")
+	ctkConfig := filepath.Join(dir, "config.toml")
+
+	config := fmt.Sprintf("version = 1\n[gpu-tracker]\nfile = %q\nlock-file = %q\nruntime-roots = [%q]\n",
+		trackerFile, filepath.Join(dir, "gpu-tracker.lock"), runtimeRoot)
+	Assert(t, os.WriteFile(ctkConfig, []byte(config), 0644) == nil, "failed to write config file")
+	// The bundle of the removed container is gone, the unknown container
+	// has no bundle
+	tracker := fmt.Sprintf(`{"enabled": true, "gpusStatus": {"0": {"uuid": "0x1", "accessibility": 1, "containerIds": ["running"]},
+		"1": {"uuid": "0x2", "accessibility": 0, "containerIds": ["running", "removed", "unknown"]}}, "gpusInfo": {},
+		"leases": {"removed": {"bundle": %q}}}`, filepath.Join(dir, "bundles", "removed"))
+	Assert(t, os.WriteFile(trackerFile, []byte(tracker), 0644) == nil, "failed to write GPU Tracker file")
+
+	// Without any runtime root, nothing can be checked
+	out, _, err := runCLI("--config", ctkConfig, "gpu-tracker", "gc")
+	Assert(t, err != nil, fmt.Sprintf("gc did not fail without runtime roots, output: %v", out))
+
+	Assert(t, os.MkdirAll(filepath.Join(runtimeRoot, "running"), 0755) == nil, "failed to create container state")
+	Assert(t, os.WriteFile(filepath.Join(runtimeRoot, "running", "state.json"), []byte("{}"), 0644) == nil, "failed to write container state")
+
+	out, _, err = runCLI("--config", ctkConfig, "gpu-tracker", "gc")
+	Assert(t, err == nil, fmt.Sprintf("gc failed, Err: %v, output: %v", err, out))
+	expected := "Released GPUs of containers that no longer exist: removed\n" +
+		"Kept GPUs of containers not found in the runtime roots: unknown, use --release-unknown to release them"
+	Assert(t, strings.TrimSpace(out) == expected, fmt.Sprintf("unexpected output %q", out))
+
+	data, err := os.ReadFile(trackerFile)
+	Assert(t, err == nil, fmt.Sprintf("failed to read GPU Tracker file, Err: %v", err))
+	Assert(t, strings.Contains(string(data), "running") && !strings.Contains(string(data), "removed") && strings.Contains(string(data), "unknown"),
+		fmt.Sprintf("unexpected GPU Tracker file %s", data))
+
+	out, _, err = runCLI("--config", ctkConfig, "gpu-tracker", "gc", "--release-unknown")
+	Assert(t, err == nil && strings.TrimSpace(out) == "Released GPUs of containers that no longer exist: unknown", fmt.Sprintf("unexpected output %q, Err: %v", out, err))
+
+	out, _, err = runCLI("--config", ctkConfig, "gpu-tracker", "gc")
+	Assert(t, err == nil && strings.TrimSpace(out) == "No reservations of removed containers found", fmt.Sprintf("unexpected output %q, Err: %v", out, err))
+}
+
+func TestGPUTrackerSharedLimitInvalid(t *testing.T) {
+	setup(t)
+	dir := t.TempDir()
+	trackerFile := filepath.Join(dir, "gpu-tracker.json")
+	ctkConfig := filepath.Join(dir, "config.toml")
+
+	config := fmt.Sprintf("version = 1\n[gpu-tracker]\nfile = %q\nlock-file = %q\n", trackerFile, filepath.Join(dir, "gpu-tracker.lock"))
+	Assert(t, os.WriteFile(ctkConfig, []byte(config), 0644) == nil, "failed to write config file")
+	tracker := `{"enabled": true, "gpusStatus": {}, "gpusInfo": {}}`
+	Assert(t, os.WriteFile(trackerFile, []byte(tracker), 0644) == nil, "failed to write GPU Tracker file")
+
+	for _, operation := range []string{"shared:0", "shared:-1", "shared:four", "shared:"} {
+		out, errOut, err := runCLI("--config", ctkConfig, "gpu-tracker", "0", operation)
+		Assert(t, err != nil, fmt.Sprintf("gpu-tracker accepted %s, output: %v", operation, out))
+		Assert(t, strings.Contains(errOut, "'shared:N'"), fmt.Sprintf("unexpected error output for %s: %v", operation, errOut))
+	}
+}
+
+func TestConfigInitSetGet(t *testing.T) {
+	setup(t)
+	ctkConfig := t.TempDir() + "/config.toml"
+
+	_, _, err := runCLI("--config", ctkConfig, "config", "init")
+	Assert(t, err == nil, fmt.Sprintf("config init failed, Err: %v", err))
+
+	out, _, _ := runCLI("--config", ctkConfig, "config", "init")
+	Assert(t, strings.Contains(out, "already exists"), fmt.Sprintf("config init overwrote existing file, output: %v", out))
+
+	_, _, err = runCLI("--config", ctkConfig, "config", "set", "runtime.runtimes", "crun,runc")
+	Assert(t, err == nil, fmt.Sprintf("config set failed, Err: %v", err))
+
+	out, _, err = runCLI("--config", ctkConfig, "config", "get", "runtime.runtimes")
+	Assert(t, err == nil, fmt.Sprintf("config get failed, Err: %v", err))
+	Assert(t, strings.TrimSpace(out) == "crun,runc", fmt.Sprintf("unexpected runtimes %q", out))
+
+	_, _, err = runCLI("--config", ctkConfig, "config", "set", "hook.path", "relative/path")
+	Assert(t, err != nil, "config set accepted an invalid value")
+}
+
+func TestOutputFormat(t *testing.T) {
+	setup(t)
+
+	out, _, err := runCLI("--output", "xml", "gpu", "list")
+	Assert(t, err != nil, "invalid output format was accepted")
+	Assert(t, strings.Contains(out, "unsupported output format"), fmt.Sprintf("unexpected output: %v", out))
+}
@@ -18,9 +18,15 @@ package configure
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine"
-	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine/docker"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine/engines"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/restart"
+	"github.com/ROCm/container-toolkit/internal/config"
 	"github.com/urfave/cli/v2"
 )
 
@@ -28,7 +34,6 @@ const (
 	defaultRuntime              = "docker"
 	defaultAmdRuntimeName       = "amd"
 	defaultAmdRuntimeExecutable = "amd-container-runtime"
-	defaultDockerConfigFilePath = "/etc/docker/daemon.json"
 )
 
 type configOptions struct {
@@ -37,6 +42,14 @@ type configOptions struct {
 	setAsDefault   bool
 	unSetAsDefault bool
 	remove         bool
+	dryRun         bool
+	restore        bool
+	restart        bool
+	restartCommand string
+	socket         string
+	restartTimeout time.Duration
+	ociHook        bool
+	hooksDir       string
 }
 
 func AddNewCommand() *cli.Command {
@@ -58,7 +71,7 @@ func AddNewCommand() *cli.Command {
 	configureCmd.Flags = []cli.Flag{
 		&cli.StringFlag{
 			Name:        "runtime",
-			Usage:       "target runtime engine, [docker for now]",
+			Usage:       "target runtime engine, [docker, containerd, crio, podman]",
 			Value:       defaultRuntime,
 			Destination: &cfgOptions.runtime,
 		},
@@ -69,8 +82,7 @@ func AddNewCommand() *cli.Command {
 		},
 		&cli.StringFlag{
 			Name:        "config-path",
-			Usage:       "path to the configuration file for the target engine",
-			Value:       defaultDockerConfigFilePath,
+			Usage:       "path to the configuration file for the target engine, defaults to the standard path of the engine",
 			Destination: &cfgOptions.configFilepath,
 		},
 		&cli.BoolFlag{
@@ -85,14 +97,60 @@ func AddNewCommand() *cli.Command {
 			Usage:       "remove AMD runtime as the default",
 			Destination: &cfgOptions.unSetAsDefault,
 		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "print the changes to the configuration file as a unified diff without writing it",
+			Destination: &cfgOptions.dryRun,
+		},
+		&cli.BoolFlag{
+			Name:        "restore",
+			Usage:       "restore the configuration file of the target engine from its last backup",
+			Destination: &cfgOptions.restore,
+		},
+		&cli.BoolFlag{
+			Name:        "restart",
+			Usage:       "restart the target engine once configured, and verify the AMD runtime is registered",
+			Destination: &cfgOptions.restart,
+		},
+		&cli.StringFlag{
+			Name:        "restart-command",
+			Usage:       "command restarting or reloading the target engine, defaults to systemctl restart of the engine unit",
+			Destination: &cfgOptions.restartCommand,
+		},
+		&cli.StringFlag{
+			Name:        "socket",
+			Usage:       "path to the API socket of the target engine, defaults to the standard socket of the engine",
+			Destination: &cfgOptions.socket,
+		},
+		&cli.DurationFlag{
+			Name:        "restart-timeout",
+			Usage:       "time to wait for the target engine to come back after the restart",
+			Value:       restart.DEFAULT_TIMEOUT,
+			Destination: &cfgOptions.restartTimeout,
+		},
+		&cli.BoolFlag{
+			Name:        "oci-hook",
+			Usage:       "install the OCI hooks descriptor of amd-container-runtime-hook instead of registering the AMD runtime, [crio, podman]",
+			Destination: &cfgOptions.ociHook,
+		},
+		&cli.StringFlag{
+			Name:        "hooks-dir",
+			Usage:       "directory of the OCI hooks descriptors read by the target engine",
+			Value:       engine.HOOKS_DIR,
+			Destination: &cfgOptions.hooksDir,
+		},
 	}
 	return &configureCmd
 }
 
 func validateConfigOptions(c *cli.Context, cfgOptions *configOptions) error {
-	if cfgOptions.runtime != "docker" {
+	defaultPath, ok := engines.DefaultConfigPath(cfgOptions.runtime)
+	if !ok {
 		return fmt.Errorf("unsupported runtime engine: %v", cfgOptions.runtime)
 	}
+	if !c.IsSet("config-path") {
+		cfgOptions.configFilepath = defaultPath
+	}
 	if cfgOptions.setAsDefault && cfgOptions.unSetAsDefault {
 		return fmt.Errorf("both set and unset as default cannot be used at the same time")
 	}
@@ -101,6 +159,30 @@ func validateConfigOptions(c *cli.Context, cfgOptions *configOptions) error {
 			return fmt.Errorf("remove flag cannot be used along with set-as-default flag")
 		}
 	}
+	if cfgOptions.restore {
+		if cfgOptions.remove || cfgOptions.setAsDefault || cfgOptions.unSetAsDefault || cfgOptions.dryRun {
+			return fmt.Errorf("restore flag cannot be used along with other configuration flags")
+		}
+		if cfgOptions.configFilepath == "" {
+			return fmt.Errorf("restore flag requires a configuration file path")
+		}
+	}
+	if cfgOptions.ociHook {
+		if cfgOptions.runtime != "crio" && cfgOptions.runtime != "podman" {
+			return fmt.Errorf("oci-hook flag is not supported for %v, which does not read OCI hooks descriptors", cfgOptions.runtime)
+		}
+		if cfgOptions.setAsDefault || cfgOptions.unSetAsDefault || cfgOptions.restore || cfgOptions.restart {
+			return fmt.Errorf("oci-hook flag cannot be used along with the default runtime, restore and restart flags")
+		}
+	}
+	if cfgOptions.restart {
+		if cfgOptions.dryRun {
+			return fmt.Errorf("restart flag cannot be used along with dry-run flag")
+		}
+		if _, ok := restart.Engines[cfgOptions.runtime]; !ok {
+			return fmt.Errorf("restart flag is not supported for %v, which has no daemon", cfgOptions.runtime)
+		}
+	}
 	return nil
 }
 
@@ -111,13 +193,39 @@ func performAction(c *cli.Context, cfgOptions *configOptions) error {
 		doNotUpdate   bool
 	)
 
-	switch cfgOptions.runtime {
-	case "docker":
-		runtimeEngine, err = docker.New(cfgOptions.configFilepath)
-	default:
-		return fmt.Errorf("unsupported runtime engine: %v", cfgOptions.runtime)
+	// Keep the backups out of the drop-in directories the engines load
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load the config: %v", err)
+	}
+	engine.SetBackupDir(filepath.Join(cfg.AmdCtk.BackupDir, cfgOptions.runtime))
+
+	if cfgOptions.ociHook {
+		return configureHook(cfgOptions, cfg.Hook.Path)
+	}
+
+	if cfgOptions.restore {
+		backup, err := engine.Restore(cfgOptions.configFilepath)
+		if err != nil {
+			return fmt.Errorf("failed to restore the config: %v", err)
+		}
+		if strings.HasSuffix(backup, engine.BACKUP_ABSENT_SUFFIX) {
+			fmt.Printf("Removed the config file: %v, which did not exist before: %v\n", cfgOptions.configFilepath, backup)
+		} else {
+			fmt.Printf("Restored the config file: %v from: %v\n", cfgOptions.configFilepath, backup)
+		}
+		if cfgOptions.restart {
+			// The restored file may or may not register the runtime
+			return restartEngine(cfgOptions, false, false)
+		}
+		printRestartHint(cfgOptions.runtime)
+		return nil
 	}
 
+	if _, err := os.Stat(cfgOptions.configFilepath); err == nil {
+		fmt.Printf("Loading configuration from: %v\n", cfgOptions.configFilepath)
+	}
+	runtimeEngine, err = engines.New(cfgOptions.runtime, cfgOptions.configFilepath)
 	if err != nil || runtimeEngine == nil {
 		return fmt.Errorf("failed to init config for runtime engine: %v | err: %v", cfgOptions.runtime, err)
 	}
@@ -140,6 +248,10 @@ func performAction(c *cli.Context, cfgOptions *configOptions) error {
 		}
 	}
 
+	if cfgOptions.dryRun {
+		return printDiff(runtimeEngine, cfgOptions.configFilepath, doNotUpdate)
+	}
+
 	// Save the config
 	if !doNotUpdate {
 		num, err := runtimeEngine.Update(cfgOptions.configFilepath)
@@ -150,7 +262,117 @@ func performAction(c *cli.Context, cfgOptions *configOptions) error {
 		if num != 0 {
 			fmt.Printf("Updated the config file: %v\n", cfgOptions.configFilepath)
 		}
-		fmt.Printf("Please restart %v daemon\n", cfgOptions.runtime)
+		if cfgOptions.restart {
+			return restartEngine(cfgOptions, true, !cfgOptions.remove)
+		}
+		printRestartHint(cfgOptions.runtime)
+	}
+	return nil
+}
+
+// configureHook installs or removes the OCI hooks descriptor of
+// amd-container-runtime-hook, which makes podman and CRI-O run the hook
+// without the AMD runtime
+func configureHook(cfgOptions *configOptions, hookPath string) error {
+	path := engine.HookDescriptorPath(cfgOptions.hooksDir)
+
+	if cfgOptions.dryRun {
+		content := []byte{}
+		if !cfgOptions.remove {
+			var err error
+			if content, err = engine.MarshalHookDescriptor(hookPath); err != nil {
+				return err
+			}
+		}
+		diff, err := engine.Diff(path, content)
+		if err != nil {
+			return err
+		}
+		if diff == "" {
+			fmt.Printf("No changes to the hook descriptor: %v\n", path)
+			return nil
+		}
+		fmt.Print(diff)
+		return nil
+	}
+
+	if cfgOptions.remove {
+		if err := engine.RemoveHookDescriptor(path); err != nil {
+			return fmt.Errorf("failed to remove the hook descriptor: %v", err)
+		}
+		fmt.Printf("Removed the hook descriptor: %v\n", path)
+		return nil
+	}
+
+	written, err := engine.WriteHookDescriptor(path, hookPath)
+	if err != nil {
+		return fmt.Errorf("failed to write the hook descriptor: %v", err)
+	}
+	if written {
+		fmt.Printf("Updated the hook descriptor: %v\n", path)
+	}
+	return nil
+}
+
+// restartEngine restarts the engine and, when verify is set, checks
+// whether the AMD runtime is registered as expected
+func restartEngine(cfgOptions *configOptions, verify bool, registered bool) error {
+	opts := restart.Options{
+		Command: strings.Fields(cfgOptions.restartCommand),
+		Socket:  cfgOptions.socket,
+		Timeout: cfgOptions.restartTimeout,
+	}
+	if err := restart.Restart(cfgOptions.runtime, opts); err != nil {
+		return err
+	}
+	fmt.Printf("%v daemon is up\n", cfgOptions.runtime)
+
+	if !verify {
+		return nil
+	}
+	verified, err := restart.VerifyRuntime(cfgOptions.runtime, cfgOptions.socket, defaultAmdRuntimeName, registered)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !verified:
+		fmt.Printf("The %v API does not list its runtimes, skipping the verification\n", cfgOptions.runtime)
+	case registered:
+		fmt.Printf("Verified %v runtime is registered with %v\n", defaultAmdRuntimeName, cfgOptions.runtime)
+	default:
+		fmt.Printf("Verified %v runtime is no longer registered with %v\n", defaultAmdRuntimeName, cfgOptions.runtime)
+	}
+	return nil
+}
+
+// printRestartHint tells to restart the engine for the config to apply
+func printRestartHint(runtime string) {
+	// podman has no daemon, new containers read the updated config
+	if runtime != "podman" {
+		fmt.Printf("Please restart %v daemon\n", runtime)
+	}
+}
+
+// printDiff prints the changes to the configuration file without
+// writing it
+func printDiff(runtimeEngine engine.Interface, path string, doNotUpdate bool) error {
+	diff := ""
+	if !doNotUpdate {
+		content, err := runtimeEngine.Marshal()
+		if err != nil {
+			return fmt.Errorf("failed to render the config: %v", err)
+		}
+
+		diff, err = engine.Diff(path, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	if diff == "" {
+		fmt.Printf("No changes to the config file: %v\n", path)
+		return nil
 	}
+	fmt.Print(diff)
 	return nil
 }
@@ -0,0 +1,336 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package containerd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine"
+	"github.com/pelletier/go-toml"
+)
+
+const (
+	versionKey            = "version"
+	pluginsKey            = "plugins"
+	containerdKey         = "containerd"
+	runtimesKey           = "runtimes"
+	defaultRuntimeNameKey = "default_runtime_name"
+	enableCDIKey          = "enable_cdi"
+	runtimeTypeKey        = "runtime_type"
+	optionsKey            = "options"
+	binaryNameKey         = "BinaryName"
+
+	// runtime_type of the runc shim, which execs BinaryName instead of runc
+	runcRuntimeType = "io.containerd.runc.v2"
+
+	// Name of the runtime of containerd using the runc shim
+	runcRuntimeName = "runc"
+
+	// Schema version of the config files created from scratch
+	defaultVersion = 2
+)
+
+// Name of the CRI plugin for each config schema version
+var criPlugins = map[int64]string{
+	1: "cri",
+	2: "io.containerd.grpc.v1.cri",
+	3: "io.containerd.cri.v1.runtime",
+}
+
+type containerdConfig struct {
+	tree    *toml.Tree
+	version int64
+
+	// original is the content of the loaded file
+	original []byte
+
+	// ledger records the settings changed by amd-ctk
+	ledger *engine.Ledger
+}
+
+func New(path string) (*containerdConfig, error) {
+	config, err := loadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config.ledger, err = engine.LoadLedger(path)
+	if err != nil {
+		return nil, err
+	}
+	if config.ledger == nil {
+		config.ledger = &engine.Ledger{}
+	}
+	return config, nil
+}
+
+func loadConfigFile(path string) (*containerdConfig, error) {
+	//check if the file exists
+	f, err := os.Stat(path)
+	if err == nil && f.IsDir() {
+		return nil, fmt.Errorf("file path is a directory")
+	}
+
+	if os.IsNotExist(err) {
+		// return empty config
+		tree, err := toml.TreeFromMap(map[string]interface{}{versionKey: int64(defaultVersion)})
+		if err != nil {
+			return nil, err
+		}
+		return &containerdConfig{tree: tree, version: defaultVersion}, nil
+	}
+
+	readB, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading configuration file: %v | err: %v", path, err)
+	}
+
+	tree, err := toml.LoadBytes(readB)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding configuration file: %v | err: %v", path, err)
+	}
+
+	// Config files without a version use the v1 schema
+	version := int64(1)
+	if tree.Has(versionKey) {
+		v, ok := tree.Get(versionKey).(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid version in configuration file: %v", path)
+		}
+		version = v
+	}
+	if _, ok := criPlugins[version]; !ok {
+		return nil, fmt.Errorf("unsupported configuration version %v in %v", version, path)
+	}
+
+	return &containerdConfig{tree: tree, version: version, original: readB}, nil
+}
+
+// pluginPath returns the path of the CRI plugin table followed by keys
+func (c *containerdConfig) pluginPath(keys ...string) []string {
+	return append([]string{pluginsKey, criPlugins[c.version]}, keys...)
+}
+
+// change sets the setting at key and records it in the ledger
+func (c *containerdConfig) change(key []string, value interface{}) {
+	previous := c.tree.GetPath(key)
+	existed := c.tree.HasPath(key)
+	c.tree.SetPath(key, value)
+	c.ledger.Record(key, existed, previous, value)
+}
+
+// remove deletes the setting at key and the tables left empty
+func (c *containerdConfig) remove(key []string) {
+	_ = c.tree.DeletePath(key)
+	engine.PruneEmptyTables(c.tree, key[:len(key)-1])
+}
+
+// revert restores the setting at key to its value before amd-ctk changed
+// it, unless it was changed since. It returns false if the ledger has no
+// change for key.
+func (c *containerdConfig) revert(key []string) bool {
+	ch := c.ledger.Find(key)
+	if ch == nil {
+		return false
+	}
+
+	if c.tree.HasPath(key) && engine.SameValue(c.tree.GetPath(key), ch.Value) {
+		if ch.Existed {
+			c.tree.SetPath(key, ch.Previous)
+		} else {
+			c.remove(key)
+		}
+	}
+	c.ledger.Forget(key)
+	return true
+}
+
+// baseOptions returns a copy of the options table of the runtime the
+// runtime name is based on: the default runtime when it uses the runc shim,
+// or runc. Settings such as SystemdCgroup then apply to both runtimes. It
+// returns nil if there is no such table.
+func (c *containerdConfig) baseOptions(name string) (*toml.Tree, error) {
+	bases := []string{runcRuntimeName}
+	if def, ok := c.tree.GetPath(c.pluginPath(containerdKey, defaultRuntimeNameKey)).(string); ok && def != name {
+		bases = append([]string{def}, bases...)
+	}
+
+	for _, base := range bases {
+		basePath := c.pluginPath(containerdKey, runtimesKey, base)
+		if c.tree.GetPath(append(slices.Clone(basePath), runtimeTypeKey)) != runcRuntimeType {
+			continue
+		}
+		options, ok := c.tree.GetPath(append(basePath, optionsKey)).(*toml.Tree)
+		if !ok {
+			continue
+		}
+		return toml.TreeFromMap(options.ToMap())
+	}
+	return nil, nil
+}
+
+func (c *containerdConfig) ConfigRuntime(name string, path string, isDefault bool) error {
+	if c == nil || c.tree == nil {
+		return fmt.Errorf("configuration is empty")
+	}
+
+	// A runtime table added by amd-ctk belongs to it as a whole, only the
+	// BinaryName of a table written by the administrator is changed
+	runtimePath := c.pluginPath(containerdKey, runtimesKey, name)
+	binaryNamePath := append(slices.Clone(runtimePath), optionsKey, binaryNameKey)
+	switch {
+	case !c.tree.HasPath(runtimePath):
+		options, err := c.baseOptions(name)
+		if err != nil {
+			return fmt.Errorf("failed to copy the runtime options | err: %v", err)
+		}
+		c.tree.SetPath(append(slices.Clone(runtimePath), runtimeTypeKey), runcRuntimeType)
+		if options != nil {
+			c.tree.SetPath(append(slices.Clone(runtimePath), optionsKey), options)
+		}
+		c.tree.SetPath(binaryNamePath, path)
+		c.ledger.Record(runtimePath, false, nil, nil)
+	case c.ledger.Find(runtimePath) != nil:
+		c.tree.SetPath(binaryNamePath, path)
+	default:
+		if _, ok := c.tree.GetPath(runtimePath).(*toml.Tree); !ok {
+			return fmt.Errorf("%q is not a table", strings.Join(runtimePath, "."))
+		}
+		c.change(binaryNamePath, path)
+	}
+
+	// Enable CDI by default
+	c.change(c.pluginPath(enableCDIKey), true)
+
+	if isDefault {
+		c.change(c.pluginPath(containerdKey, defaultRuntimeNameKey), name)
+	}
+
+	return nil
+}
+
+func (c *containerdConfig) UnsetDefaultRuntime() error {
+	if c == nil || c.tree == nil {
+		return fmt.Errorf("configuration is empty")
+	}
+
+	// Restore the default runtime set before amd-ctk, if any
+	defaultPath := c.pluginPath(containerdKey, defaultRuntimeNameKey)
+	if !c.revert(defaultPath) && c.tree.HasPath(defaultPath) {
+		c.remove(defaultPath)
+	}
+
+	fmt.Println("Removed amd as the default runtime")
+	return nil
+}
+
+// RemoveRuntime reverts the changes recorded in the ledger and returns
+// an error and a do not update flag in case config.toml doesn't need
+// to be updated. Without a ledger the amd runtime, enable_cdi and the
+// default runtime when set to amd are removed.
+func (c *containerdConfig) RemoveRuntime(name string) (error, bool) {
+	if c == nil || c.tree == nil {
+		return fmt.Errorf("configuration is empty"), true
+	}
+
+	updated := false
+	runtimePath := c.pluginPath(containerdKey, runtimesKey, name)
+	if ch := c.ledger.Find(runtimePath); ch != nil {
+		if c.tree.HasPath(runtimePath) {
+			c.remove(runtimePath)
+		}
+		c.ledger.Forget(runtimePath)
+		updated = true
+	}
+
+	keys := [][]string{
+		append(slices.Clone(runtimePath), optionsKey, binaryNameKey),
+		c.pluginPath(enableCDIKey),
+		c.pluginPath(containerdKey, defaultRuntimeNameKey),
+	}
+	for _, key := range keys {
+		if c.revert(key) {
+			updated = true
+		}
+	}
+
+	if !updated && c.tree.HasPath(runtimePath) {
+		c.remove(runtimePath)
+		if c.tree.HasPath(c.pluginPath(enableCDIKey)) {
+			c.remove(c.pluginPath(enableCDIKey))
+		}
+		defaultPath := c.pluginPath(containerdKey, defaultRuntimeNameKey)
+		if def, ok := c.tree.GetPath(defaultPath).(string); ok && def == name {
+			c.remove(defaultPath)
+		}
+		updated = true
+	}
+
+	return nil, !updated
+}
+
+func (c containerdConfig) RuntimePath(name string) (string, bool) {
+	runtimePath := c.pluginPath(containerdKey, runtimesKey, name)
+	if !c.tree.HasPath(runtimePath) {
+		return "", false
+	}
+	path, _ := c.tree.GetPath(append(runtimePath, optionsKey, binaryNameKey)).(string)
+	return path, true
+}
+
+func (c containerdConfig) IsDefaultRuntime(name string) bool {
+	def, _ := c.tree.GetPath(c.pluginPath(containerdKey, defaultRuntimeNameKey)).(string)
+	return def == name
+}
+
+// IsCDIEnabled reports whether enable_cdi is set, which defaults to true
+// from containerd 2.0, the first release with the version 3 schema
+func (c containerdConfig) IsCDIEnabled() bool {
+	enabled, ok := c.tree.GetPath(c.pluginPath(enableCDIKey)).(bool)
+	if !ok {
+		return c.version >= 3
+	}
+	return enabled
+}
+
+// Marshal returns config.toml with the keys in the order of the loaded file
+func (c containerdConfig) Marshal() ([]byte, error) {
+	return engine.MarshalTOML(c.tree, c.original)
+}
+
+func (c containerdConfig) Update(path string) (int, error) {
+	toWrite, err := c.Marshal()
+	if err != nil {
+		return 0, err
+	}
+
+	if path == "" {
+		num, err := os.Stdout.Write(toWrite)
+		return num, err
+	}
+
+	// The config file and its ledger are backed up with the same stamp,
+	// for --restore to roll both back
+	stamp := engine.NewBackupStamp()
+	if err := engine.WriteFile(path, toWrite, stamp); err != nil {
+		return 0, err
+	}
+	return len(toWrite), c.ledger.Save(path, stamp)
+}
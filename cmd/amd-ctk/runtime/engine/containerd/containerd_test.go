@@ -0,0 +1,204 @@
+package containerd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pelletier/go-toml"
+)
+
+func loadTestConfig(t *testing.T, name string) (*containerdConfig, string) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if name != "" {
+		data, err := os.ReadFile(filepath.Join("../../../../../tests/containerd", name))
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("writing %s: %v", path, err)
+		}
+	}
+
+	cfg, err := New(path)
+	if err != nil {
+		t.Fatalf("loading %s: %v", name, err)
+	}
+	return cfg, path
+}
+
+func reloadConfig(t *testing.T, path string) *toml.Tree {
+	tree, err := toml.LoadFile(path)
+	if err != nil {
+		t.Fatalf("loading %s: %v", path, err)
+	}
+	return tree
+}
+
+func TestConfigRuntime(t *testing.T) {
+	tests := []struct {
+		file    string
+		version int64
+		plugin  string
+	}{
+		{"", 2, "io.containerd.grpc.v1.cri"},
+		{"config-v1.toml", 1, "cri"},
+		{"config-v2.toml", 2, "io.containerd.grpc.v1.cri"},
+		{"config-v3.toml", 3, "io.containerd.cri.v1.runtime"},
+	}
+
+	for _, tt := range tests {
+		cfg, path := loadTestConfig(t, tt.file)
+		Assert(t, cfg.version == tt.version, fmt.Sprintf("%q: expected version %d, got %d", tt.file, tt.version, cfg.version))
+
+		err := cfg.ConfigRuntime("amd", "amd-container-runtime", true)
+		Assert(t, err == nil, fmt.Sprintf("%q: ConfigRuntime returned error %v", tt.file, err))
+		_, err = cfg.Update(path)
+		Assert(t, err == nil, fmt.Sprintf("%q: Update returned error %v", tt.file, err))
+
+		tree := reloadConfig(t, path)
+		runtime := []string{"plugins", tt.plugin, "containerd", "runtimes", "amd"}
+		Assert(t, tree.GetPath(append(runtime, "runtime_type")) == "io.containerd.runc.v2",
+			fmt.Sprintf("%q: unexpected runtime_type %v", tt.file, tree.GetPath(append(runtime, "runtime_type"))))
+		Assert(t, tree.GetPath(append(runtime, "options", "BinaryName")) == "amd-container-runtime",
+			fmt.Sprintf("%q: unexpected BinaryName %v", tt.file, tree.GetPath(append(runtime, "options", "BinaryName"))))
+		Assert(t, tree.GetPath([]string{"plugins", tt.plugin, "enable_cdi"}) == true, fmt.Sprintf("%q: CDI is not enabled", tt.file))
+		// The options of runc are copied
+		systemdCgroup := tree.GetPath(append(runtime, "options", "SystemdCgroup"))
+		Assert(t, (systemdCgroup == true) == (tt.file == "config-v2.toml"), fmt.Sprintf("%q: unexpected SystemdCgroup %v", tt.file, systemdCgroup))
+		Assert(t, tree.GetPath([]string{"plugins", tt.plugin, "containerd", "default_runtime_name"}) == "amd",
+			fmt.Sprintf("%q: amd is not the default runtime", tt.file))
+
+		cfg, err = New(path)
+		Assert(t, err == nil, fmt.Sprintf("%q: reloading returned error %v", tt.file, err))
+		runtimePath, registered := cfg.RuntimePath("amd")
+		Assert(t, registered && runtimePath == "amd-container-runtime", fmt.Sprintf("%q: unexpected runtime path %v, %v", tt.file, runtimePath, registered))
+		Assert(t, cfg.IsDefaultRuntime("amd") && cfg.IsCDIEnabled(), fmt.Sprintf("%q: unexpected inspection", tt.file))
+	}
+}
+
+func TestConfigRuntimeDefaultOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	config := `version = 2
+
+[plugins."io.containerd.grpc.v1.cri".containerd]
+  default_runtime_name = "crun"
+
+[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.crun]
+  runtime_type = "io.containerd.runc.v2"
+
+[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.crun.options]
+  BinaryName = "/usr/bin/crun"
+  SystemdCgroup = true
+
+[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc]
+  runtime_type = "io.containerd.runc.v2"
+`
+	Assert(t, os.WriteFile(path, []byte(config), 0644) == nil, "writing the config failed")
+	cfg, err := New(path)
+	Assert(t, err == nil, fmt.Sprintf("loading %s: %v", path, err))
+
+	// The options of the default runtime are copied, except for BinaryName
+	Assert(t, cfg.ConfigRuntime("amd", "amd-container-runtime", false) == nil, "ConfigRuntime failed")
+	options := cfg.pluginPath("containerd", "runtimes", "amd", "options")
+	Assert(t, cfg.tree.GetPath(append(options, "SystemdCgroup")) == true, "SystemdCgroup of the default runtime is not copied")
+	Assert(t, cfg.tree.GetPath(append(options, "BinaryName")) == "amd-container-runtime",
+		fmt.Sprintf("unexpected BinaryName %v", cfg.tree.GetPath(append(options, "BinaryName"))))
+	crun := cfg.pluginPath("containerd", "runtimes", "crun", "options", "BinaryName")
+	Assert(t, cfg.tree.GetPath(crun) == "/usr/bin/crun", "the options of the default runtime were changed")
+}
+
+func TestRemoveRuntime(t *testing.T) {
+	cfg, path := loadTestConfig(t, "config-v2.toml")
+	original := reloadConfig(t, path)
+
+	Assert(t, cfg.ConfigRuntime("amd", "amd-container-runtime", true) == nil, "ConfigRuntime failed")
+	err, doNotUpdate := cfg.RemoveRuntime("amd")
+	Assert(t, err == nil && !doNotUpdate, fmt.Sprintf("unexpected RemoveRuntime result %v, %v", err, doNotUpdate))
+	_, err = cfg.Update(path)
+	Assert(t, err == nil, fmt.Sprintf("Update returned error %v", err))
+
+	// The user settings are restored, including the previous default runtime
+	tree := reloadConfig(t, path)
+	Assert(t, tree.String() == original.String(), fmt.Sprintf("expected:\n%s\ngot:\n%s", original, tree))
+	_, err = os.Stat(path + ".amd-ctk.json")
+	Assert(t, os.IsNotExist(err), "the ledger should be removed once empty")
+
+	err, doNotUpdate = cfg.RemoveRuntime("amd")
+	Assert(t, err == nil && doNotUpdate, "removing a missing runtime should not update the config")
+
+	cfg, path = loadTestConfig(t, "")
+	Assert(t, cfg.ConfigRuntime("amd", "amd-container-runtime", false) == nil, "ConfigRuntime failed")
+	err, _ = cfg.RemoveRuntime("amd")
+	Assert(t, err == nil, fmt.Sprintf("RemoveRuntime returned error %v", err))
+	_, err = cfg.Update(path)
+	Assert(t, err == nil, fmt.Sprintf("Update returned error %v", err))
+	tree = reloadConfig(t, path)
+	Assert(t, len(tree.Keys()) == 1 && tree.Has("version"), fmt.Sprintf("empty tables are not pruned:\n%s", tree))
+}
+
+func TestUnsetDefaultRuntime(t *testing.T) {
+	cfg, _ := loadTestConfig(t, "config-v2.toml")
+	Assert(t, cfg.ConfigRuntime("amd", "amd-container-runtime", true) == nil, "ConfigRuntime failed")
+	Assert(t, cfg.UnsetDefaultRuntime() == nil, "UnsetDefaultRuntime failed")
+	def := cfg.tree.GetPath(cfg.pluginPath("containerd", "default_runtime_name"))
+	Assert(t, def == "runc", fmt.Sprintf("the previous default runtime is not restored: %v", def))
+	Assert(t, cfg.tree.HasPath(cfg.pluginPath("containerd", "runtimes", "amd")), "amd runtime was removed")
+}
+
+func TestRemoveRuntimeKeepsUserChanges(t *testing.T) {
+	cfg, path := loadTestConfig(t, "config-v2.toml")
+	Assert(t, cfg.ConfigRuntime("amd", "amd-container-runtime", false) == nil, "ConfigRuntime failed")
+	_, err := cfg.Update(path)
+	Assert(t, err == nil, fmt.Sprintf("Update returned error %v", err))
+
+	// enable_cdi is set by the administrator after amd-ctk
+	cfg, err = New(path)
+	Assert(t, err == nil, fmt.Sprintf("reloading %s: %v", path, err))
+	cfg.tree.SetPath(cfg.pluginPath("enable_cdi"), false)
+
+	err, doNotUpdate := cfg.RemoveRuntime("amd")
+	Assert(t, err == nil && !doNotUpdate, fmt.Sprintf("unexpected RemoveRuntime result %v, %v", err, doNotUpdate))
+	Assert(t, !cfg.tree.HasPath(cfg.pluginPath("containerd", "runtimes", "amd")), "amd runtime is not removed")
+	Assert(t, cfg.tree.GetPath(cfg.pluginPath("enable_cdi")) == false, "enable_cdi changed by the administrator was reverted")
+}
+
+func TestRemoveRuntimeWithoutLedger(t *testing.T) {
+	cfg, path := loadTestConfig(t, "config-v2.toml")
+	Assert(t, cfg.ConfigRuntime("amd", "amd-container-runtime", true) == nil, "ConfigRuntime failed")
+	_, err := cfg.Update(path)
+	Assert(t, err == nil, fmt.Sprintf("Update returned error %v", err))
+	Assert(t, os.Remove(path+".amd-ctk.json") == nil, "the ledger should be written")
+
+	cfg, err = New(path)
+	Assert(t, err == nil, fmt.Sprintf("reloading %s: %v", path, err))
+	err, doNotUpdate := cfg.RemoveRuntime("amd")
+	Assert(t, err == nil && !doNotUpdate, fmt.Sprintf("unexpected RemoveRuntime result %v, %v", err, doNotUpdate))
+	Assert(t, !cfg.tree.HasPath(cfg.pluginPath("containerd", "runtimes", "amd")), "amd runtime is not removed")
+	Assert(t, !cfg.tree.HasPath(cfg.pluginPath("enable_cdi")), "enable_cdi is not removed")
+	Assert(t, !cfg.tree.HasPath(cfg.pluginPath("containerd", "default_runtime_name")), "default runtime is not removed")
+}
+
+func TestIsCDIEnabled(t *testing.T) {
+	// enable_cdi defaults to true from the version 3 schema
+	cfg, _ := loadTestConfig(t, "config-v2.toml")
+	Assert(t, !cfg.IsCDIEnabled(), "CDI should be disabled by default with version 2")
+	cfg, _ = loadTestConfig(t, "config-v3.toml")
+	Assert(t, cfg.IsCDIEnabled(), "CDI should be enabled by default with version 3")
+	cfg.tree.SetPath(cfg.pluginPath("enable_cdi"), false)
+	Assert(t, !cfg.IsCDIEnabled(), "enable_cdi is not read")
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	Assert(t, os.WriteFile(path, []byte("version = 4\n"), 0644) == nil, "writing config failed")
+	_, err := New(path)
+	Assert(t, err != nil, "version 4 should not be supported")
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
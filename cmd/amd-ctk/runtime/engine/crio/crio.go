@@ -0,0 +1,221 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package crio
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine"
+	"github.com/ROCm/container-toolkit/internal/config"
+	"github.com/pelletier/go-toml"
+)
+
+const (
+	crioKey           = "crio"
+	runtimeKey        = "runtime"
+	runtimesKey       = "runtimes"
+	defaultRuntimeKey = "default_runtime"
+	runtimePathKey    = "runtime_path"
+	runtimeTypeKey    = "runtime_type"
+	runtimeRootKey    = "runtime_root"
+	monitorPathKey    = "monitor_path"
+	monitorEnvKey     = "monitor_env"
+
+	ociRuntimeType = "oci"
+
+	// Name of the runtime registered by amd-ctk
+	amdRuntimeName = "amd"
+
+	// Fallback when conmon is not found on the host
+	defaultMonitorPath = "/usr/libexec/crio/conmon"
+)
+
+// Paths of conmon installed by the CRI-O packages
+var monitorPaths = []string{
+	"/usr/libexec/crio/conmon",
+	"/usr/local/libexec/crio/conmon",
+	"/usr/bin/conmon",
+	"/usr/local/bin/conmon",
+}
+
+// crioConfig is a CRI-O drop-in configuration file, owned by amd-ctk,
+// which is merged by CRI-O on top of crio.conf
+type crioConfig struct {
+	tree *toml.Tree
+
+	// original is the content of the loaded file
+	original []byte
+
+	// lookPath resolves the runtime executable to an absolute path
+	lookPath func(string) (string, error)
+
+	// fileExists reports whether a monitor binary exists
+	fileExists func(string) bool
+}
+
+func New(path string) (*crioConfig, error) {
+	return loadConfigFile(path)
+}
+
+func loadConfigFile(path string) (*crioConfig, error) {
+	config := &crioConfig{
+		lookPath: exec.LookPath,
+		fileExists: func(p string) bool {
+			_, err := os.Stat(p)
+			return err == nil
+		},
+	}
+
+	//check if the file exists
+	f, err := os.Stat(path)
+	if err == nil && f.IsDir() {
+		return nil, fmt.Errorf("file path is a directory")
+	}
+
+	if os.IsNotExist(err) {
+		// return empty config
+		config.tree, err = toml.TreeFromMap(map[string]interface{}{})
+		if err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+
+	readB, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading configuration file: %v | err: %v", path, err)
+	}
+
+	config.tree, err = toml.LoadBytes(readB)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding configuration file: %v | err: %v", path, err)
+	}
+	config.original = readB
+
+	return config, nil
+}
+
+// runtimePath returns the path of the [crio.runtime] table followed by keys
+func runtimePath(keys ...string) []string {
+	return append([]string{crioKey, runtimeKey}, keys...)
+}
+
+// monitorPath returns the path of conmon on the host
+func (c *crioConfig) monitorPath() string {
+	for _, p := range monitorPaths {
+		if c.fileExists(p) {
+			return p
+		}
+	}
+	return defaultMonitorPath
+}
+
+func (c *crioConfig) ConfigRuntime(name string, path string, isDefault bool) error {
+	if c == nil || c.tree == nil {
+		return fmt.Errorf("configuration is empty")
+	}
+
+	runtime, err := toml.TreeFromMap(map[string]interface{}{
+		runtimePathKey: engine.ResolveRuntimePath(path, c.lookPath),
+		runtimeTypeKey: ociRuntimeType,
+		runtimeRootKey: config.CRIO_RUNTIME_ROOT,
+		monitorPathKey: c.monitorPath(),
+		monitorEnvKey:  []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+	})
+	if err != nil {
+		return err
+	}
+	c.tree.SetPath(runtimePath(runtimesKey, name), runtime)
+
+	if isDefault {
+		c.tree.SetPath(runtimePath(defaultRuntimeKey), name)
+	}
+
+	return nil
+}
+
+// UnsetDefaultRuntime removes default_runtime from the drop-in when it is
+// the amd runtime, and leaves any other default runtime alone
+func (c *crioConfig) UnsetDefaultRuntime() error {
+	if c == nil || c.tree == nil {
+		return fmt.Errorf("configuration is empty")
+	}
+
+	if !c.IsDefaultRuntime(amdRuntimeName) {
+		return nil
+	}
+
+	_ = c.tree.DeletePath(runtimePath(defaultRuntimeKey))
+	engine.PruneEmptyTables(c.tree, runtimePath())
+
+	fmt.Println("Removed amd as the default runtime")
+	return nil
+}
+
+// RemoveRuntime removes the amd runtime configuration and returns
+// an error and a do not update flag in case the drop-in doesn't need
+// to be updated
+func (c *crioConfig) RemoveRuntime(name string) (error, bool) {
+	if c == nil || c.tree == nil {
+		return fmt.Errorf("configuration is empty"), true
+	}
+
+	if !c.tree.HasPath(runtimePath(runtimesKey, name)) {
+		return nil, true
+	}
+
+	_ = c.tree.DeletePath(runtimePath(runtimesKey, name))
+	if def, ok := c.tree.GetPath(runtimePath(defaultRuntimeKey)).(string); ok && def == name {
+		_ = c.tree.DeletePath(runtimePath(defaultRuntimeKey))
+	}
+
+	engine.PruneEmptyTables(c.tree, runtimePath(runtimesKey))
+	return nil, false
+}
+
+func (c crioConfig) RuntimePath(name string) (string, bool) {
+	if !c.tree.HasPath(runtimePath(runtimesKey, name)) {
+		return "", false
+	}
+	path, _ := c.tree.GetPath(runtimePath(runtimesKey, name, runtimePathKey)).(string)
+	return path, true
+}
+
+// IsDefaultRuntime only looks at the drop-in, the default runtime of
+// crio.conf is not read
+func (c crioConfig) IsDefaultRuntime(name string) bool {
+	def, _ := c.tree.GetPath(runtimePath(defaultRuntimeKey)).(string)
+	return def == name
+}
+
+// IsCDIEnabled returns true, CRI-O always resolves CDI devices
+func (c crioConfig) IsCDIEnabled() bool {
+	return true
+}
+
+// Marshal returns the drop-in with the keys in the order of the loaded
+// file, empty once it holds no settings
+func (c crioConfig) Marshal() ([]byte, error) {
+	return engine.MarshalTOML(c.tree, c.original)
+}
+
+// Update writes the drop-in, or deletes it once it holds no settings
+func (c crioConfig) Update(path string) (int, error) {
+	return engine.UpdateDropIn(path, c.tree, c.original)
+}
@@ -0,0 +1,101 @@
+package crio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pelletier/go-toml"
+)
+
+func newTestConfig(t *testing.T) (*crioConfig, string) {
+	path := filepath.Join(t.TempDir(), "crio.conf.d", "99-amd.toml")
+	cfg, err := New(path)
+	if err != nil {
+		t.Fatalf("loading %s: %v", path, err)
+	}
+	cfg.lookPath = func(string) (string, error) { return "/opt/amd/bin/amd-container-runtime", nil }
+	cfg.fileExists = func(p string) bool { return p == "/usr/bin/conmon" }
+	return cfg, path
+}
+
+func TestConfigRuntime(t *testing.T) {
+	cfg, path := newTestConfig(t)
+	err := cfg.ConfigRuntime("amd", "amd-container-runtime", true)
+	Assert(t, err == nil, fmt.Sprintf("ConfigRuntime returned error %v", err))
+	_, err = cfg.Update(path)
+	Assert(t, err == nil, fmt.Sprintf("Update returned error %v", err))
+
+	tree, err := toml.LoadFile(path)
+	Assert(t, err == nil, fmt.Sprintf("loading %s: %v", path, err))
+	expected := map[string]interface{}{
+		"crio.runtime.default_runtime":           "amd",
+		"crio.runtime.runtimes.amd.runtime_path": "/opt/amd/bin/amd-container-runtime",
+		"crio.runtime.runtimes.amd.runtime_type": "oci",
+		"crio.runtime.runtimes.amd.runtime_root": "/run/amd-container-runtime",
+		"crio.runtime.runtimes.amd.monitor_path": "/usr/bin/conmon",
+	}
+	for key, value := range expected {
+		Assert(t, tree.Get(key) == value, fmt.Sprintf("%s: expected %v, got %v", key, value, tree.Get(key)))
+	}
+
+	// The drop-in of an existing file is updated in place
+	cfg, err = New(path)
+	Assert(t, err == nil, fmt.Sprintf("reloading %s: %v", path, err))
+	runtimePath, registered := cfg.RuntimePath("amd")
+	Assert(t, registered && runtimePath == "/opt/amd/bin/amd-container-runtime", fmt.Sprintf("unexpected runtime path %v, %v", runtimePath, registered))
+	Assert(t, cfg.IsDefaultRuntime("amd"), "amd should be the default runtime")
+	Assert(t, cfg.UnsetDefaultRuntime() == nil, "UnsetDefaultRuntime failed")
+	Assert(t, !cfg.tree.Has("crio.runtime.default_runtime"), "default runtime is still set")
+	Assert(t, cfg.tree.Has("crio.runtime.runtimes.amd"), "amd runtime was removed")
+}
+
+func TestUnsetOtherDefaultRuntime(t *testing.T) {
+	_, path := newTestConfig(t)
+	content := []byte("[crio.runtime]\ndefault_runtime = \"crun\"\n")
+	Assert(t, os.MkdirAll(filepath.Dir(path), 0755) == nil, "failed to create the drop-in directory")
+	Assert(t, os.WriteFile(path, content, 0644) == nil, "failed to write the drop-in")
+
+	cfg, err := New(path)
+	Assert(t, err == nil, fmt.Sprintf("loading %s: %v", path, err))
+	Assert(t, cfg.UnsetDefaultRuntime() == nil, "UnsetDefaultRuntime failed")
+	Assert(t, cfg.IsDefaultRuntime("crun"), "the default runtime crun was removed")
+
+	num, err := cfg.Update(path)
+	Assert(t, err == nil && num == 0, fmt.Sprintf("Update returned %v, %v", num, err))
+	data, _ := os.ReadFile(path)
+	Assert(t, string(data) == string(content), fmt.Sprintf("the drop-in was changed to %q", data))
+}
+
+func TestMonitorPath(t *testing.T) {
+	cfg, _ := newTestConfig(t)
+	Assert(t, cfg.monitorPath() == "/usr/bin/conmon", fmt.Sprintf("unexpected monitor path %v", cfg.monitorPath()))
+
+	cfg.fileExists = func(string) bool { return false }
+	Assert(t, cfg.monitorPath() == "/usr/libexec/crio/conmon", fmt.Sprintf("unexpected monitor path %v", cfg.monitorPath()))
+}
+
+func TestRemoveRuntime(t *testing.T) {
+	cfg, path := newTestConfig(t)
+	err, doNotUpdate := cfg.RemoveRuntime("amd")
+	Assert(t, err == nil && doNotUpdate, "removing a missing runtime should not update the drop-in")
+
+	Assert(t, cfg.ConfigRuntime("amd", "amd-container-runtime", true) == nil, "ConfigRuntime failed")
+	_, err = cfg.Update(path)
+	Assert(t, err == nil, fmt.Sprintf("Update returned error %v", err))
+
+	err, doNotUpdate = cfg.RemoveRuntime("amd")
+	Assert(t, err == nil && !doNotUpdate, fmt.Sprintf("unexpected RemoveRuntime result %v, %v", err, doNotUpdate))
+	_, err = cfg.Update(path)
+	Assert(t, err == nil, fmt.Sprintf("Update returned error %v", err))
+
+	_, err = os.Stat(path)
+	Assert(t, os.IsNotExist(err), "the empty drop-in should be removed")
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
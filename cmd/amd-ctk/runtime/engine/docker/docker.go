@@ -21,16 +21,42 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine"
+	"github.com/ROCm/container-toolkit/internal/cdi"
 )
 
 const (
-	runtimesKey        = "runtimes"
-	defaultRuntimeKey  = "default-runtime"
-	featuresKey        = "features"
-	defaultCDISpecPath = "/etc/cdi"
+	runtimesKey       = "runtimes"
+	defaultRuntimeKey = "default-runtime"
+	featuresKey       = "features"
+	cdiFeatureKey     = "cdi"
 )
 
-type dockerConfig map[string]interface{}
+// Directories of the CDI specs generated by amd-ctk
+var defaultCDISpecDirs = []string{"/etc/cdi", "/var/run/cdi"}
+
+type dockerConfig struct {
+	settings map[string]interface{}
+
+	// Layout of the loaded file, kept when writing it back
+	order           *keyOrder
+	indent          string
+	trailingNewline bool
+
+	// ledger records the settings changed by amd-ctk
+	ledger *engine.Ledger
+
+	// removeCDISpecs is set by RemoveRuntime, the specs are only removed
+	// when the configuration is written
+	removeCDISpecs bool
+
+	// cdiSpecDirs are the directories of the CDI specs removed along with
+	// the runtime
+	cdiSpecDirs []string
+}
 
 func New(path string) (*dockerConfig, error) {
 	return loadConfigFile(path)
@@ -43,128 +69,299 @@ func loadConfigFile(path string) (*dockerConfig, error) {
 		return nil, fmt.Errorf("file path is a directory")
 	}
 
-	config := dockerConfig{}
+	config := dockerConfig{
+		settings:        map[string]interface{}{},
+		indent:          defaultIndent,
+		trailingNewline: true,
+		ledger:          &engine.Ledger{},
+		cdiSpecDirs:     defaultCDISpecDirs,
+	}
+
+	ledger, lerr := engine.LoadLedger(path)
+	if lerr != nil {
+		return nil, lerr
+	}
+	if ledger != nil {
+		config.ledger = ledger
+	}
 
 	if os.IsNotExist(err) {
 		// return empty config
 		return &config, nil
 	}
 
-	fmt.Printf("Loading configuration from: %v\n", path)
-
 	readB, err := os.ReadFile(path)
 
 	if err != nil {
 		return nil, fmt.Errorf("error reading configuration file: %v | err: %v", path, err)
 	}
 
-	reader := bytes.NewReader(readB)
-	err = json.NewDecoder(reader).Decode(&config)
+	if len(bytes.TrimSpace(readB)) == 0 {
+		return &config, nil
+	}
+
+	// Numbers are kept as written in the file
+	dec := json.NewDecoder(bytes.NewReader(readB))
+	dec.UseNumber()
+	err = dec.Decode(&config.settings)
 	if err != nil {
 		return nil, fmt.Errorf("error decoding configuration file: %v | err: %v", path, err)
 	}
 
+	config.order, err = parseKeyOrder(readB)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding configuration file: %v | err: %v", path, err)
+	}
+	config.indent = detectIndent(readB)
+	config.trailingNewline = bytes.HasSuffix(readB, []byte("\n"))
+
 	return &config, nil
 }
 
-func (d *dockerConfig) ConfigRuntime(name string, path string, isDefault bool) error {
-	if d == nil {
-		return fmt.Errorf("configuration is empty")
+// get returns the setting at key
+func (d *dockerConfig) get(key []string) (interface{}, bool) {
+	var value interface{} = d.settings
+	for _, k := range key {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[k]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// set sets the setting at key, creating the missing objects on the way
+func (d *dockerConfig) set(key []string, value interface{}) error {
+	m := d.settings
+	for i, k := range key[:len(key)-1] {
+		if _, exists := m[k]; !exists {
+			m[k] = map[string]interface{}{}
+		}
+		child, ok := m[k].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%q is not an object", strings.Join(key[:i+1], "."))
+		}
+		m = child
+	}
+	m[key[len(key)-1]] = value
+	return nil
+}
+
+// delete deletes the setting at key and the objects left empty
+func (d *dockerConfig) delete(key []string) {
+	parent, ok := d.get(key[:len(key)-1])
+	if !ok {
+		return
+	}
+	if m, ok := parent.(map[string]interface{}); ok {
+		delete(m, key[len(key)-1])
+		if len(m) == 0 && len(key) > 1 {
+			d.delete(key[:len(key)-1])
+		}
+	}
+}
+
+// change sets the setting at key and records it in the ledger
+func (d *dockerConfig) change(key []string, value interface{}) error {
+	previous, existed := d.get(key)
+	if err := d.set(key, value); err != nil {
+		return err
+	}
+	d.ledger.Record(key, existed, previous, value)
+	return nil
+}
+
+// revert restores the setting at key to its value before amd-ctk changed
+// it, unless it was changed since. It returns false if the ledger has no
+// change for key.
+func (d *dockerConfig) revert(key []string) bool {
+	c := d.ledger.Find(key)
+	if c == nil {
+		return false
 	}
 
-	currentCfg := *d
+	if current, ok := d.get(key); ok && engine.SameValue(current, c.Value) {
+		if c.Existed {
+			_ = d.set(key, c.Previous)
+		} else {
+			d.delete(key)
+		}
+	}
+	d.ledger.Forget(key)
+	return true
+}
 
-	//check any existing "runtimes"
-	runtimes := map[string]interface{}{}
-	if _, exists := currentCfg[runtimesKey]; exists {
-		runtimes = currentCfg[runtimesKey].(map[string]interface{})
+func (d *dockerConfig) ConfigRuntime(name string, path string, isDefault bool) error {
+	if d == nil || d.settings == nil {
+		return fmt.Errorf("configuration is empty")
 	}
 
-	runtimes[name] = map[string]interface{}{
-		"path": path,
-		"args": []string{},
+	// A runtime entry added by amd-ctk belongs to it as a whole, only the
+	// path of an entry written by the administrator is changed. The args
+	// of an existing entry are kept.
+	runtimeKey := []string{runtimesKey, name}
+	if entry, exists := d.get(runtimeKey); !exists {
+		err := d.change(runtimeKey, map[string]interface{}{
+			"path": path,
+			"args": []string{},
+		})
+		if err != nil {
+			return err
+		}
+	} else if _, ok := entry.(map[string]interface{}); !ok {
+		return fmt.Errorf("%q is not an object", strings.Join(runtimeKey, "."))
+	} else if d.ledger.Find(runtimeKey) != nil {
+		_ = d.set(append(runtimeKey, "path"), path)
+	} else if err := d.change(append(runtimeKey, "path"), path); err != nil {
+		return err
 	}
-	currentCfg[runtimesKey] = runtimes
 
-	// Enable CDI by default
-	currentCfg[featuresKey] = map[string]interface{}{
-		"cdi": true,
+	// Enable CDI by default, the other features are kept
+	if err := d.change([]string{featuresKey, cdiFeatureKey}, true); err != nil {
+		return err
 	}
 
 	if isDefault {
-		currentCfg[defaultRuntimeKey] = name
+		if err := d.change([]string{defaultRuntimeKey}, name); err != nil {
+			return err
+		}
 	}
 
-	*d = currentCfg
 	return nil
 }
 
 func (d *dockerConfig) UnsetDefaultRuntime() error {
-	if d == nil {
+	if d == nil || d.settings == nil {
 		return fmt.Errorf("configuration is empty")
 	}
 
-	currentCfg := *d
-
-	delete(currentCfg, defaultRuntimeKey)
+	// Restore the default runtime set before amd-ctk, if any
+	if !d.revert([]string{defaultRuntimeKey}) {
+		delete(d.settings, defaultRuntimeKey)
+	}
 
 	fmt.Println("Removed amd as the default runtime")
 	return nil
 }
 
-// RemoveRuntime removes the amd runtime configuration and returns
+// RemoveRuntime reverts the changes recorded in the ledger and returns
 // an error and a do not update flag in case daemon.json doesn't need
-// to be updated
+// to be updated. Without a ledger, e.g. when configured by an older
+// amd-ctk, the amd runtime, the cdi feature and the default runtime
+// when set to amd are removed.
 func (d *dockerConfig) RemoveRuntime(name string) (error, bool) {
-	_ = os.RemoveAll(defaultCDISpecPath)
-
-	if d == nil {
+	if d == nil || d.settings == nil {
 		return fmt.Errorf("configuration is empty"), true
 	}
 
 	updated := false
-	currentCfg := *d
-
-	//check any existing "runtimes"
-	if _, exists := currentCfg[runtimesKey]; exists {
-		runtimes := currentCfg[runtimesKey].(map[string]interface{})
-		delete(runtimes, name)
-		delete(currentCfg, featuresKey)
-		_, defExists := currentCfg[defaultRuntimeKey]
-		if defExists {
-			defCfg := currentCfg[defaultRuntimeKey].(string)
-			if defCfg == name {
-				delete(currentCfg, defaultRuntimeKey)
-			}
+
+	// A runtime entry added by amd-ctk is removed even if its args were
+	// changed since
+	runtimeKey := []string{runtimesKey, name}
+	if c := d.ledger.Find(runtimeKey); c != nil && !c.Existed {
+		d.delete(runtimeKey)
+		d.ledger.Forget(runtimeKey)
+		updated = true
+	}
+
+	keys := [][]string{
+		append(slices.Clone(runtimeKey), "path"),
+		runtimeKey,
+		{featuresKey, cdiFeatureKey},
+		{defaultRuntimeKey},
+	}
+	for _, key := range keys {
+		if d.revert(key) {
+			updated = true
 		}
-		if len(runtimes) == 0 {
-			delete(currentCfg, runtimesKey)
+	}
+
+	if !updated {
+		if _, exists := d.get(runtimeKey); exists {
+			d.delete(runtimeKey)
+			d.delete([]string{featuresKey, cdiFeatureKey})
+			if def, _ := d.get([]string{defaultRuntimeKey}); def == name {
+				d.delete([]string{defaultRuntimeKey})
+			}
+			updated = true
 		}
-		updated = true
 	}
 
 	if updated {
-		*d = currentCfg
+		d.removeCDISpecs = true
 		return nil, false
 	}
 	return nil, true
 }
 
+func (d dockerConfig) RuntimePath(name string) (string, bool) {
+	entry, ok := d.get([]string{runtimesKey, name})
+	if !ok {
+		return "", false
+	}
+	path, _ := entry.(map[string]interface{})["path"].(string)
+	return path, true
+}
+
+func (d dockerConfig) IsDefaultRuntime(name string) bool {
+	def, _ := d.get([]string{defaultRuntimeKey})
+	return def == name
+}
+
+func (d dockerConfig) IsCDIEnabled() bool {
+	cdi, _ := d.get([]string{featuresKey, cdiFeatureKey})
+	return cdi == true
+}
+
+// Marshal returns daemon.json with the keys in the order and with the
+// indentation of the loaded file
+func (d dockerConfig) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := marshalOrdered(&buf, d.settings, d.order, "", d.indent); err != nil {
+		return nil, fmt.Errorf("json marshal failed: %v", err)
+	}
+	if d.trailingNewline {
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
 func (d dockerConfig) Update(path string) (int, error) {
-	toWrite, err := json.MarshalIndent(d, "", "    ")
+	if d.removeCDISpecs {
+		// Only the specs written by amd-ctk cdi generate are removed, the
+		// specs of the other vendors are kept
+		for _, dir := range d.cdiSpecDirs {
+			removed, skipped, err := cdi.RemoveSpecs(dir)
+			for _, path := range removed {
+				fmt.Printf("Removed CDI spec: %v\n", path)
+			}
+			for _, s := range skipped {
+				fmt.Printf("Not removing CDI spec: %v, %v\n", s.Path, s.Reason)
+			}
+			if err != nil {
+				return 0, fmt.Errorf("failed to remove CDI specs: %v", err)
+			}
+		}
+	}
 
+	toWrite, err := d.Marshal()
 	if err != nil {
-		return 0, fmt.Errorf("json marshal failed: %v", err)
+		return 0, err
 	}
 	if path == "" {
 		num, err := os.Stdout.Write(toWrite)
 		return num, err
 	}
 
-	f, err := os.Create(path)
-	if err != nil {
-		return 0, fmt.Errorf("failed to open file: %v | err: %v", path, err)
+	// The config file and its ledger are backed up with the same stamp,
+	// for --restore to roll both back
+	stamp := engine.NewBackupStamp()
+	if err := engine.WriteFile(path, toWrite, stamp); err != nil {
+		return 0, err
 	}
-	defer f.Close()
-	return f.Write(toWrite)
+	return len(toWrite), d.ledger.Save(path, stamp)
 }
@@ -0,0 +1,225 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ROCm/container-toolkit/internal/cdi"
+)
+
+func loadTestConfig(t *testing.T) (*dockerConfig, []byte) {
+	path := "../../../../../tests/docker/daemon.json"
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	cfg, err := New(path)
+	if err != nil {
+		t.Fatalf("loading %s: %v", path, err)
+	}
+	return cfg, data
+}
+
+func TestMarshalKeepsLayout(t *testing.T) {
+	cfg, data := loadTestConfig(t)
+	out, err := cfg.Marshal()
+	Assert(t, err == nil, fmt.Sprintf("Marshal returned error %v", err))
+	Assert(t, string(out) == string(data), fmt.Sprintf("expected:\n%s\ngot:\n%s", data, out))
+}
+
+func TestMarshalNewKeys(t *testing.T) {
+	cfg, data := loadTestConfig(t)
+	Assert(t, cfg.ConfigRuntime("amd", "amd-container-runtime", true) == nil, "ConfigRuntime failed")
+
+	out, err := cfg.Marshal()
+	Assert(t, err == nil, fmt.Sprintf("Marshal returned error %v", err))
+	expected := string(data[:len(data)-len("  ]\n}\n")]) + `  ],
+  "default-runtime": "amd",
+  "features": {
+    "cdi": true
+  },
+  "runtimes": {
+    "amd": {
+      "args": [],
+      "path": "amd-container-runtime"
+    }
+  }
+}
+`
+	Assert(t, string(out) == expected, fmt.Sprintf("expected:\n%s\ngot:\n%s", expected, out))
+}
+
+func TestMarshalNewFile(t *testing.T) {
+	cfg, err := New(filepath.Join(t.TempDir(), "daemon.json"))
+	Assert(t, err == nil, fmt.Sprintf("New returned error %v", err))
+	Assert(t, cfg.ConfigRuntime("amd", "amd-container-runtime", false) == nil, "ConfigRuntime failed")
+
+	out, err := cfg.Marshal()
+	Assert(t, err == nil, fmt.Sprintf("Marshal returned error %v", err))
+	expected := `{
+    "features": {
+        "cdi": true
+    },
+    "runtimes": {
+        "amd": {
+            "args": [],
+            "path": "amd-container-runtime"
+        }
+    }
+}
+`
+	Assert(t, string(out) == expected, fmt.Sprintf("expected:\n%s\ngot:\n%s", expected, out))
+}
+
+// writeTestConfig writes daemon.json in a temporary directory and loads it
+func writeTestConfig(t *testing.T, content string) (*dockerConfig, string) {
+	path := filepath.Join(t.TempDir(), "daemon.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+	cfg, err := New(path)
+	if err != nil {
+		t.Fatalf("loading %s: %v", path, err)
+	}
+	return cfg, path
+}
+
+// configureAndReload configures the amd runtime, writes daemon.json and
+// its ledger, and loads them back
+func configureAndReload(t *testing.T, cfg *dockerConfig, path string, isDefault bool) *dockerConfig {
+	Assert(t, cfg.ConfigRuntime("amd", "amd-container-runtime", isDefault) == nil, "ConfigRuntime failed")
+	_, err := cfg.Update(path)
+	Assert(t, err == nil, fmt.Sprintf("Update returned error %v", err))
+
+	cfg, err = New(path)
+	if err != nil {
+		t.Fatalf("reloading %s: %v", path, err)
+	}
+	return cfg
+}
+
+func TestConfigRuntimeMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		isDefault bool
+		expected  string
+		removed   string
+	}{
+		{
+			name:     "keep other features",
+			config:   `{"features": {"containerd-snapshotter": true}}`,
+			expected: `{"features":{"cdi":true,"containerd-snapshotter":true},"runtimes":{"amd":{"args":[],"path":"amd-container-runtime"}}}`,
+			removed:  `{"features":{"containerd-snapshotter":true}}`,
+		},
+		{
+			name:     "keep cdi disabled after remove",
+			config:   `{"features": {"cdi": false}}`,
+			expected: `{"features":{"cdi":true},"runtimes":{"amd":{"args":[],"path":"amd-container-runtime"}}}`,
+			removed:  `{"features":{"cdi":false}}`,
+		},
+		{
+			name:     "keep runtime args",
+			config:   `{"runtimes": {"amd": {"path": "/opt/amd/amd-container-runtime", "args": ["--debug"]}}}`,
+			expected: `{"features":{"cdi":true},"runtimes":{"amd":{"args":["--debug"],"path":"amd-container-runtime"}}}`,
+			removed:  `{"runtimes":{"amd":{"args":["--debug"],"path":"/opt/amd/amd-container-runtime"}}}`,
+		},
+		{
+			name:      "restore the default runtime",
+			config:    `{"default-runtime": "runc", "runtimes": {"runc": {"path": "runc"}}}`,
+			isDefault: true,
+			expected:  `{"default-runtime":"amd","features":{"cdi":true},"runtimes":{"amd":{"args":[],"path":"amd-container-runtime"},"runc":{"path":"runc"}}}`,
+			removed:   `{"default-runtime":"runc","runtimes":{"runc":{"path":"runc"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, path := writeTestConfig(t, tt.config)
+			cfg = configureAndReload(t, cfg, path, tt.isDefault)
+			out, _ := json.Marshal(cfg.settings)
+			Assert(t, string(out) == tt.expected, fmt.Sprintf("expected %s, got %s", tt.expected, out))
+
+			err, doNotUpdate := cfg.RemoveRuntime("amd")
+			Assert(t, err == nil && !doNotUpdate, fmt.Sprintf("unexpected RemoveRuntime result %v, %v", err, doNotUpdate))
+			out, _ = json.Marshal(cfg.settings)
+			Assert(t, string(out) == tt.removed, fmt.Sprintf("expected %s, got %s", tt.removed, out))
+			Assert(t, len(cfg.ledger.Changes) == 0, fmt.Sprintf("changes left in the ledger: %+v", cfg.ledger.Changes))
+		})
+	}
+}
+
+func TestRemoveRuntimeKeepsUserChanges(t *testing.T) {
+	cfg, path := writeTestConfig(t, `{"features": {"containerd-snapshotter": true}}`)
+	cfg = configureAndReload(t, cfg, path, true)
+
+	// The administrator adds args to the amd runtime and picks another
+	// default runtime after amd-ctk
+	_ = cfg.set([]string{"runtimes", "amd", "args"}, []string{"--debug"})
+	_ = cfg.set([]string{"default-runtime"}, "runc")
+
+	err, _ := cfg.RemoveRuntime("amd")
+	Assert(t, err == nil, fmt.Sprintf("RemoveRuntime returned error %v", err))
+	out, _ := json.Marshal(cfg.settings)
+	expected := `{"default-runtime":"runc","features":{"containerd-snapshotter":true}}`
+	Assert(t, string(out) == expected, fmt.Sprintf("expected %s, got %s", expected, out))
+}
+
+func TestRemoveRuntimeWithoutLedger(t *testing.T) {
+	cfg, _ := writeTestConfig(t, `{"default-runtime": "amd", "features": {"cdi": true, "containerd-snapshotter": true},
+		"runtimes": {"amd": {"path": "amd-container-runtime", "args": []}}}`)
+
+	err, doNotUpdate := cfg.RemoveRuntime("amd")
+	Assert(t, err == nil && !doNotUpdate, fmt.Sprintf("unexpected RemoveRuntime result %v, %v", err, doNotUpdate))
+	out, _ := json.Marshal(cfg.settings)
+	expected := `{"features":{"containerd-snapshotter":true}}`
+	Assert(t, string(out) == expected, fmt.Sprintf("expected %s, got %s", expected, out))
+
+	err, doNotUpdate = cfg.RemoveRuntime("amd")
+	Assert(t, err == nil && doNotUpdate, "removing a missing runtime should not update daemon.json")
+}
+
+func TestRemoveRuntimeKeepsOtherCDISpecs(t *testing.T) {
+	cdiRoot := t.TempDir()
+	specs := map[string]string{
+		"amd.json":      `{"kind": "amd.com/gpu"}`,
+		"nvidia.yaml":   "kind: nvidia.com/gpu\n",
+		"intel.json":    `{"kind": "intel.com/gpu"}`,
+		"amd-edit.json": `{"kind": "amd.com/gpu"}`,
+	}
+	for name, content := range specs {
+		Assert(t, os.WriteFile(filepath.Join(cdiRoot, name), []byte(content), 0644) == nil, "writing spec failed")
+	}
+	Assert(t, cdi.RecordSpec(filepath.Join(cdiRoot, "amd.json"), []byte(specs["amd.json"])) == nil, "RecordSpec failed")
+	// amd-edit.json was changed since amd-ctk wrote it
+	Assert(t, cdi.RecordSpec(filepath.Join(cdiRoot, "amd-edit.json"), []byte(`{}`)) == nil, "RecordSpec failed")
+
+	cfg, path := writeTestConfig(t, `{}`)
+	cfg = configureAndReload(t, cfg, path, false)
+	cfg.cdiSpecDirs = []string{cdiRoot, filepath.Join(cdiRoot, "missing")}
+
+	err, doNotUpdate := cfg.RemoveRuntime("amd")
+	Assert(t, err == nil && !doNotUpdate, fmt.Sprintf("unexpected RemoveRuntime result %v, %v", err, doNotUpdate))
+
+	// The specs are only removed along with the configuration
+	_, err = os.Stat(filepath.Join(cdiRoot, "amd.json"))
+	Assert(t, err == nil, "amd.json removed before Update")
+	_, err = cfg.Update(path)
+	Assert(t, err == nil, fmt.Sprintf("Update returned error %v", err))
+
+	for name := range specs {
+		_, err := os.Stat(filepath.Join(cdiRoot, name))
+		Assert(t, os.IsNotExist(err) == (name == "amd.json"), fmt.Sprintf("unexpected state of %s: %v", name, err))
+	}
+	_, err = os.Stat(cdi.ManifestPath(cdiRoot))
+	Assert(t, os.IsNotExist(err), "the manifest should be removed once empty")
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
@@ -0,0 +1,213 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Indentation of the files created from scratch
+const defaultIndent = "    "
+
+// keyOrder is the order of the keys of a JSON object and of its nested
+// objects, as found in the loaded file
+type keyOrder struct {
+	keys     []string
+	children map[string]*keyOrder
+}
+
+func (o *keyOrder) child(key string) *keyOrder {
+	if o == nil {
+		return nil
+	}
+	return o.children[key]
+}
+
+// parseKeyOrder reads the key order of the JSON document in data. Array
+// items are recorded as children named after their index.
+func parseKeyOrder(data []byte) (*keyOrder, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	return parseValueOrder(dec, tok)
+}
+
+func parseValueOrder(dec *json.Decoder, tok json.Token) (*keyOrder, error) {
+	delim, ok := tok.(json.Delim)
+	if !ok || (delim != '{' && delim != '[') {
+		return nil, nil
+	}
+
+	order := &keyOrder{children: map[string]*keyOrder{}}
+	for i := 0; dec.More(); i++ {
+		key := strconv.Itoa(i)
+		if delim == '{' {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key = keyTok.(string)
+			order.keys = append(order.keys, key)
+		}
+
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		child, err := parseValueOrder(dec, tok)
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			order.children[key] = child
+		}
+	}
+
+	// closing delimiter
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// detectIndent returns the indentation of the first indented line of a
+// JSON document
+func detectIndent(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) != len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return defaultIndent
+}
+
+// marshalOrdered writes v as indented JSON, with the keys of each object
+// in the order of the loaded file followed by new keys in alphabetical
+// order
+func marshalOrdered(w io.Writer, v interface{}, order *keyOrder, prefix string, indent string) error {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			_, err := io.WriteString(w, "{}")
+			return err
+		}
+
+		keys := []string{}
+		seen := map[string]bool{}
+		if order != nil {
+			for _, k := range order.keys {
+				if _, ok := val[k]; ok && !seen[k] {
+					keys = append(keys, k)
+					seen[k] = true
+				}
+			}
+		}
+		added := []string{}
+		for k := range val {
+			if !seen[k] {
+				added = append(added, k)
+			}
+		}
+		sort.Strings(added)
+		keys = append(keys, added...)
+
+		if _, err := io.WriteString(w, "{\n"); err != nil {
+			return err
+		}
+		for i, k := range keys {
+			name, err := marshalScalar(k)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "%s%s%s: ", prefix, indent, name); err != nil {
+				return err
+			}
+			if err := marshalOrdered(w, val[k], order.child(k), prefix+indent, indent); err != nil {
+				return err
+			}
+			sep := ",\n"
+			if i == len(keys)-1 {
+				sep = "\n"
+			}
+			if _, err := io.WriteString(w, sep); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, prefix+"}")
+		return err
+	case []interface{}:
+		return marshalArray(w, len(val), func(i int) interface{} { return val[i] }, order, prefix, indent)
+	case []string:
+		return marshalArray(w, len(val), func(i int) interface{} { return val[i] }, order, prefix, indent)
+	default:
+		s, err := marshalScalar(v)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, s)
+		return err
+	}
+}
+
+func marshalArray(w io.Writer, n int, item func(int) interface{}, order *keyOrder, prefix string, indent string) error {
+	if n == 0 {
+		_, err := io.WriteString(w, "[]")
+		return err
+	}
+
+	if _, err := io.WriteString(w, "[\n"); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if _, err := io.WriteString(w, prefix+indent); err != nil {
+			return err
+		}
+		if err := marshalOrdered(w, item(i), order.child(strconv.Itoa(i)), prefix+indent, indent); err != nil {
+			return err
+		}
+		sep := ",\n"
+		if i == n-1 {
+			sep = "\n"
+		}
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, prefix+"]")
+	return err
+}
+
+// marshalScalar encodes a JSON scalar without escaping HTML characters
+func marshalScalar(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
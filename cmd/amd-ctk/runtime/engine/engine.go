@@ -20,5 +20,16 @@ type Interface interface {
 	ConfigRuntime(string, string, bool) error
 	UnsetDefaultRuntime() error
 	Update(string) (int, error)
+	Marshal() ([]byte, error)
 	RemoveRuntime(string) (error, bool)
+
+	// Read-only inspection of the loaded configuration
+
+	// RuntimePath returns the executable of a runtime as configured, and
+	// false if the runtime is not registered
+	RuntimePath(string) (string, bool)
+	// IsDefaultRuntime reports whether a runtime is the default runtime
+	IsDefaultRuntime(string) bool
+	// IsCDIEnabled reports whether the engine resolves CDI devices
+	IsCDIEnabled() bool
 }
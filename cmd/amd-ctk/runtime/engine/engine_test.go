@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml"
+)
+
+func TestDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+
+	diff, err := Diff(path, []byte("a = 1\n"))
+	Assert(t, err == nil, fmt.Sprintf("Diff returned error %v", err))
+	expected := fmt.Sprintf("--- %s\n+++ %s\n@@ -0,0 +1 @@\n+a = 1\n", path, path)
+	Assert(t, diff == expected, fmt.Sprintf("expected:\n%s\ngot:\n%s", expected, diff))
+
+	Assert(t, os.WriteFile(path, []byte("a = 1\nb = 2\n"), 0644) == nil, "writing config failed")
+	diff, err = Diff(path, []byte("a = 1\nb = 3"))
+	Assert(t, err == nil, fmt.Sprintf("Diff returned error %v", err))
+	expected = fmt.Sprintf("--- %s\n+++ %s\n@@ -1,2 +1,2 @@\n a = 1\n-b = 2\n+b = 3\n", path, path)
+	Assert(t, diff == expected, fmt.Sprintf("expected:\n%s\ngot:\n%s", expected, diff))
+
+	diff, err = Diff(path, []byte("a = 1\nb = 2\n"))
+	Assert(t, err == nil && diff == "", fmt.Sprintf("unexpected diff %q, %v", diff, err))
+}
+
+func TestMarshalTOML(t *testing.T) {
+	original := `zeta = 1
+alpha = 2
+
+[tables.second]
+  y = true
+
+[tables.first]
+  x = "x"
+`
+	tree, err := toml.Load(original)
+	Assert(t, err == nil, fmt.Sprintf("loading toml: %v", err))
+
+	tree.SetPath([]string{"tables", "new", "z"}, int64(3))
+	tree.SetPath([]string{"tables", "second", "b"}, "b")
+	tree.SetPath([]string{"beta"}, int64(4))
+
+	out, err := MarshalTOML(tree, []byte(original))
+	Assert(t, err == nil, fmt.Sprintf("MarshalTOML returned error %v", err))
+	expected := `zeta = 1
+alpha = 2
+beta = 4
+
+[tables]
+
+  [tables.second]
+    y = true
+    b = "b"
+
+  [tables.first]
+    x = "x"
+
+  [tables.new]
+    z = 3
+`
+	Assert(t, string(out) == expected, fmt.Sprintf("expected:\n%s\ngot:\n%s", expected, out))
+}
+
+func TestResolveRuntimePath(t *testing.T) {
+	lookPath := func(string) (string, error) { return "/opt/amd/bin/amd-container-runtime", nil }
+	Assert(t, ResolveRuntimePath("/usr/bin/amd-container-runtime", lookPath) == "/usr/bin/amd-container-runtime",
+		"absolute paths should be kept")
+	Assert(t, ResolveRuntimePath("amd-container-runtime", lookPath) == "/opt/amd/bin/amd-container-runtime",
+		fmt.Sprintf("unexpected path %v", ResolveRuntimePath("amd-container-runtime", lookPath)))
+
+	lookPath = func(string) (string, error) { return "", fmt.Errorf("not found") }
+	Assert(t, ResolveRuntimePath("amd-container-runtime", lookPath) == "/usr/local/bin/amd-container-runtime",
+		fmt.Sprintf("unexpected fallback path %v", ResolveRuntimePath("amd-container-runtime", lookPath)))
+}
+
+func TestPruneEmptyTables(t *testing.T) {
+	tree, err := toml.Load("[a.b.c]\n[a.d]\nkey = 1\n")
+	Assert(t, err == nil, fmt.Sprintf("loading TOML failed: %v", err))
+
+	PruneEmptyTables(tree, []string{"a", "b", "c"})
+	Assert(t, !tree.Has("a.b") && tree.Has("a.d.key"), fmt.Sprintf("unexpected tree after pruning a.b.c: %v", tree))
+
+	PruneEmptyTables(tree, []string{"a", "d"})
+	Assert(t, tree.Has("a.d.key"), "a table with keys was pruned")
+}
+
+func TestUpdateDropIn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "99-amd.toml")
+	original := []byte("[a]\n  key = 1\n")
+	Assert(t, os.WriteFile(path, original, 0644) == nil, "writing drop-in failed")
+
+	tree, err := toml.LoadBytes(original)
+	Assert(t, err == nil, fmt.Sprintf("loading TOML failed: %v", err))
+	num, err := UpdateDropIn(path, tree, original)
+	Assert(t, err == nil && num == 0, fmt.Sprintf("unchanged drop-in was written: %v, %v", num, err))
+	stamps, _ := ListBackups(path)
+	Assert(t, len(stamps) == 0, fmt.Sprintf("unchanged drop-in was backed up: %v", stamps))
+
+	tree.Set("a.key", int64(2))
+	num, err = UpdateDropIn(path, tree, original)
+	Assert(t, err == nil && num != 0, fmt.Sprintf("changed drop-in was not written: %v, %v", num, err))
+
+	_ = tree.Delete("a")
+	_, err = UpdateDropIn(path, tree, original)
+	Assert(t, err == nil, fmt.Sprintf("UpdateDropIn returned error %v", err))
+	_, err = os.Stat(path)
+	Assert(t, os.IsNotExist(err), "empty drop-in was not removed")
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "etc", "daemon.json")
+
+	// A new file is created with the default mode, and its absence is
+	// backed up
+	Assert(t, WriteFile(path, []byte("v0"), "20250101T000000.000000000") == nil, "WriteFile failed")
+	fi, err := os.Stat(path)
+	Assert(t, err == nil && fi.Mode().Perm() == DEFAULT_FILE_MODE, fmt.Sprintf("unexpected file %v, %v", fi, err))
+	stamps, _ := ListBackups(path)
+	Assert(t, slices.Equal(stamps, []string{"20250101T000000.000000000"}), fmt.Sprintf("unexpected backups %v", stamps))
+	Assert(t, isAbsent(path, stamps[0]), "the absence of the file is not backed up")
+
+	// Rewrites keep the mode and back up the previous content
+	Assert(t, os.Chmod(path, 0600) == nil, "chmod failed")
+	for i := 1; i <= MAX_BACKUPS+2; i++ {
+		stamp := fmt.Sprintf("20250101T00000%d.000000000", i)
+		Assert(t, WriteFile(path, []byte(fmt.Sprintf("v%d", i)), stamp) == nil, "WriteFile failed")
+	}
+	data, _ := os.ReadFile(path)
+	fi, _ = os.Stat(path)
+	Assert(t, string(data) == fmt.Sprintf("v%d", MAX_BACKUPS+2), fmt.Sprintf("unexpected content %s", data))
+	Assert(t, fi.Mode().Perm() == 0600, fmt.Sprintf("the mode is not kept: %v", fi.Mode()))
+
+	stamps, _ = ListBackups(path)
+	Assert(t, len(stamps) == MAX_BACKUPS, fmt.Sprintf("expected %d backups, got %v", MAX_BACKUPS, stamps))
+	data, _ = os.ReadFile(BackupPath(path, stamps[0]))
+	Assert(t, string(data) == "v2", fmt.Sprintf("the oldest backups are not pruned: %s", data))
+
+	// No temporary file is left behind
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	Assert(t, len(entries) == MAX_BACKUPS+1, fmt.Sprintf("unexpected files %v", entries))
+}
+
+func TestBackupDir(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "crio.conf.d", "99-amd.toml")
+	SetBackupDir(filepath.Join(dir, "backups", "crio"))
+	defer SetBackupDir("")
+
+	Assert(t, WriteFile(path, []byte("v0"), "20250101T000000.000000000") == nil, "WriteFile failed")
+	Assert(t, WriteFile(path, []byte("v1"), "20250101T000001.000000000") == nil, "WriteFile failed")
+
+	// The drop-in directory only holds the drop-in
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	Assert(t, len(entries) == 1, fmt.Sprintf("unexpected files %v", entries))
+
+	name := strings.ReplaceAll(strings.TrimPrefix(path, "/"), "/", "%2F")
+	backup := filepath.Join(dir, "backups", "crio", name+".amd-ctk-backup.20250101T000001.000000000")
+	Assert(t, BackupPath(path, "20250101T000001.000000000") == backup, fmt.Sprintf("unexpected backup path %v", BackupPath(path, "20250101T000001.000000000")))
+	data, err := os.ReadFile(backup)
+	Assert(t, err == nil && string(data) == "v0", fmt.Sprintf("unexpected backup %s, %v", data, err))
+
+	restored, err := Restore(path)
+	Assert(t, err == nil && restored == backup, fmt.Sprintf("Restore returned %v, %v", restored, err))
+	data, _ = os.ReadFile(path)
+	Assert(t, string(data) == "v0", fmt.Sprintf("unexpected content %s", data))
+
+	// Files of the same name in different directories have their own
+	// backups
+	other := filepath.Join(dir, "containers.conf.d", "99-amd.toml")
+	Assert(t, WriteFile(other, []byte("other"), "20250101T000002.000000000") == nil, "WriteFile failed")
+	stamps, _ := ListBackups(other)
+	Assert(t, slices.Equal(stamps, []string{"20250101T000002.000000000"}), fmt.Sprintf("unexpected backups %v", stamps))
+}
+
+func TestRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	_, err := Restore(path)
+	Assert(t, err != nil, "restoring a file without backup should fail")
+
+	Assert(t, WriteFile(path, []byte("original"), "20250101T000001.000000000") == nil, "WriteFile failed")
+
+	// The first update creates the ledger
+	ledger := &Ledger{}
+	ledger.Record([]string{"enable_cdi"}, false, nil, true)
+	Assert(t, WriteFile(path, []byte("configured"), "20250101T000002.000000000") == nil, "WriteFile failed")
+	Assert(t, ledger.Save(path, "20250101T000002.000000000") == nil, "saving the ledger failed")
+
+	backup, err := Restore(path)
+	Assert(t, err == nil, fmt.Sprintf("Restore returned error %v", err))
+	Assert(t, backup == BackupPath(path, "20250101T000002.000000000"), fmt.Sprintf("unexpected backup %v", backup))
+	data, _ := os.ReadFile(path)
+	Assert(t, string(data) == "original", fmt.Sprintf("unexpected content %s", data))
+	_, err = os.Stat(LedgerPath(path))
+	Assert(t, os.IsNotExist(err), "the ledger did not exist at the time of the backup")
+
+	// The restored file was backed up, restoring again undoes the restore
+	_, err = Restore(path)
+	Assert(t, err == nil, fmt.Sprintf("Restore returned error %v", err))
+	data, _ = os.ReadFile(path)
+	Assert(t, string(data) == "configured", fmt.Sprintf("unexpected content %s", data))
+	_, err = os.Stat(LedgerPath(path))
+	Assert(t, err == nil, "the ledger is not restored")
+
+	// Removed files are backed up too
+	Assert(t, RemoveFile(path, NewBackupStamp()) == nil, "RemoveFile failed")
+	_, err = Restore(path)
+	Assert(t, err == nil, fmt.Sprintf("Restore returned error %v", err))
+	data, _ = os.ReadFile(path)
+	Assert(t, string(data) == "configured", fmt.Sprintf("unexpected content %s", data))
+}
+
+func TestRestoreCreatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crio.conf.d", "99-amd.toml")
+	Assert(t, WriteFile(path, []byte("created"), "20250101T000001.000000000") == nil, "WriteFile failed")
+
+	// The file did not exist before amd-ctk, restoring removes it
+	backup, err := Restore(path)
+	Assert(t, err == nil, fmt.Sprintf("Restore returned error %v", err))
+	Assert(t, strings.HasSuffix(backup, BACKUP_ABSENT_SUFFIX), fmt.Sprintf("unexpected backup %v", backup))
+	_, err = os.Stat(path)
+	Assert(t, os.IsNotExist(err), "the created file was not removed")
+
+	// The removed file was backed up
+	_, err = Restore(path)
+	Assert(t, err == nil, fmt.Sprintf("Restore returned error %v", err))
+	data, _ := os.ReadFile(path)
+	Assert(t, string(data) == "created", fmt.Sprintf("unexpected content %s", data))
+}
+
+func TestHookDescriptor(t *testing.T) {
+	path := HookDescriptorPath(filepath.Join(t.TempDir(), "hooks.d"))
+	written, err := WriteHookDescriptor(path, "/usr/local/bin/amd-container-runtime-hook")
+	Assert(t, err == nil && written, fmt.Sprintf("WriteHookDescriptor returned %v, %v", written, err))
+
+	data, err := os.ReadFile(path)
+	Assert(t, err == nil, fmt.Sprintf("reading the descriptor failed: %v", err))
+	expected := `{
+  "version": "1.0.0",
+  "hook": {
+    "path": "/usr/local/bin/amd-container-runtime-hook",
+    "args": [
+      "amd-container-runtime-hook",
+      "--engine-hook"
+    ]
+  },
+  "when": {
+    "always": true
+  },
+  "stages": [
+    "createRuntime",
+    "poststop"
+  ]
+}
+`
+	Assert(t, string(data) == expected, fmt.Sprintf("expected:\n%s\ngot:\n%s", expected, data))
+
+	// An up to date descriptor is not rewritten
+	written, err = WriteHookDescriptor(path, "/usr/local/bin/amd-container-runtime-hook")
+	Assert(t, err == nil && !written, fmt.Sprintf("WriteHookDescriptor returned %v, %v", written, err))
+
+	Assert(t, RemoveHookDescriptor(path) == nil, "RemoveHookDescriptor failed")
+	_, err = os.Stat(path)
+	Assert(t, os.IsNotExist(err), "the descriptor was not removed")
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
@@ -0,0 +1,77 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package engines
+
+import (
+	"fmt"
+
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine/containerd"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine/crio"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine/docker"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine/podman"
+)
+
+const (
+	defaultDockerConfigFilePath     = "/etc/docker/daemon.json"
+	defaultContainerdConfigFilePath = "/etc/containerd/config.toml"
+	defaultCrioConfigFilePath       = "/etc/crio/crio.conf.d/99-amd.toml"
+)
+
+// Names of the supported container engines
+var Names = []string{"docker", "containerd", "crio", "podman"}
+
+// Default configuration file of each supported container engine
+var defaultConfigFilePaths = map[string]string{
+	"docker":     defaultDockerConfigFilePath,
+	"containerd": defaultContainerdConfigFilePath,
+	"crio":       defaultCrioConfigFilePath,
+	"podman":     podman.DefaultConfigPath(),
+}
+
+// DefaultConfigPath returns the default configuration file of a container
+// engine, and false if the engine is not supported
+func DefaultConfigPath(name string) (string, bool) {
+	path, ok := defaultConfigFilePaths[name]
+	return path, ok
+}
+
+// New loads the configuration file of a container engine
+func New(name string, path string) (engine.Interface, error) {
+	var (
+		runtimeEngine engine.Interface
+		err           error
+	)
+
+	switch name {
+	case "docker":
+		runtimeEngine, err = docker.New(path)
+	case "containerd":
+		runtimeEngine, err = containerd.New(path)
+	case "crio":
+		runtimeEngine, err = crio.New(path)
+	case "podman":
+		runtimeEngine, err = podman.New(path)
+	default:
+		return nil, fmt.Errorf("unsupported runtime engine: %v", name)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return runtimeEngine, nil
+}
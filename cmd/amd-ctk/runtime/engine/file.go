@@ -0,0 +1,354 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Constants
+const (
+	// Infix of the backups of a configuration file, followed by the time
+	// of the backup, e.g. daemon.json.amd-ctk-backup.20250102T150405.000000000
+	BACKUP_INFIX = ".amd-ctk-backup."
+
+	// Layout of the time of the backups, which sorts lexically
+	BACKUP_TIME_LAYOUT = "20060102T150405.000000000"
+
+	// Suffix of the empty backups recording that a file did not exist, e.g.
+	// 99-amd.toml.amd-ctk-backup.20250102T150405.000000000.absent
+	BACKUP_ABSENT_SUFFIX = ".absent"
+
+	// Number of backups kept for each configuration file
+	MAX_BACKUPS = 5
+
+	// Mode of the configuration files created from scratch
+	DEFAULT_FILE_MODE = 0644
+)
+
+// backupDir is the directory of the backups, set through SetBackupDir
+var backupDir string
+
+// SetBackupDir sets the directory the backups are kept in. Without it,
+// the backups are kept next to the backed up files.
+func SetBackupDir(dir string) {
+	backupDir = dir
+}
+
+// NewBackupStamp returns the stamp of the backups of an update. The
+// configuration file and its ledger are backed up with the same stamp.
+func NewBackupStamp() string {
+	return time.Now().UTC().Format(BACKUP_TIME_LAYOUT)
+}
+
+// BackupPath returns the path of the backup of a file
+func BackupPath(path string, stamp string) string {
+	if backupDir == "" {
+		return path + BACKUP_INFIX + stamp
+	}
+	return filepath.Join(backupDir, backupName(path)) + BACKUP_INFIX + stamp
+}
+
+// backupName returns the name of the backups of a file in the backup
+// directory: its absolute path with the separators escaped, so that files
+// of the same name in different directories do not share their backups
+func backupName(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	r := strings.NewReplacer("%", "%25", "/", "%2F")
+	return r.Replace(strings.TrimPrefix(path, "/"))
+}
+
+// ListBackups returns the stamps of the backups of a file, oldest first
+func ListBackups(path string) ([]string, error) {
+	prefix := BackupPath(path, "")
+	matches, err := filepath.Glob(globEscape(prefix) + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	stamps := []string{}
+	for _, m := range matches {
+		stamps = append(stamps, strings.TrimSuffix(strings.TrimPrefix(m, prefix), BACKUP_ABSENT_SUFFIX))
+	}
+	sort.Strings(stamps)
+	return stamps, nil
+}
+
+// WriteFile atomically replaces the content of a file. The current file
+// is first backed up with stamp, and its mode and owner are kept. A file
+// created from scratch gets an absent backup instead. The new content is
+// written to a temporary file of the same directory, synced, and renamed
+// over the file.
+func WriteFile(path string, data []byte, stamp string) error {
+	mode := os.FileMode(DEFAULT_FILE_MODE)
+	uid, gid := -1, -1
+
+	fi, err := os.Stat(path)
+	switch {
+	case err == nil:
+		mode = fi.Mode().Perm()
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(st.Uid), int(st.Gid)
+		}
+		if err := backupFile(path, stamp); err != nil {
+			return err
+		}
+	case os.IsNotExist(err):
+		if err := backupAbsent(path, stamp); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory of: %v | err: %v", path, err)
+		}
+	default:
+		return fmt.Errorf("failed to stat file: %v | err: %v", path, err)
+	}
+
+	return writeAtomic(path, data, mode, uid, gid)
+}
+
+// RemoveFile removes a file after backing it up with stamp
+func RemoveFile(path string, stamp string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	if err := backupFile(path, stamp); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove file: %v | err: %v", path, err)
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// Restore rolls a configuration file and its ledger back to their last
+// backup, and returns the path of the backup. The file and its ledger are
+// removed if they did not exist at the time of the backup. They are backed
+// up first, so that the next Restore undoes this one.
+func Restore(path string) (string, error) {
+	stamps, err := ListBackups(path)
+	if err != nil {
+		return "", err
+	}
+	if len(stamps) == 0 {
+		return "", fmt.Errorf("no backup of %v found", path)
+	}
+	stamp := stamps[len(stamps)-1]
+
+	current := NewBackupStamp()
+	for _, p := range []string{path, LedgerPath(path)} {
+		if err := backupCurrent(p, current); err != nil {
+			return "", err
+		}
+	}
+
+	if err := restoreFile(path, stamp); err != nil {
+		return "", err
+	}
+
+	ledgerPath := LedgerPath(path)
+	if hasBackup(ledgerPath, stamp) {
+		if err := restoreFile(ledgerPath, stamp); err != nil {
+			return "", err
+		}
+	} else if err := os.Remove(ledgerPath); err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to remove ledger file: %v | err: %v", ledgerPath, err)
+	}
+
+	if isAbsent(path, stamp) {
+		return absentPath(path, stamp), nil
+	}
+	return BackupPath(path, stamp), nil
+}
+
+// absentPath returns the path of the backup recording that a file did not
+// exist
+func absentPath(path string, stamp string) string {
+	return BackupPath(path, stamp) + BACKUP_ABSENT_SUFFIX
+}
+
+// isAbsent returns true if the backup with stamp records that the file did
+// not exist
+func isAbsent(path string, stamp string) bool {
+	_, err := os.Stat(absentPath(path, stamp))
+	return err == nil
+}
+
+// hasBackup returns true if the file has a backup with stamp, either of
+// its content or of its absence
+func hasBackup(path string, stamp string) bool {
+	if _, err := os.Stat(BackupPath(path, stamp)); err == nil {
+		return true
+	}
+	return isAbsent(path, stamp)
+}
+
+// backupCurrent backs up a file with stamp, or records its absence
+func backupCurrent(path string, stamp string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return backupAbsent(path, stamp)
+	}
+	return backupFile(path, stamp)
+}
+
+// restoreFile atomically replaces a file with its backup, keeping the
+// mode and owner of the backup, or removes the file if it did not exist
+func restoreFile(path string, stamp string) error {
+	if isAbsent(path, stamp) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove file: %v | err: %v", path, err)
+		}
+		syncDir(filepath.Dir(path))
+		return nil
+	}
+
+	backup := BackupPath(path, stamp)
+	data, err := os.ReadFile(backup)
+	if err != nil {
+		return fmt.Errorf("error reading backup file: %v | err: %v", backup, err)
+	}
+	fi, err := os.Stat(backup)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %v | err: %v", backup, err)
+	}
+
+	uid, gid := -1, -1
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		uid, gid = int(st.Uid), int(st.Gid)
+	}
+	return writeAtomic(path, data, fi.Mode().Perm(), uid, gid)
+}
+
+// backupFile copies a file to its backup with stamp, keeping its mode and
+// owner, and deletes the oldest backups beyond MAX_BACKUPS
+func backupFile(path string, stamp string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v | err: %v", path, err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %v | err: %v", path, err)
+	}
+
+	uid, gid := -1, -1
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		uid, gid = int(st.Uid), int(st.Gid)
+	}
+	backup := BackupPath(path, stamp)
+	if err := os.MkdirAll(filepath.Dir(backup), 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory of: %v | err: %v", path, err)
+	}
+	if err := writeAtomic(backup, data, fi.Mode().Perm(), uid, gid); err != nil {
+		return fmt.Errorf("failed to back up file: %v | err: %v", path, err)
+	}
+
+	return pruneBackups(path)
+}
+
+// backupAbsent records with stamp that a file does not exist, so that
+// restoring the backup removes the file
+func backupAbsent(path string, stamp string) error {
+	absent := absentPath(path, stamp)
+	if err := os.MkdirAll(filepath.Dir(absent), 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory of: %v | err: %v", path, err)
+	}
+	if err := writeAtomic(absent, []byte{}, 0600, -1, -1); err != nil {
+		return fmt.Errorf("failed to back up file: %v | err: %v", path, err)
+	}
+
+	return pruneBackups(path)
+}
+
+// pruneBackups deletes the oldest backups of a file beyond MAX_BACKUPS
+func pruneBackups(path string) error {
+	stamps, err := ListBackups(path)
+	if err != nil {
+		return err
+	}
+	for len(stamps) > MAX_BACKUPS {
+		_ = os.Remove(BackupPath(path, stamps[0]))
+		_ = os.Remove(absentPath(path, stamps[0]))
+		stamps = stamps[1:]
+	}
+	return nil
+}
+
+// writeAtomic writes data to a temporary file next to path and renames it
+// over path. uid and gid are left unchanged when negative.
+func writeAtomic(path string, data []byte, mode os.FileMode, uid int, gid int) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for: %v | err: %v", path, err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %v | err: %v", tmpPath, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync file: %v | err: %v", tmpPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %v | err: %v", tmpPath, err)
+	}
+
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return fmt.Errorf("failed to set the mode of: %v | err: %v", tmpPath, err)
+	}
+	if uid >= 0 && (uid != os.Getuid() || gid != os.Getgid()) {
+		if err := os.Chown(tmpPath, uid, gid); err != nil {
+			return fmt.Errorf("failed to set the owner of: %v | err: %v", tmpPath, err)
+		}
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to rename %v to %v | err: %v", tmpPath, path, err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes the entries of a directory, so that a rename survives
+// a crash
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync()
+}
+
+// globEscape escapes the glob metacharacters of a path
+func globEscape(path string) string {
+	r := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`)
+	return r.Replace(path)
+}
@@ -0,0 +1,100 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ROCm/container-toolkit/internal/hook"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Constants
+const (
+	// Directory of the OCI hooks descriptors read by podman and CRI-O
+	HOOKS_DIR = "/usr/share/containers/oci/hooks.d"
+
+	// Name of the descriptor of amd-container-runtime-hook
+	HOOK_DESCRIPTOR_NAME = "amd-container-runtime-hook.json"
+
+	// Version of the OCI hooks descriptor schema
+	hookDescriptorVersion = "1.0.0"
+)
+
+// hookDescriptor is an OCI hooks descriptor, which tells podman and
+// CRI-O to run a hook for the containers it applies to
+type hookDescriptor struct {
+	Version string     `json:"version"`
+	Hook    specs.Hook `json:"hook"`
+	When    hookWhen   `json:"when"`
+	Stages  []string   `json:"stages"`
+}
+
+// hookWhen holds the conditions for the hook to run
+type hookWhen struct {
+	Always bool `json:"always"`
+}
+
+// HookDescriptorPath returns the path of the descriptor of
+// amd-container-runtime-hook in dir
+func HookDescriptorPath(dir string) string {
+	return filepath.Join(dir, HOOK_DESCRIPTOR_NAME)
+}
+
+// MarshalHookDescriptor returns the descriptor of the hook at hookPath.
+// Descriptors cannot match environment variables, so the hook runs for
+// every container and skips the ones not requesting GPUs.
+func MarshalHookDescriptor(hookPath string) ([]byte, error) {
+	descriptor := hookDescriptor{
+		Version: hookDescriptorVersion,
+		Hook: specs.Hook{
+			Path: hookPath,
+			Args: []string{filepath.Base(hookPath), hook.ENGINE_HOOK_ARG},
+		},
+		When:   hookWhen{Always: true},
+		Stages: []string{"createRuntime", "poststop"},
+	}
+
+	data, err := json.MarshalIndent(descriptor, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the hook descriptor | err: %v", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// WriteHookDescriptor writes the descriptor of the hook at hookPath to
+// path, backing up the current descriptor if any. It returns false if the
+// descriptor is already up to date.
+func WriteHookDescriptor(path string, hookPath string) (bool, error) {
+	data, err := MarshalHookDescriptor(hookPath)
+	if err != nil {
+		return false, err
+	}
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return false, nil
+	}
+	return true, WriteFile(path, data, NewBackupStamp())
+}
+
+// RemoveHookDescriptor removes the descriptor at path after backing it up
+func RemoveHookDescriptor(path string) error {
+	return RemoveFile(path, NewBackupStamp())
+}
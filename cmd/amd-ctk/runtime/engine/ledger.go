@@ -0,0 +1,132 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// Suffix of the ledger file kept next to the engine configuration file
+const LEDGER_SUFFIX = ".amd-ctk.json"
+
+// Change is a setting of an engine configuration file changed by
+// amd-ctk, along with the value it had before
+type Change struct {
+	// Key is the path of the setting in the configuration file
+	Key []string `json:"key"`
+
+	// Existed is false when the setting was added by amd-ctk
+	Existed bool `json:"existed"`
+
+	// Previous is the value before the first change by amd-ctk
+	Previous interface{} `json:"previous,omitempty"`
+
+	// Value is the value set by amd-ctk
+	Value interface{} `json:"value"`
+}
+
+// Ledger records the changes made by amd-ctk to an engine configuration
+// file, so that they can be reverted without touching the settings of
+// the administrator
+type Ledger struct {
+	Changes []Change `json:"changes"`
+}
+
+// LedgerPath returns the path of the ledger of a configuration file
+func LedgerPath(configPath string) string {
+	return configPath + LEDGER_SUFFIX
+}
+
+// LoadLedger reads the ledger of a configuration file. It returns a nil
+// ledger if amd-ctk has not recorded any change to the file.
+func LoadLedger(configPath string) (*Ledger, error) {
+	data, err := os.ReadFile(LedgerPath(configPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading ledger file: %v | err: %v", LedgerPath(configPath), err)
+	}
+
+	ledger := &Ledger{}
+	if err := json.Unmarshal(data, ledger); err != nil {
+		return nil, fmt.Errorf("error decoding ledger file: %v | err: %v", LedgerPath(configPath), err)
+	}
+	return ledger, nil
+}
+
+// Save writes the ledger next to the configuration file, or deletes it
+// once no change is left to revert. The current ledger is backed up with
+// stamp, the stamp of the backup of the configuration file.
+func (l *Ledger) Save(configPath string, stamp string) error {
+	path := LedgerPath(configPath)
+	if l == nil || len(l.Changes) == 0 {
+		return RemoveFile(path, stamp)
+	}
+
+	data, err := json.MarshalIndent(l, "", "    ")
+	if err != nil {
+		return fmt.Errorf("json marshal failed: %v", err)
+	}
+	return WriteFile(path, append(data, '\n'), stamp)
+}
+
+// Find returns the recorded change of a setting, or nil
+func (l *Ledger) Find(key []string) *Change {
+	if l == nil {
+		return nil
+	}
+	for i := range l.Changes {
+		if slices.Equal(l.Changes[i].Key, key) {
+			return &l.Changes[i]
+		}
+	}
+	return nil
+}
+
+// Record adds the change of a setting to the ledger. The previous value
+// of an already recorded setting is kept, it is the one to restore.
+func (l *Ledger) Record(key []string, existed bool, previous interface{}, value interface{}) {
+	if c := l.Find(key); c != nil {
+		c.Value = value
+		return
+	}
+	if !existed {
+		previous = nil
+	}
+	l.Changes = append(l.Changes, Change{Key: slices.Clone(key), Existed: existed, Previous: previous, Value: value})
+}
+
+// Forget removes the change of a setting from the ledger
+func (l *Ledger) Forget(key []string) {
+	if l == nil {
+		return
+	}
+	l.Changes = slices.DeleteFunc(l.Changes, func(c Change) bool { return slices.Equal(c.Key, key) })
+}
+
+// SameValue reports whether two setting values are equal once encoded,
+// which ignores the differences of types between the decoders
+func SameValue(a interface{}, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
@@ -0,0 +1,214 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package podman
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine"
+	"github.com/pelletier/go-toml"
+)
+
+const (
+	engineKey         = "engine"
+	runtimesKey       = "runtimes"
+	defaultRuntimeKey = "runtime"
+
+	// Name of the drop-in file written by amd-ctk
+	dropInName = "99-amd.conf"
+
+	// Drop-in directory of the system wide containers.conf
+	systemConfigDir = "/etc/containers/containers.conf.d"
+
+	// Drop-in directory of the per-user containers.conf, relative to
+	// $XDG_CONFIG_HOME
+	userConfigDir = "containers/containers.conf.d"
+
+	// Name of the runtime registered by amd-ctk
+	amdRuntimeName = "amd"
+)
+
+// DefaultConfigPath returns the path of the containers.conf drop-in, in
+// the per-user configuration when running rootless
+func DefaultConfigPath() string {
+	return configPath(os.Geteuid() != 0)
+}
+
+func configPath(rootless bool) string {
+	if !rootless {
+		return filepath.Join(systemConfigDir, dropInName)
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return filepath.Join(systemConfigDir, dropInName)
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, userConfigDir, dropInName)
+}
+
+// podmanConfig is a containers.conf drop-in configuration file, owned by
+// amd-ctk, which is merged by podman on top of containers.conf
+type podmanConfig struct {
+	tree *toml.Tree
+
+	// original is the content of the loaded file
+	original []byte
+
+	// lookPath resolves the runtime executable to an absolute path
+	lookPath func(string) (string, error)
+}
+
+func New(path string) (*podmanConfig, error) {
+	return loadConfigFile(path)
+}
+
+func loadConfigFile(path string) (*podmanConfig, error) {
+	config := &podmanConfig{lookPath: exec.LookPath}
+
+	//check if the file exists
+	f, err := os.Stat(path)
+	if err == nil && f.IsDir() {
+		return nil, fmt.Errorf("file path is a directory")
+	}
+
+	if os.IsNotExist(err) {
+		// return empty config
+		config.tree, err = toml.TreeFromMap(map[string]interface{}{})
+		if err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+
+	readB, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading configuration file: %v | err: %v", path, err)
+	}
+
+	config.tree, err = toml.LoadBytes(readB)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding configuration file: %v | err: %v", path, err)
+	}
+	config.original = readB
+
+	return config, nil
+}
+
+func (p *podmanConfig) ConfigRuntime(name string, path string, isDefault bool) error {
+	if p == nil || p.tree == nil {
+		return fmt.Errorf("configuration is empty")
+	}
+
+	// Podman picks the first existing path of the runtime entry
+	p.tree.SetPath([]string{engineKey, runtimesKey, name}, []string{engine.ResolveRuntimePath(path, p.lookPath)})
+
+	if isDefault {
+		p.tree.SetPath([]string{engineKey, defaultRuntimeKey}, name)
+	}
+
+	return nil
+}
+
+// UnsetDefaultRuntime removes engine.runtime from the drop-in when it is
+// the amd runtime, and leaves any other default runtime alone
+func (p *podmanConfig) UnsetDefaultRuntime() error {
+	if p == nil || p.tree == nil {
+		return fmt.Errorf("configuration is empty")
+	}
+
+	if !p.IsDefaultRuntime(amdRuntimeName) {
+		return nil
+	}
+
+	_ = p.tree.DeletePath([]string{engineKey, defaultRuntimeKey})
+	engine.PruneEmptyTables(p.tree, []string{engineKey})
+
+	fmt.Println("Removed amd as the default runtime")
+	return nil
+}
+
+// RemoveRuntime removes the amd runtime configuration and returns
+// an error and a do not update flag in case the drop-in doesn't need
+// to be updated
+func (p *podmanConfig) RemoveRuntime(name string) (error, bool) {
+	if p == nil || p.tree == nil {
+		return fmt.Errorf("configuration is empty"), true
+	}
+
+	if !p.tree.HasPath([]string{engineKey, runtimesKey, name}) {
+		return nil, true
+	}
+
+	_ = p.tree.DeletePath([]string{engineKey, runtimesKey, name})
+	if def, ok := p.tree.GetPath([]string{engineKey, defaultRuntimeKey}).(string); ok && def == name {
+		_ = p.tree.DeletePath([]string{engineKey, defaultRuntimeKey})
+	}
+
+	engine.PruneEmptyTables(p.tree, []string{engineKey, runtimesKey})
+	return nil, false
+}
+
+// RuntimePath returns the first path listed for a runtime, podman uses
+// the first one found on the host
+func (p podmanConfig) RuntimePath(name string) (string, bool) {
+	key := []string{engineKey, runtimesKey, name}
+	if !p.tree.HasPath(key) {
+		return "", false
+	}
+
+	// Loaded arrays are []interface{}, arrays set by ConfigRuntime []string
+	path := ""
+	switch paths := p.tree.GetPath(key).(type) {
+	case []interface{}:
+		if len(paths) > 0 {
+			path, _ = paths[0].(string)
+		}
+	case []string:
+		if len(paths) > 0 {
+			path = paths[0]
+		}
+	}
+	return path, true
+}
+
+func (p podmanConfig) IsDefaultRuntime(name string) bool {
+	def, _ := p.tree.GetPath([]string{engineKey, defaultRuntimeKey}).(string)
+	return def == name
+}
+
+// IsCDIEnabled returns true, podman always resolves CDI devices
+func (p podmanConfig) IsCDIEnabled() bool {
+	return true
+}
+
+// Marshal returns the drop-in with the keys in the order of the loaded
+// file, empty once it holds no settings
+func (p podmanConfig) Marshal() ([]byte, error) {
+	return engine.MarshalTOML(p.tree, p.original)
+}
+
+// Update writes the drop-in, or deletes it once it holds no settings
+func (p podmanConfig) Update(path string) (int, error) {
+	return engine.UpdateDropIn(path, p.tree, p.original)
+}
@@ -0,0 +1,85 @@
+package podman
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/pelletier/go-toml"
+)
+
+func TestConfigPath(t *testing.T) {
+	Assert(t, configPath(false) == "/etc/containers/containers.conf.d/99-amd.conf",
+		fmt.Sprintf("unexpected system path %v", configPath(false)))
+
+	t.Setenv("XDG_CONFIG_HOME", "/home/user/.config")
+	Assert(t, configPath(true) == "/home/user/.config/containers/containers.conf.d/99-amd.conf",
+		fmt.Sprintf("unexpected XDG path %v", configPath(true)))
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "/home/user")
+	Assert(t, configPath(true) == "/home/user/.config/containers/containers.conf.d/99-amd.conf",
+		fmt.Sprintf("unexpected user path %v", configPath(true)))
+}
+
+func TestConfigRuntime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "containers.conf.d", "99-amd.conf")
+	cfg, err := New(path)
+	Assert(t, err == nil, fmt.Sprintf("loading %s: %v", path, err))
+	cfg.lookPath = func(string) (string, error) { return "/opt/amd/bin/amd-container-runtime", nil }
+
+	Assert(t, cfg.ConfigRuntime("amd", "amd-container-runtime", true) == nil, "ConfigRuntime failed")
+	runtimePath, registered := cfg.RuntimePath("amd")
+	Assert(t, registered && runtimePath == "/opt/amd/bin/amd-container-runtime", fmt.Sprintf("unexpected runtime path %v, %v", runtimePath, registered))
+	_, err = cfg.Update(path)
+	Assert(t, err == nil, fmt.Sprintf("Update returned error %v", err))
+
+	tree, err := toml.LoadFile(path)
+	Assert(t, err == nil, fmt.Sprintf("loading %s: %v", path, err))
+	Assert(t, tree.Get("engine.runtime") == "amd", fmt.Sprintf("unexpected default runtime %v", tree.Get("engine.runtime")))
+	paths, _ := tree.Get("engine.runtimes.amd").([]interface{})
+	Assert(t, slices.Equal(paths, []interface{}{"/opt/amd/bin/amd-container-runtime"}), fmt.Sprintf("unexpected runtime paths %v", paths))
+
+	cfg, err = New(path)
+	Assert(t, err == nil, fmt.Sprintf("reloading %s: %v", path, err))
+	runtimePath, registered = cfg.RuntimePath("amd")
+	Assert(t, registered && runtimePath == "/opt/amd/bin/amd-container-runtime", fmt.Sprintf("unexpected runtime path %v, %v", runtimePath, registered))
+	Assert(t, cfg.IsDefaultRuntime("amd") && cfg.IsCDIEnabled(), "amd should be the default runtime")
+	Assert(t, cfg.UnsetDefaultRuntime() == nil, "UnsetDefaultRuntime failed")
+	Assert(t, !cfg.IsDefaultRuntime("amd"), "amd is still the default runtime")
+	Assert(t, !cfg.tree.Has("engine.runtime"), "default runtime is still set")
+
+	err, doNotUpdate := cfg.RemoveRuntime("amd")
+	Assert(t, err == nil && !doNotUpdate, fmt.Sprintf("unexpected RemoveRuntime result %v, %v", err, doNotUpdate))
+	_, err = cfg.Update(path)
+	Assert(t, err == nil, fmt.Sprintf("Update returned error %v", err))
+	_, err = os.Stat(path)
+	Assert(t, os.IsNotExist(err), "the empty drop-in should be removed")
+
+	err, doNotUpdate = cfg.RemoveRuntime("amd")
+	Assert(t, err == nil && doNotUpdate, "removing a missing runtime should not update the drop-in")
+}
+
+func TestUnsetOtherDefaultRuntime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "99-amd.conf")
+	content := []byte("[engine]\nruntime = \"crun\"\n")
+	Assert(t, os.WriteFile(path, content, 0644) == nil, "failed to write the drop-in")
+
+	cfg, err := New(path)
+	Assert(t, err == nil, fmt.Sprintf("loading %s: %v", path, err))
+	Assert(t, cfg.UnsetDefaultRuntime() == nil, "UnsetDefaultRuntime failed")
+	Assert(t, cfg.IsDefaultRuntime("crun"), "the default runtime crun was removed")
+
+	num, err := cfg.Update(path)
+	Assert(t, err == nil && num == 0, fmt.Sprintf("Update returned %v, %v", num, err))
+	data, _ := os.ReadFile(path)
+	Assert(t, string(data) == string(content), fmt.Sprintf("the drop-in was changed to %q", data))
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
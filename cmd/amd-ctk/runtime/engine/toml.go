@@ -0,0 +1,185 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pelletier/go-toml"
+)
+
+// Fallback directory of the runtime executable when it is not found in PATH
+const DEFAULT_RUNTIME_DIR = "/usr/local/bin"
+
+// ResolveRuntimePath returns the absolute path of the runtime executable,
+// looked up with lookPath unless already absolute
+func ResolveRuntimePath(path string, lookPath func(string) (string, error)) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	if p, err := lookPath(path); err == nil {
+		if abs, err := filepath.Abs(p); err == nil {
+			return abs
+		}
+	}
+	return filepath.Join(DEFAULT_RUNTIME_DIR, path)
+}
+
+// PruneEmptyTables deletes the table of tree at path and its parents as
+// long as they are empty
+func PruneEmptyTables(tree *toml.Tree, path []string) {
+	for i := len(path); i > 0; i-- {
+		t, ok := tree.GetPath(path[:i]).(*toml.Tree)
+		if !ok || len(t.Keys()) != 0 {
+			return
+		}
+		_ = tree.DeletePath(path[:i])
+	}
+}
+
+// UpdateDropIn writes the drop-in of tree loaded from original to path,
+// or to stdout if path is empty. The drop-in is deleted once it holds no
+// settings, and left alone if its settings did not change.
+func UpdateDropIn(path string, tree *toml.Tree, original []byte) (int, error) {
+	toWrite, err := MarshalTOML(tree, original)
+	if err != nil {
+		return 0, err
+	}
+
+	if path == "" {
+		return os.Stdout.Write(toWrite)
+	}
+
+	if isUnchanged(toWrite, original) {
+		return 0, nil
+	}
+
+	stamp := NewBackupStamp()
+	if len(tree.Keys()) == 0 {
+		if err := RemoveFile(path, stamp); err != nil {
+			return 0, err
+		}
+		fmt.Printf("Removed the config file: %v\n", path)
+		return 0, nil
+	}
+
+	if err := WriteFile(path, toWrite, stamp); err != nil {
+		return 0, err
+	}
+	return len(toWrite), nil
+}
+
+// isUnchanged returns true if content is the loaded file original, as
+// marshaled by MarshalTOML
+func isUnchanged(content []byte, original []byte) bool {
+	if original == nil {
+		return false
+	}
+	tree, err := toml.LoadBytes(original)
+	if err != nil {
+		return false
+	}
+	marshaled, err := MarshalTOML(tree, original)
+	return err == nil && bytes.Equal(marshaled, content)
+}
+
+// MarshalTOML returns the TOML document of tree with the keys in the
+// order of original, the content of the loaded file. Keys added since
+// are written after the existing keys of their table.
+func MarshalTOML(tree *toml.Tree, original []byte) ([]byte, error) {
+	var ref *toml.Tree
+	if len(original) != 0 {
+		var err error
+		if ref, err = toml.LoadBytes(original); err != nil {
+			return nil, fmt.Errorf("toml unmarshal failed: %v", err)
+		}
+	}
+	orderKeys(tree, ref)
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Order(toml.OrderPreserve).Encode(tree); err != nil {
+		return nil, fmt.Errorf("toml marshal failed: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// orderKeys renumbers the positions of the keys of tree, which the
+// encoder sorts on, after the positions of the same keys in ref. New
+// keys come last and values always come before tables.
+func orderKeys(tree *toml.Tree, ref *toml.Tree) {
+	type entry struct {
+		key     string
+		line    int
+		isTable bool
+	}
+
+	entries := []entry{}
+	for _, key := range tree.Keys() {
+		e := entry{key: key, line: int(^uint(0) >> 1)}
+		if ref != nil && ref.HasPath([]string{key}) {
+			e.line = ref.GetPositionPath([]string{key}).Line
+		}
+		switch tree.GetPath([]string{key}).(type) {
+		case *toml.Tree, []*toml.Tree:
+			e.isTable = true
+		}
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].isTable != entries[j].isTable {
+			return !entries[i].isTable
+		}
+		if entries[i].line != entries[j].line {
+			return entries[i].line < entries[j].line
+		}
+		return entries[i].key < entries[j].key
+	})
+
+	for i, e := range entries {
+		pos := toml.Position{Line: i + 1, Col: 1}
+		switch v := tree.GetPath([]string{e.key}).(type) {
+		case *toml.Tree:
+			v.SetPositionPath(nil, pos)
+			refChild, _ := refValue(ref, e.key).(*toml.Tree)
+			orderKeys(v, refChild)
+		case []*toml.Tree:
+			refChildren, _ := refValue(ref, e.key).([]*toml.Tree)
+			for j, t := range v {
+				t.SetPositionPath(nil, pos)
+				var refChild *toml.Tree
+				if j < len(refChildren) {
+					refChild = refChildren[j]
+				}
+				orderKeys(t, refChild)
+			}
+		default:
+			tree.SetPositionPath([]string{e.key}, pos)
+		}
+	}
+}
+
+func refValue(ref *toml.Tree, key string) interface{} {
+	if ref == nil {
+		return nil
+	}
+	return ref.GetPath([]string{key})
+}
@@ -0,0 +1,245 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package restart
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+)
+
+// Constants
+const (
+	// Time to wait for the engine socket to accept connections again
+	DEFAULT_TIMEOUT = 60 * time.Second
+
+	// Interval between two connection attempts to the engine socket
+	pollInterval = 500 * time.Millisecond
+
+	// Timeout of a request to the engine API
+	requestTimeout = 10 * time.Second
+)
+
+// Engine describes how to restart a container engine and query its API
+type Engine struct {
+	// Unit is the systemd unit of the engine
+	Unit string
+
+	// Socket is the default path of the API socket of the engine
+	Socket string
+
+	// runtimes returns the names of the runtimes registered with the
+	// engine, it is nil when the API of the engine cannot tell
+	runtimes func(client *http.Client) ([]string, error)
+}
+
+// Engines lists the container engines run as a daemon
+var Engines = map[string]Engine{
+	"docker": {
+		Unit:     "docker",
+		Socket:   "/var/run/docker.sock",
+		runtimes: dockerRuntimes,
+	},
+	"containerd": {
+		Unit:   "containerd",
+		Socket: "/run/containerd/containerd.sock",
+	},
+	"crio": {
+		Unit:     "crio",
+		Socket:   "/var/run/crio/crio.sock",
+		runtimes: crioRuntimes,
+	},
+}
+
+// Options of a restart of a container engine
+type Options struct {
+	// Command restarts or reloads the engine, systemctl restart of the
+	// unit of the engine when empty
+	Command []string
+
+	// Socket is the API socket of the engine, the default socket of the
+	// engine when empty
+	Socket string
+
+	// Timeout is the time to wait for the socket, DEFAULT_TIMEOUT when 0
+	Timeout time.Duration
+}
+
+// Restart runs the restart command of a container engine and waits for
+// its socket to accept connections again
+func Restart(name string, opts Options) error {
+	e, ok := Engines[name]
+	if !ok {
+		return fmt.Errorf("%v cannot be restarted", name)
+	}
+	opts = e.withDefaults(opts)
+
+	fmt.Printf("Restarting %v daemon: %v\n", name, strings.Join(opts.Command, " "))
+	out, err := exec.Command(opts.Command[0], opts.Command[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to restart %v: %v | output: %v", name, err, strings.TrimSpace(string(out)))
+	}
+
+	return waitForSocket(opts.Socket, opts.Timeout)
+}
+
+// VerifyRuntime checks through the engine API whether a runtime is
+// registered with a container engine, or no longer registered when
+// registered is false. It returns false if the engine API cannot tell.
+func VerifyRuntime(name string, socket string, runtime string, registered bool) (bool, error) {
+	e, ok := Engines[name]
+	if !ok {
+		return false, fmt.Errorf("unsupported runtime engine: %v", name)
+	}
+	if e.runtimes == nil {
+		return false, nil
+	}
+	if socket == "" {
+		socket = e.Socket
+	}
+
+	runtimes, err := e.runtimes(unixClient(socket))
+	if err != nil {
+		return false, fmt.Errorf("failed to query %v runtimes: %v", name, err)
+	}
+	if slices.Contains(runtimes, runtime) != registered {
+		if registered {
+			return false, fmt.Errorf("%v runtime is not registered with %v, registered runtimes: %v", runtime, name, runtimes)
+		}
+		return false, fmt.Errorf("%v runtime is still registered with %v", runtime, name)
+	}
+	return true, nil
+}
+
+// withDefaults fills the unset options with the defaults of the engine
+func (e Engine) withDefaults(opts Options) Options {
+	if len(opts.Command) == 0 {
+		opts.Command = []string{"systemctl", "restart", e.Unit}
+	}
+	if opts.Socket == "" {
+		opts.Socket = e.Socket
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DEFAULT_TIMEOUT
+	}
+	return opts
+}
+
+// waitForSocket waits for a unix socket to accept connections
+func waitForSocket(socket string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("unix", socket, pollInterval)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v waiting for socket: %v | err: %v", timeout, socket, err)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// unixClient returns an HTTP client sending all its requests to a unix
+// socket
+func unixClient(socket string) *http.Client {
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+// get returns the body of a successful GET request to the engine API
+func get(client *http.Client, path string) ([]byte, error) {
+	resp, err := client.Get("http://localhost" + path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %v returned %v: %v", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// dockerRuntimes returns the runtimes listed by the /info endpoint of the
+// docker API
+func dockerRuntimes(client *http.Client) ([]string, error) {
+	body, err := get(client, "/info")
+	if err != nil {
+		return nil, err
+	}
+
+	info := struct {
+		Runtimes map[string]json.RawMessage `json:"Runtimes"`
+	}{}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("error decoding docker info: %v", err)
+	}
+	return sortedKeys(info.Runtimes), nil
+}
+
+// crioRuntimes returns the runtimes of the configuration served by the
+// /config endpoint of CRI-O, the one printed by crio status config
+func crioRuntimes(client *http.Client) ([]string, error) {
+	body, err := get(client, "/config")
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := toml.LoadBytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding crio config: %v", err)
+	}
+	runtimes, ok := tree.GetPath([]string{"crio", "runtime", "runtimes"}).(*toml.Tree)
+	if !ok {
+		return []string{}, nil
+	}
+	keys := runtimes.Keys()
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// sortedKeys returns the keys of a map in lexical order
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,122 @@
+package restart
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shortTempDir returns a temporary directory with a path short enough for
+// a unix socket
+func shortTempDir(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "restart")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// fakeSystemctl puts a systemctl script recording its arguments first in
+// PATH
+func fakeSystemctl(t *testing.T, exitCode int) string {
+	dir := t.TempDir()
+	record := filepath.Join(dir, "args")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\necho restarting\nexit %d\n", record, exitCode)
+	if err := os.WriteFile(filepath.Join(dir, "systemctl"), []byte(script), 0755); err != nil {
+		t.Fatalf("writing fake systemctl: %v", err)
+	}
+	t.Setenv("PATH", dir+":"+os.Getenv("PATH"))
+	return record
+}
+
+// serveUnix serves handler on a unix socket in dir and returns its path
+func serveUnix(t *testing.T, dir string, handler http.Handler) string {
+	socket := filepath.Join(dir, "engine.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listening on %s: %v", socket, err)
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return socket
+}
+
+func TestRestart(t *testing.T) {
+	record := fakeSystemctl(t, 0)
+	socket := serveUnix(t, shortTempDir(t), http.NotFoundHandler())
+
+	err := Restart("docker", Options{Socket: socket, Timeout: time.Second})
+	Assert(t, err == nil, fmt.Sprintf("Restart returned error %v", err))
+	args, _ := os.ReadFile(record)
+	Assert(t, strings.TrimSpace(string(args)) == "restart docker", fmt.Sprintf("unexpected systemctl args %q", args))
+
+	// A custom command replaces systemctl
+	err = Restart("crio", Options{Command: []string{"systemctl", "reload", "crio"}, Socket: socket, Timeout: time.Second})
+	Assert(t, err == nil, fmt.Sprintf("Restart returned error %v", err))
+	args, _ = os.ReadFile(record)
+	Assert(t, strings.TrimSpace(string(args)) == "reload crio", fmt.Sprintf("unexpected systemctl args %q", args))
+
+	err = Restart("podman", Options{Socket: socket})
+	Assert(t, err != nil, "podman has no daemon to restart")
+}
+
+func TestRestartFailure(t *testing.T) {
+	fakeSystemctl(t, 1)
+	socket := serveUnix(t, shortTempDir(t), http.NotFoundHandler())
+
+	err := Restart("docker", Options{Socket: socket, Timeout: time.Second})
+	Assert(t, err != nil && strings.Contains(err.Error(), "restarting"), fmt.Sprintf("expected the output of systemctl in the error, got %v", err))
+}
+
+func TestRestartWaitsForSocket(t *testing.T) {
+	fakeSystemctl(t, 0)
+	dir := shortTempDir(t)
+
+	// The socket comes back a while after the restart
+	go func() {
+		time.Sleep(2 * pollInterval)
+		serveUnix(t, dir, http.NotFoundHandler())
+	}()
+	err := Restart("containerd", Options{Socket: filepath.Join(dir, "engine.sock"), Timeout: 5 * time.Second})
+	Assert(t, err == nil, fmt.Sprintf("Restart returned error %v", err))
+
+	err = Restart("containerd", Options{Socket: filepath.Join(dir, "missing.sock"), Timeout: pollInterval})
+	Assert(t, err != nil && strings.Contains(err.Error(), "timed out"), fmt.Sprintf("expected a timeout, got %v", err))
+}
+
+func TestVerifyRuntime(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Runtimes": {"amd": {"path": "amd-container-runtime"}, "runc": {"path": "runc"}}}`)
+	})
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "[crio.runtime.runtimes.runc]\nruntime_path = \"/usr/bin/runc\"\n")
+	})
+	socket := serveUnix(t, shortTempDir(t), mux)
+
+	verified, err := VerifyRuntime("docker", socket, "amd", true)
+	Assert(t, verified && err == nil, fmt.Sprintf("amd should be registered with docker: %v, %v", verified, err))
+	_, err = VerifyRuntime("docker", socket, "amd", false)
+	Assert(t, err != nil, "amd should still be registered with docker")
+
+	_, err = VerifyRuntime("crio", socket, "amd", true)
+	Assert(t, err != nil && strings.Contains(err.Error(), "runc"), fmt.Sprintf("amd should not be registered with crio: %v", err))
+	verified, err = VerifyRuntime("crio", socket, "amd", false)
+	Assert(t, verified && err == nil, fmt.Sprintf("amd should not be registered with crio: %v, %v", verified, err))
+
+	verified, err = VerifyRuntime("containerd", socket, "amd", true)
+	Assert(t, !verified && err == nil, "containerd runtimes cannot be verified")
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
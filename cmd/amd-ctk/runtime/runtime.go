@@ -18,6 +18,7 @@ package runtime
 
 import (
 	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/configure"
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/status"
 	"github.com/urfave/cli/v2"
 )
 
@@ -31,6 +32,7 @@ func AddNewCommand() *cli.Command {
 
 	runtimeCmd.Subcommands = []*cli.Command{
 		configure.AddNewCommand(),
+		status.AddNewCommand(),
 	}
 
 	return &runtimeCmd
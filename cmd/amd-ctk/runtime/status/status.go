@@ -0,0 +1,285 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package status
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/ROCm/container-toolkit/cmd/amd-ctk/runtime/engine/engines"
+	"github.com/ROCm/container-toolkit/internal/cdi"
+	"github.com/ROCm/container-toolkit/internal/config"
+	gpuTracker "github.com/ROCm/container-toolkit/internal/gpu-tracker"
+	"github.com/ROCm/container-toolkit/internal/output"
+	"github.com/ROCm/container-toolkit/internal/runtime"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	amdRuntimeName = "amd"
+)
+
+type statusOptions struct {
+	runtime        string
+	configFilepath string
+}
+
+// inspector gathers the status of the AMD runtime integration
+type inspector struct {
+	cfg *config.Config
+
+	// lookPath resolves an executable to an absolute path
+	lookPath func(string) (string, error)
+
+	// findLowLevelRuntime returns the runtime the containers are handed
+	// off to
+	findLowLevelRuntime func([]string) (string, error)
+
+	// validateSpec reports whether a CDI spec matches the GPUs of the host
+	validateSpec func(string) (bool, error)
+
+	// gpuTrackerEnabled reports whether the GPU Tracker is enabled
+	gpuTrackerEnabled func() (bool, error)
+}
+
+func AddNewCommand() *cli.Command {
+	stOptions := statusOptions{}
+
+	// Add the status subcommand
+	statusCmd := cli.Command{
+		Name:      "status",
+		Usage:     "Report the integration of the AMD runtime with the container engines",
+		UsageText: "amd-ctk runtime status [options]",
+		Before: func(c *cli.Context) error {
+			return validateStatusOptions(c, &stOptions)
+		},
+		Action: func(c *cli.Context) error {
+			return performAction(c, &stOptions)
+		},
+	}
+
+	statusCmd.Flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:        "runtime",
+			Usage:       "only inspect this runtime engine, [docker, containerd, crio, podman], defaults to all of them",
+			Destination: &stOptions.runtime,
+		},
+		&cli.StringFlag{
+			Name:        "config-path",
+			Usage:       "path to the configuration file for the runtime engine, defaults to the standard path of the engine",
+			Destination: &stOptions.configFilepath,
+		},
+	}
+	return &statusCmd
+}
+
+func validateStatusOptions(c *cli.Context, stOptions *statusOptions) error {
+	if stOptions.runtime == "" {
+		if c.IsSet("config-path") {
+			return fmt.Errorf("config-path flag requires the runtime flag")
+		}
+		return nil
+	}
+
+	defaultPath, ok := engines.DefaultConfigPath(stOptions.runtime)
+	if !ok {
+		return fmt.Errorf("unsupported runtime engine: %v", stOptions.runtime)
+	}
+	if !c.IsSet("config-path") {
+		stOptions.configFilepath = defaultPath
+	}
+	return nil
+}
+
+func performAction(c *cli.Context, stOptions *statusOptions) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	in := &inspector{
+		cfg:                 cfg,
+		lookPath:            exec.LookPath,
+		findLowLevelRuntime: runtime.FindLowLevelRuntime,
+		validateSpec: func(path string) (bool, error) {
+			handler, err := cdi.New(path)
+			if err != nil {
+				return false, err
+			}
+			return handler.ValidateSpec()
+		},
+		gpuTrackerEnabled: func() (bool, error) {
+			tracker, err := gpuTracker.New()
+			if err != nil {
+				return false, err
+			}
+			return tracker.IsEnabled()
+		},
+	}
+
+	doc := in.inspect(stOptions.runtime, stOptions.configFilepath)
+
+	if err := output.PrintRuntimeStatus(os.Stdout, c.String("output"), doc); err != nil {
+		return err
+	}
+	if len(doc.Problems) > 0 {
+		return cli.Exit(fmt.Sprintf("found %d problems", len(doc.Problems)), 1)
+	}
+	return nil
+}
+
+// inspect returns the status of the AMD runtime integration. Only the
+// selected engine is inspected when set, and not finding the amd runtime
+// in its configuration is then a problem.
+func (in *inspector) inspect(selected string, configPath string) output.RuntimeStatus {
+	doc := output.RuntimeStatus{
+		Engines:  []output.EngineStatus{},
+		Problems: []string{},
+	}
+	problem := func(format string, a ...interface{}) {
+		doc.Problems = append(doc.Problems, fmt.Sprintf(format, a...))
+	}
+
+	names := engines.Names
+	if selected != "" {
+		names = []string{selected}
+	}
+
+	registered := false
+	for _, name := range names {
+		path := configPath
+		if selected == "" {
+			path, _ = engines.DefaultConfigPath(name)
+		}
+
+		status, err := in.inspectEngine(name, path)
+		doc.Engines = append(doc.Engines, status)
+		switch {
+		case err != nil:
+			problem("%v: %v", name, err)
+		case !status.ConfigFound:
+			if selected != "" {
+				problem("%v: config file %v not found", name, path)
+			}
+		case !status.Registered:
+			if selected != "" {
+				problem("%v: %v runtime is not registered", name, amdRuntimeName)
+			}
+		default:
+			registered = true
+			if status.ResolvedRuntimePath == "" {
+				problem("%v: %v runtime executable %q not found", name, amdRuntimeName, status.RuntimePath)
+			}
+			if !status.CDIEnabled {
+				problem("%v: CDI is not enabled", name)
+			}
+		}
+	}
+	if selected == "" && !registered {
+		problem("%v runtime is not registered with any engine", amdRuntimeName)
+	}
+
+	lowLevelRuntime, err := in.findLowLevelRuntime(in.cfg.Runtime.Runtimes)
+	if err != nil {
+		problem("%v", err)
+	}
+	doc.LowLevelRuntime = lowLevelRuntime
+
+	// The hook is only added to the containers in the hook runtime mode
+	doc.HookPath = in.resolve(in.cfg.Hook.Path)
+	if doc.HookPath == "" && in.cfg.Runtime.Mode == config.RUNTIME_MODE_HOOK {
+		problem("runtime hook %v not found, it is required by the %v runtime mode", in.cfg.Hook.Path, config.RUNTIME_MODE_HOOK)
+	}
+
+	doc.CDISpec = in.inspectCDISpec(problem)
+
+	doc.GPUTrackerEnabled, err = in.gpuTrackerEnabled()
+	if err != nil {
+		problem("failed to read the GPU Tracker state: %v", err)
+	}
+
+	return doc
+}
+
+// inspectEngine reads the status of the amd runtime from the
+// configuration file of an engine
+func (in *inspector) inspectEngine(name string, path string) (output.EngineStatus, error) {
+	status := output.EngineStatus{
+		Name:       name,
+		ConfigPath: path,
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return status, nil
+	}
+	status.ConfigFound = true
+
+	runtimeEngine, err := engines.New(name, path)
+	if err != nil {
+		return status, err
+	}
+
+	status.RuntimePath, status.Registered = runtimeEngine.RuntimePath(amdRuntimeName)
+	status.Default = runtimeEngine.IsDefaultRuntime(amdRuntimeName)
+	status.CDIEnabled = runtimeEngine.IsCDIEnabled()
+	if status.Registered {
+		status.ResolvedRuntimePath = in.resolve(status.RuntimePath)
+	}
+	return status, nil
+}
+
+// inspectCDISpec checks the CDI spec applied in the cdi runtime mode. A
+// missing spec is only a problem in that mode.
+func (in *inspector) inspectCDISpec(problem func(string, ...interface{})) output.CDISpecStatus {
+	spec := output.CDISpecStatus{Path: in.cfg.Runtime.CDISpecPath}
+
+	if _, err := os.Stat(spec.Path); err != nil {
+		if in.cfg.Runtime.Mode == config.RUNTIME_MODE_CDI {
+			problem("CDI spec %v not found, it is required by the %v runtime mode", spec.Path, config.RUNTIME_MODE_CDI)
+		}
+		return spec
+	}
+	spec.Present = true
+
+	valid, err := in.validateSpec(spec.Path)
+	switch {
+	case err != nil:
+		problem("failed to validate CDI spec %v: %v", spec.Path, err)
+	case !valid:
+		problem("CDI spec %v does not match the GPUs of the host, run amd-ctk cdi generate", spec.Path)
+	}
+	spec.Valid = valid && err == nil
+	return spec
+}
+
+// resolve returns the absolute path of an executable, or an empty string
+// if it is not found
+func (in *inspector) resolve(path string) string {
+	if path == "" {
+		return ""
+	}
+	p, err := in.lookPath(path)
+	if err != nil {
+		return ""
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
@@ -0,0 +1,123 @@
+package status
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ROCm/container-toolkit/internal/config"
+)
+
+// newTestInspector returns an inspector finding every executable but
+// amd-container-runtime, with a valid CDI spec
+func newTestInspector(t *testing.T) *inspector {
+	cfg := config.Default()
+	cfg.Runtime.CDISpecPath = filepath.Join(t.TempDir(), "amd.json")
+	Assert(t, os.WriteFile(cfg.Runtime.CDISpecPath, []byte("{}"), 0644) == nil, "writing the CDI spec failed")
+
+	return &inspector{
+		cfg: cfg,
+		lookPath: func(path string) (string, error) {
+			if filepath.Base(path) == "amd-container-runtime" {
+				return "", fmt.Errorf("%v not found", path)
+			}
+			return filepath.Join("/usr/bin", filepath.Base(path)), nil
+		},
+		findLowLevelRuntime: func([]string) (string, error) { return "/usr/bin/runc", nil },
+		validateSpec:        func(string) (bool, error) { return true, nil },
+		gpuTrackerEnabled:   func() (bool, error) { return true, nil },
+	}
+}
+
+func writeConfig(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	Assert(t, os.WriteFile(path, []byte(content), 0644) == nil, fmt.Sprintf("writing %s failed", path))
+	return path
+}
+
+func TestInspectDocker(t *testing.T) {
+	in := newTestInspector(t)
+	path := writeConfig(t, "daemon.json", `{"default-runtime": "amd", "features": {"cdi": true},
+		"runtimes": {"amd": {"path": "/usr/local/bin/amd-container-runtime-wrapper", "args": []}}}`)
+
+	doc := in.inspect("docker", path)
+	Assert(t, len(doc.Problems) == 0, fmt.Sprintf("unexpected problems %v", doc.Problems))
+	Assert(t, len(doc.Engines) == 1, fmt.Sprintf("expected 1 engine, got %v", doc.Engines))
+	e := doc.Engines[0]
+	Assert(t, e.ConfigFound && e.Registered && e.Default && e.CDIEnabled, fmt.Sprintf("unexpected status %+v", e))
+	Assert(t, e.ResolvedRuntimePath == "/usr/bin/amd-container-runtime-wrapper", fmt.Sprintf("unexpected runtime path %v", e.ResolvedRuntimePath))
+	Assert(t, doc.LowLevelRuntime == "/usr/bin/runc", fmt.Sprintf("unexpected low-level runtime %v", doc.LowLevelRuntime))
+	Assert(t, doc.CDISpec.Present && doc.CDISpec.Valid, fmt.Sprintf("unexpected CDI spec status %+v", doc.CDISpec))
+	Assert(t, doc.GPUTrackerEnabled, "GPU Tracker should be enabled")
+}
+
+func TestInspectProblems(t *testing.T) {
+	in := newTestInspector(t)
+	in.findLowLevelRuntime = func(c []string) (string, error) { return "", fmt.Errorf("none of the low-level runtimes %v found", c) }
+	in.validateSpec = func(string) (bool, error) { return false, nil }
+
+	path := writeConfig(t, "config.toml", `version = 2
+[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.amd.options]
+BinaryName = "amd-container-runtime"
+`)
+	doc := in.inspect("containerd", path)
+	expected := []string{
+		`containerd: amd runtime executable "amd-container-runtime" not found`,
+		"containerd: CDI is not enabled",
+		"none of the low-level runtimes [runc crun] found",
+		fmt.Sprintf("CDI spec %v does not match the GPUs of the host, run amd-ctk cdi generate", in.cfg.Runtime.CDISpecPath),
+	}
+	Assert(t, slices.Equal(doc.Problems, expected), fmt.Sprintf("expected problems:\n%q\ngot:\n%q", expected, doc.Problems))
+	Assert(t, !doc.Engines[0].Default, "amd should not be the default runtime")
+
+	// A config without the amd runtime is only a problem for the selected
+	// engine
+	path = writeConfig(t, "99-amd.conf", "[engine]\nruntime = \"crun\"\n")
+	doc = newTestInspector(t).inspect("podman", path)
+	Assert(t, slices.Equal(doc.Problems, []string{"podman: amd runtime is not registered"}), fmt.Sprintf("unexpected problems %q", doc.Problems))
+
+	doc = newTestInspector(t).inspect("crio", filepath.Join(t.TempDir(), "missing.toml"))
+	Assert(t, len(doc.Problems) == 1 && !doc.Engines[0].ConfigFound, fmt.Sprintf("unexpected problems %q", doc.Problems))
+}
+
+func TestInspectCDISpec(t *testing.T) {
+	in := newTestInspector(t)
+	Assert(t, os.Remove(in.cfg.Runtime.CDISpecPath) == nil, "removing the CDI spec failed")
+
+	problems := []string{}
+	problem := func(format string, a ...interface{}) { problems = append(problems, fmt.Sprintf(format, a...)) }
+
+	spec := in.inspectCDISpec(problem)
+	Assert(t, !spec.Present && len(problems) == 0, "a missing CDI spec is not a problem in the legacy mode")
+
+	in.cfg.Runtime.Mode = config.RUNTIME_MODE_CDI
+	spec = in.inspectCDISpec(problem)
+	Assert(t, !spec.Present && len(problems) == 1, fmt.Sprintf("a missing CDI spec is a problem in the cdi mode: %q", problems))
+}
+
+func TestInspectHook(t *testing.T) {
+	in := newTestInspector(t)
+	path := writeConfig(t, "99-amd.conf", "[engine.runtimes]\namd = [\"/usr/bin/crun\"]\n")
+	in.lookPath = func(path string) (string, error) {
+		if filepath.Base(path) == "amd-container-runtime-hook" {
+			return "", fmt.Errorf("%v not found", path)
+		}
+		return path, nil
+	}
+
+	doc := in.inspect("podman", path)
+	Assert(t, doc.HookPath == "" && len(doc.Problems) == 0, fmt.Sprintf("a missing hook is not a problem in the legacy mode: %q", doc.Problems))
+
+	in.cfg.Runtime.Mode = config.RUNTIME_MODE_HOOK
+	doc = in.inspect("podman", path)
+	expected := []string{fmt.Sprintf("runtime hook %v not found, it is required by the hook runtime mode", in.cfg.Hook.Path)}
+	Assert(t, slices.Equal(doc.Problems, expected), fmt.Sprintf("expected problems:\n%q\ngot:\n%q", expected, doc.Problems))
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
@@ -0,0 +1,54 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package main
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/ROCm/container-toolkit/internal/config"
+	"github.com/ROCm/container-toolkit/internal/hook"
+	"github.com/ROCm/container-toolkit/internal/logger"
+)
+
+func main() {
+	logger.SetLogFile("amd-container-runtime-hook.log")
+	logger.SetLogPrefix("amd-container-runtime-hook ")
+	cfg, err := config.Load()
+	if err == nil {
+		logger.SetDefaultLogDir(cfg.Runtime.LogDir)
+	}
+	logger.Init(false)
+	if err != nil {
+		slog.Error("Failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Running ROCm container runtime hook", "args", os.Args)
+
+	h, err := hook.New(os.Stdin, os.Args[1:])
+	if err != nil {
+		slog.Error("Failed to create container runtime hook", "error", err)
+		os.Exit(1)
+	}
+
+	err = h.Run()
+	if err != nil {
+		slog.Error("Failed to run container runtime hook", "error", err)
+		os.Exit(1)
+	}
+}
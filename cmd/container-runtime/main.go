@@ -20,19 +20,28 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/ROCm/container-toolkit/internal/config"
 	gpuTracker "github.com/ROCm/container-toolkit/internal/gpu-tracker"
 	"github.com/ROCm/container-toolkit/internal/logger"
+	"github.com/ROCm/container-toolkit/internal/rootless"
 	"github.com/ROCm/container-toolkit/internal/runtime"
 )
 
 func main() {
+	cfg, err := config.Load()
+	if err == nil {
+		logger.SetDefaultLogDir(cfg.Runtime.LogDir)
+	}
 	logger.Init(false)
-
-	if os.Getuid() != 0 {
-		slog.Error("Rootless mode is not supported")
+	if err != nil {
+		slog.Error("Failed to load config", "error", err)
 		os.Exit(1)
 	}
 
+	if rootless.IsRootless() {
+		slog.Info("Running in rootless mode", "uid", os.Getuid(), "log_dir", cfg.Runtime.LogDir, "gpu_tracker_file", cfg.GPUTracker.File)
+	}
+
 	slog.Info("Creating ROCm container runtime", "args", os.Args)
 
 	rt, err := runtime.New(os.Args)
@@ -23,12 +23,13 @@ import (
 	"log/slog"
 	"math"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+
+	"golang.org/x/sys/unix"
 )
 
 type DeviceInfo struct {
@@ -36,12 +37,26 @@ type DeviceInfo struct {
 	PartitionType string
 }
 
+// DeviceStat holds the metadata of a device node
+type DeviceStat struct {
+	// Major and Minor are the device numbers
+	Major int64
+	Minor int64
+
+	// Mode is the raw st_mode, with the file type and permission bits
+	Mode uint32
+
+	// Uid and Gid are the owner of the device node
+	Uid uint32
+	Gid uint32
+}
+
 // FileSystem interface for mocking filesystem operations
 type FileSystem interface {
 	Stat(name string) (os.FileInfo, error)
 	Glob(pattern string) ([]string, error)
 	ReadFile(name string) ([]byte, error)
-	GetDeviceStat(dev string, format string) (string, error)
+	GetDeviceStat(dev string) (DeviceStat, error)
 }
 
 // DefaultFS implements FileSystem using actual filesystem operations
@@ -59,12 +74,19 @@ func (fs *DefaultFS) ReadFile(name string) ([]byte, error) {
 	return os.ReadFile(name)
 }
 
-func (fs *DefaultFS) GetDeviceStat(dev string, format string) (string, error) {
-	out, err := exec.Command("stat", "-c", format, dev).Output()
-	if err != nil {
-		return "", fmt.Errorf("stat %s: %w", dev, err)
+func (fs *DefaultFS) GetDeviceStat(dev string) (DeviceStat, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(dev, &st); err != nil {
+		return DeviceStat{}, fmt.Errorf("stat %s: %w", dev, err)
 	}
-	return strings.TrimSpace(string(out)), nil
+
+	return DeviceStat{
+		Major: int64(unix.Major(uint64(st.Rdev))),
+		Minor: int64(unix.Minor(uint64(st.Rdev))),
+		Mode:  st.Mode,
+		Uid:   st.Uid,
+		Gid:   st.Gid,
+	}, nil
 }
 
 var defaultFS FileSystem = &DefaultFS{}
@@ -197,45 +219,30 @@ func GetAMDGPU(dev string) (AMDGPU, error) {
 }
 
 func GetAMDGPUWithFS(fs FileSystem, dev string) (AMDGPU, error) {
-	getStat := func(dev, format string) (string, error) {
-		return fs.GetDeviceStat(dev, format)
-	}
-
-	convStat := func(dev, format string, base, width int) int64 {
-		out, err := getStat(dev, format)
-		if err != nil {
-			return 0
-		}
-
-		var ret int64
-		if base == 8 {
-			ret, err = strconv.ParseInt("0"+out, base, width)
-		} else {
-			ret, err = strconv.ParseInt(out, base, width)
-		}
-		if err != nil {
-			slog.Debug("Failed to parse device stat", "device", dev, "value", out, "base", base, "error", err)
-			return 0
-		}
-
-		return ret
+	st, err := fs.GetDeviceStat(dev)
+	if err != nil {
+		return AMDGPU{}, fmt.Errorf("getting device info: %w", err)
 	}
 
 	gpu := AMDGPU{
 		Path:     dev,
-		Major:    convStat(dev, "%t", 16, 64),
-		Minor:    convStat(dev, "%T", 16, 64),
-		FileMode: os.FileMode(convStat(dev, "%a", 8, 64)),
+		Major:    st.Major,
+		Minor:    st.Minor,
+		FileMode: os.FileMode(st.Mode & 0777),
+		Gid:      st.Gid,
+		Uid:      st.Uid,
 		Allow:    true,
 		DevType:  "c",
 		Access:   "rwm",
 	}
 
-	gid := convStat(dev, "%g", 10, 32)
-	gpu.Gid = uint32(gid)
-
-	uid := 0
-	gpu.Uid = uint32(uid)
+	switch st.Mode & unix.S_IFMT {
+	case unix.S_IFCHR:
+	case unix.S_IFBLK:
+		gpu.DevType = "b"
+	default:
+		return AMDGPU{}, fmt.Errorf("%s is not a device node", dev)
+	}
 
 	return gpu, nil
 }
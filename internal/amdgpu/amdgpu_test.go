@@ -10,6 +10,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/sys/unix"
 )
 
 // Mock filesystem operations
@@ -41,9 +42,9 @@ func (m *mockFS) ReadFile(name string) ([]byte, error) {
 	return args.Get(0).([]byte), args.Error(1)
 }
 
-func (m *mockFS) GetDeviceStat(dev string, format string) (string, error) {
-	args := m.Called(dev, format)
-	return args.String(0), args.Error(1)
+func (m *mockFS) GetDeviceStat(dev string) (DeviceStat, error) {
+	args := m.Called(dev)
+	return args.Get(0).(DeviceStat), args.Error(1)
 }
 
 // Mock file info
@@ -428,15 +429,19 @@ func TestGetAMDGPUWithFS(t *testing.T) {
 		setupMocks    func(*mockFS)
 		expectedGPU   AMDGPU
 		expectedError error
+		wantErr       bool
 	}{
 		{
 			name:   "valid GPU device",
 			device: "/dev/dri/card0",
 			setupMocks: func(m *mockFS) {
-				m.On("GetDeviceStat", "/dev/dri/card0", "%t").Return("e2", nil)  // major number (226 in hex)
-				m.On("GetDeviceStat", "/dev/dri/card0", "%T").Return("0", nil)   // minor number
-				m.On("GetDeviceStat", "/dev/dri/card0", "%a").Return("666", nil) // file mode (octal)
-				m.On("GetDeviceStat", "/dev/dri/card0", "%g").Return("44", nil)  // group ID
+				m.On("GetDeviceStat", "/dev/dri/card0").Return(DeviceStat{
+					Major: 226,
+					Minor: 0,
+					Mode:  unix.S_IFCHR | 0666,
+					Uid:   0,
+					Gid:   44,
+				}, nil)
 			},
 			expectedGPU: AMDGPU{
 				Path:     "/dev/dri/card0",
@@ -455,18 +460,21 @@ func TestGetAMDGPUWithFS(t *testing.T) {
 			name:   "valid render device",
 			device: "/dev/dri/renderD128",
 			setupMocks: func(m *mockFS) {
-				m.On("GetDeviceStat", "/dev/dri/renderD128", "%t").Return("e2", nil)  // major number (226 in hex)
-				m.On("GetDeviceStat", "/dev/dri/renderD128", "%T").Return("80", nil)  // minor number (128 in hex)
-				m.On("GetDeviceStat", "/dev/dri/renderD128", "%a").Return("666", nil) // file mode (octal)
-				m.On("GetDeviceStat", "/dev/dri/renderD128", "%g").Return("44", nil)  // group ID
+				m.On("GetDeviceStat", "/dev/dri/renderD128").Return(DeviceStat{
+					Major: 226,
+					Minor: 128,
+					Mode:  unix.S_IFCHR | 0660,
+					Uid:   1000,
+					Gid:   109,
+				}, nil)
 			},
 			expectedGPU: AMDGPU{
 				Path:     "/dev/dri/renderD128",
 				Major:    226,
 				Minor:    128,
-				FileMode: 0666,
-				Gid:      44,
-				Uid:      0,
+				FileMode: 0660,
+				Gid:      109,
+				Uid:      1000,
 				Allow:    true,
 				DevType:  "c",
 				Access:   "rwm",
@@ -477,23 +485,17 @@ func TestGetAMDGPUWithFS(t *testing.T) {
 			name:   "non-existent device",
 			device: "/dev/dri/card999",
 			setupMocks: func(m *mockFS) {
-				m.On("GetDeviceStat", "/dev/dri/card999", "%t").Return("", fmt.Errorf("stat failed"))
-				m.On("GetDeviceStat", "/dev/dri/card999", "%T").Return("", fmt.Errorf("stat failed"))
-				m.On("GetDeviceStat", "/dev/dri/card999", "%a").Return("", fmt.Errorf("stat failed"))
-				m.On("GetDeviceStat", "/dev/dri/card999", "%g").Return("", fmt.Errorf("stat failed"))
+				m.On("GetDeviceStat", "/dev/dri/card999").Return(DeviceStat{}, os.ErrNotExist)
 			},
-			expectedGPU: AMDGPU{
-				Path:     "/dev/dri/card999",
-				Major:    0,
-				Minor:    0,
-				FileMode: 0,
-				Gid:      0,
-				Uid:      0,
-				Allow:    true,
-				DevType:  "c",
-				Access:   "rwm",
+			expectedError: os.ErrNotExist,
+		},
+		{
+			name:   "not a device node",
+			device: "/dev/dri/by-path",
+			setupMocks: func(m *mockFS) {
+				m.On("GetDeviceStat", "/dev/dri/by-path").Return(DeviceStat{Mode: unix.S_IFDIR | 0755}, nil)
 			},
-			expectedError: nil,
+			wantErr: true,
 		},
 	}
 
@@ -504,9 +506,11 @@ func TestGetAMDGPUWithFS(t *testing.T) {
 
 			gpu, err := GetAMDGPUWithFS(mockFS, tt.device)
 
-			if tt.expectedError != nil {
+			if tt.expectedError != nil || tt.wantErr {
 				assert.Error(t, err)
-				assert.ErrorIs(t, err, tt.expectedError)
+				if tt.expectedError != nil {
+					assert.ErrorIs(t, err, tt.expectedError)
+				}
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expectedGPU, gpu)
@@ -517,6 +521,24 @@ func TestGetAMDGPUWithFS(t *testing.T) {
 	}
 }
 
+func TestDefaultFSGetDeviceStat(t *testing.T) {
+	fs := &DefaultFS{}
+
+	st, err := fs.GetDeviceStat("/dev/null")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), st.Major)
+	assert.Equal(t, int64(3), st.Minor)
+	assert.Equal(t, uint32(unix.S_IFCHR), st.Mode&unix.S_IFMT)
+
+	gpu, err := GetAMDGPUWithFS(fs, "/dev/null")
+	assert.NoError(t, err)
+	assert.Equal(t, "c", gpu.DevType)
+	assert.Equal(t, os.FileMode(0666), gpu.FileMode)
+
+	_, err = fs.GetDeviceStat("/dev/nonexistent-device")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
 func TestGetDevIdsFromTopology(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -0,0 +1,344 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package amdgpu
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// KFD memory bank heap types of the GPU framebuffer
+const (
+	heapTypeFBPublic  = "1"
+	heapTypeFBPrivate = "2"
+)
+
+// KFD IO link types
+const (
+	ioLinkTypePCIe = "2"
+	ioLinkTypeXGMI = "11"
+)
+
+// IOLink is a link of a GPU to another KFD topology node
+type IOLink struct {
+	// NodeTo is the KFD node at the other end of the link
+	NodeTo int `json:"nodeTo" yaml:"nodeTo"`
+
+	// Type is the link type, either pcie, xgmi or other
+	Type string `json:"type" yaml:"type"`
+
+	// Weight is the relative distance of the link, lower is closer
+	Weight int `json:"weight" yaml:"weight"`
+}
+
+// GPUInfo is the inventory entry of a GPU, or of a GPU partition. Partitions
+// of the same physical GPU share its PCI metadata.
+type GPUInfo struct {
+	// Index is the GPU Id used to select the GPU in AMD_VISIBLE_DEVICES
+	Index int `json:"index" yaml:"index"`
+
+	// DrmDevices are the card and render device nodes of the GPU
+	DrmDevices []string `json:"drmDevices" yaml:"drmDevices"`
+
+	// RenderMinor is the minor number of the render device node
+	RenderMinor int `json:"renderMinor" yaml:"renderMinor"`
+
+	// PCIBusID is the PCI address of the physical GPU, e.g. 0000:05:00.0
+	PCIBusID string `json:"pciBusId" yaml:"pciBusId"`
+
+	// VendorID, DeviceID, SubsystemVendorID and SubsystemDeviceID are the
+	// PCI IDs of the physical GPU, e.g. 0x1002
+	VendorID          string `json:"vendorId" yaml:"vendorId"`
+	DeviceID          string `json:"deviceId" yaml:"deviceId"`
+	SubsystemVendorID string `json:"subsystemVendorId" yaml:"subsystemVendorId"`
+	SubsystemDeviceID string `json:"subsystemDeviceId" yaml:"subsystemDeviceId"`
+
+	// MarketingName is the product name of the GPU, if exposed by the driver
+	MarketingName string `json:"marketingName" yaml:"marketingName"`
+
+	// GfxTargetVersion is the GPU architecture, e.g. gfx942
+	GfxTargetVersion string `json:"gfxTargetVersion" yaml:"gfxTargetVersion"`
+
+	// UniqueID is the unique_id of the GPU in hex, e.g. 0x1a2b3c
+	UniqueID string `json:"uniqueId" yaml:"uniqueId"`
+
+	// KFDNodeID is the node of the GPU in the KFD topology
+	KFDNodeID int `json:"kfdNodeId" yaml:"kfdNodeId"`
+
+	// NUMANode is the NUMA node of the GPU, -1 if unknown
+	NUMANode int `json:"numaNode" yaml:"numaNode"`
+
+	// LocalCPUList is the list of the CPUs local to the GPU, e.g. 0-47,96-143
+	LocalCPUList string `json:"localCpuList" yaml:"localCpuList"`
+
+	// VRAMSize is the size of the GPU memory in bytes
+	VRAMSize uint64 `json:"vramSize" yaml:"vramSize"`
+
+	// ComputePartition and MemoryPartition are the partition modes of the
+	// physical GPU, e.g. cpx and nps1
+	ComputePartition string `json:"computePartition" yaml:"computePartition"`
+	MemoryPartition  string `json:"memoryPartition" yaml:"memoryPartition"`
+
+	// HiveID is the XGMI hive of the GPU in hex, empty if not in a hive
+	HiveID string `json:"hiveId" yaml:"hiveId"`
+
+	// Parent is the PCI address of the physical GPU of a partition, empty
+	// if the physical GPU is not partitioned
+	Parent string `json:"parent" yaml:"parent"`
+
+	// PartitionID is the index of the partition within its physical GPU
+	PartitionID int `json:"partitionId" yaml:"partitionId"`
+
+	// IOLinks are the links of the GPU to the other KFD topology nodes
+	IOLinks []IOLink `json:"ioLinks" yaml:"ioLinks"`
+}
+
+// kfdNode holds the properties of a KFD topology node
+type kfdNode struct {
+	id    int
+	path  string
+	props map[string]string
+}
+
+// GetGPUInventory returns the inventory of all the GPUs on the system, in
+// the order of their GPU Ids
+func GetGPUInventory() ([]GPUInfo, error) {
+	return GetGPUInventoryWithFS(defaultFS)
+}
+
+// GetGPUInventoryWithFS returns the GPU inventory read through the given
+// FileSystem
+func GetGPUInventoryWithFS(fs FileSystem) ([]GPUInfo, error) {
+	devs, err := GetAMDGPUsWithFS(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := getKFDNodes(fs, "/sys/class/kfd/kfd")
+
+	gpus := []GPUInfo{}
+	for idx, dev := range devs {
+		gpu := GPUInfo{
+			Index:      idx,
+			DrmDevices: dev.DrmDevices,
+			NUMANode:   -1,
+			IOLinks:    []IOLink{},
+		}
+		for _, d := range dev.DrmDevices {
+			if minor, ok := strings.CutPrefix(filepath.Base(d), "renderD"); ok {
+				gpu.RenderMinor, _ = strconv.Atoi(minor)
+			}
+		}
+
+		if node, exists := nodes[gpu.RenderMinor]; exists {
+			gpu.setKFDProperties(fs, node)
+		} else {
+			slog.Debug("No KFD topology node found", "renderMinor", gpu.RenderMinor)
+		}
+
+		if gpu.PCIBusID != "" {
+			gpu.setPCIProperties(fs, filepath.Join("/sys/bus/pci/devices", gpu.PCIBusID))
+		}
+
+		gpus = append(gpus, gpu)
+	}
+
+	// Partitions of the same physical GPU are next to each other
+	for i := 0; i < len(gpus); {
+		j := i + 1
+		for j < len(gpus) && gpus[i].PCIBusID != "" && gpus[j].PCIBusID == gpus[i].PCIBusID {
+			j++
+		}
+		if j-i > 1 {
+			for k := i; k < j; k++ {
+				gpus[k].Parent = gpus[k].PCIBusID
+				gpus[k].PartitionID = k - i
+			}
+		}
+		i = j
+	}
+
+	return gpus, nil
+}
+
+// getKFDNodes returns the GPU nodes of the KFD topology by render minor
+func getKFDNodes(fs FileSystem, topoRoot string) map[int]kfdNode {
+	nodes := make(map[int]kfdNode)
+
+	nodeFiles, err := fs.Glob(topoRoot + "/topology/nodes/*/properties")
+	if err != nil {
+		slog.Warn("Failed to glob topology nodes", "error", err)
+		return nodes
+	}
+
+	for _, nodeFile := range nodeFiles {
+		props, err := readProperties(fs, nodeFile)
+		if err != nil {
+			slog.Debug("Error reading topology node", "file", nodeFile, "error", err)
+			continue
+		}
+
+		renderMinor, err := strconv.Atoi(props["drm_render_minor"])
+		if err != nil || renderMinor <= 0 {
+			continue
+		}
+
+		id, err := strconv.Atoi(filepath.Base(filepath.Dir(nodeFile)))
+		if err != nil {
+			continue
+		}
+
+		nodes[renderMinor] = kfdNode{
+			id:    id,
+			path:  filepath.Dir(nodeFile),
+			props: props,
+		}
+	}
+
+	return nodes
+}
+
+// setKFDProperties sets the GPU properties found in its KFD topology node
+func (gpu *GPUInfo) setKFDProperties(fs FileSystem, node kfdNode) {
+	gpu.KFDNodeID = node.id
+
+	if v, err := strconv.ParseUint(node.props["unique_id"], 10, 64); err == nil && v != 0 {
+		gpu.UniqueID = fmt.Sprintf("0x%x", v)
+	}
+	if v, err := strconv.ParseUint(node.props["hive_id"], 10, 64); err == nil && v != 0 {
+		gpu.HiveID = fmt.Sprintf("0x%x", v)
+	}
+	if v, err := strconv.Atoi(node.props["gfx_target_version"]); err == nil && v != 0 {
+		gpu.GfxTargetVersion = FormatGfxTargetVersion(v)
+	}
+
+	locationId, e1 := strconv.ParseInt(node.props["location_id"], 10, 64)
+	domain, e2 := strconv.ParseInt(node.props["domain"], 10, 64)
+	if e1 == nil && e2 == nil {
+		bus := (locationId >> 8) & 0xff
+		dev := (locationId >> 3) & 0x1f
+		fn := locationId & 0x7
+		gpu.PCIBusID = fmt.Sprintf("%04x:%02x:%02x.%x", domain, bus, dev, fn)
+	}
+
+	// The memory banks of a partition only cover its own share of the VRAM
+	bankFiles, _ := fs.Glob(node.path + "/mem_banks/*/properties")
+	for _, bankFile := range bankFiles {
+		props, err := readProperties(fs, bankFile)
+		if err != nil {
+			continue
+		}
+		if props["heap_type"] != heapTypeFBPublic && props["heap_type"] != heapTypeFBPrivate {
+			continue
+		}
+		if size, err := strconv.ParseUint(props["size_in_bytes"], 10, 64); err == nil {
+			gpu.VRAMSize += size
+		}
+	}
+
+	// io_links hold the links to the CPU and the XGMI peers, p2p_links the
+	// PCIe peer-to-peer links to the other GPUs
+	for _, dir := range []string{"io_links", "p2p_links"} {
+		linkFiles, _ := fs.Glob(node.path + "/" + dir + "/*/properties")
+		for _, linkFile := range linkFiles {
+			props, err := readProperties(fs, linkFile)
+			if err != nil {
+				continue
+			}
+			nodeTo, err := strconv.Atoi(props["node_to"])
+			if err != nil {
+				continue
+			}
+			link := IOLink{
+				NodeTo: nodeTo,
+				Type:   "other",
+			}
+			link.Weight, _ = strconv.Atoi(props["weight"])
+			switch props["type"] {
+			case ioLinkTypePCIe:
+				link.Type = "pcie"
+			case ioLinkTypeXGMI:
+				link.Type = "xgmi"
+			}
+			gpu.IOLinks = append(gpu.IOLinks, link)
+		}
+	}
+}
+
+// setPCIProperties sets the GPU properties found in the sysfs directory
+// of its PCI device
+func (gpu *GPUInfo) setPCIProperties(fs FileSystem, pciPath string) {
+	read := func(name string) string {
+		data, err := fs.ReadFile(filepath.Join(pciPath, name))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(data))
+	}
+
+	gpu.VendorID = read("vendor")
+	gpu.DeviceID = read("device")
+	gpu.SubsystemVendorID = read("subsystem_vendor")
+	gpu.SubsystemDeviceID = read("subsystem_device")
+	gpu.MarketingName = read("product_name")
+	gpu.LocalCPUList = read("local_cpulist")
+	gpu.ComputePartition = strings.ToLower(read("current_compute_partition"))
+	gpu.MemoryPartition = strings.ToLower(read("current_memory_partition"))
+
+	if v, err := strconv.Atoi(read("numa_node")); err == nil {
+		gpu.NUMANode = v
+	}
+
+	if gpu.VRAMSize == 0 {
+		if v, err := strconv.ParseUint(read("mem_info_vram_total"), 10, 64); err == nil {
+			gpu.VRAMSize = v
+		}
+	}
+}
+
+// FormatGfxTargetVersion returns the GPU architecture name of a KFD
+// gfx_target_version, e.g. gfx942 for 90402 and gfx90a for 90010
+func FormatGfxTargetVersion(version int) string {
+	major := version / 10000
+	minor := (version / 100) % 100
+	step := version % 100
+	return fmt.Sprintf("gfx%d%x%x", major, minor, step)
+}
+
+// readProperties parses a KFD topology properties file, one
+// "<name> <value>" entry per line
+func readProperties(fs FileSystem, path string) (map[string]string, error) {
+	content, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading topology properties from %s: %w", path, err)
+	}
+
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			props[fields[0]] = fields[1]
+		}
+	}
+
+	return props, nil
+}
@@ -0,0 +1,131 @@
+package amdgpu
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rootFS implements FileSystem on top of a fake sysfs tree under root
+type rootFS struct {
+	root string
+}
+
+func (r *rootFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(filepath.Join(r.root, name))
+}
+
+func (r *rootFS) Glob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(r.root, pattern))
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range matches {
+		matches[i] = strings.TrimPrefix(m, r.root)
+	}
+	return matches, nil
+}
+
+func (r *rootFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(r.root, name))
+}
+
+func (r *rootFS) GetDeviceStat(dev string) (DeviceStat, error) {
+	return DeviceStat{}, os.ErrNotExist
+}
+
+func TestGetGPUInventory(t *testing.T) {
+	root, err := filepath.Abs("../../tests/amdgpu/inventory")
+	assert.NoError(t, err)
+
+	gpus, err := GetGPUInventoryWithFS(&rootFS{root: root})
+	assert.NoError(t, err)
+
+	expected := []GPUInfo{
+		{
+			Index:             0,
+			DrmDevices:        []string{"/dev/dri/card1", "/dev/dri/renderD128"},
+			RenderMinor:       128,
+			PCIBusID:          "0000:05:00.0",
+			VendorID:          "0x1002",
+			DeviceID:          "0x74a1",
+			SubsystemVendorID: "0x1002",
+			SubsystemDeviceID: "0x74a1",
+			MarketingName:     "AMD Instinct MI300X",
+			GfxTargetVersion:  "gfx942",
+			UniqueID:          "0x1234567890abcdef",
+			KFDNodeID:         1,
+			NUMANode:          0,
+			LocalCPUList:      "0-47,96-143",
+			VRAMSize:          206141652992,
+			ComputePartition:  "spx",
+			MemoryPartition:   "nps1",
+			IOLinks:           []IOLink{{NodeTo: 0, Type: "pcie", Weight: 20}, {NodeTo: 2, Type: "pcie", Weight: 40}},
+		},
+		{
+			Index:             1,
+			DrmDevices:        []string{"/dev/dri/card2", "/dev/dri/renderD129"},
+			RenderMinor:       129,
+			PCIBusID:          "0000:85:00.0",
+			VendorID:          "0x1002",
+			DeviceID:          "0x74a1",
+			SubsystemVendorID: "0x1002",
+			SubsystemDeviceID: "0x74a1",
+			MarketingName:     "AMD Instinct MI300X",
+			GfxTargetVersion:  "gfx942",
+			UniqueID:          "0x89ad28434ab2622f",
+			KFDNodeID:         2,
+			NUMANode:          1,
+			LocalCPUList:      "48-95,144-191",
+			VRAMSize:          25769803776,
+			ComputePartition:  "cpx",
+			MemoryPartition:   "nps1",
+			HiveID:            "0x4252874d42efcdfd",
+			Parent:            "0000:85:00.0",
+			PartitionID:       0,
+			IOLinks:           []IOLink{{NodeTo: 0, Type: "pcie", Weight: 20}, {NodeTo: 3, Type: "xgmi", Weight: 15}},
+		},
+		{
+			Index:             2,
+			DrmDevices:        []string{"/dev/dri/card3", "/dev/dri/renderD130"},
+			RenderMinor:       130,
+			PCIBusID:          "0000:85:00.0",
+			VendorID:          "0x1002",
+			DeviceID:          "0x74a1",
+			SubsystemVendorID: "0x1002",
+			SubsystemDeviceID: "0x74a1",
+			MarketingName:     "AMD Instinct MI300X",
+			GfxTargetVersion:  "gfx942",
+			UniqueID:          "0x89ad28434ab2622f",
+			KFDNodeID:         3,
+			NUMANode:          1,
+			LocalCPUList:      "48-95,144-191",
+			VRAMSize:          25769803776,
+			ComputePartition:  "cpx",
+			MemoryPartition:   "nps1",
+			HiveID:            "0x4252874d42efcdfd",
+			Parent:            "0000:85:00.0",
+			PartitionID:       1,
+			IOLinks:           []IOLink{{NodeTo: 0, Type: "pcie", Weight: 20}, {NodeTo: 2, Type: "xgmi", Weight: 15}},
+		},
+	}
+
+	assert.Equal(t, expected, gpus)
+}
+
+func TestFormatGfxTargetVersion(t *testing.T) {
+	tests := map[int]string{
+		90402:  "gfx942",
+		90010:  "gfx90a",
+		90008:  "gfx908",
+		110000: "gfx1100",
+		120001: "gfx1201",
+	}
+
+	for version, expected := range tests {
+		assert.Equal(t, expected, FormatGfxTargetVersion(version))
+	}
+}
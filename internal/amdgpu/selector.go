@@ -0,0 +1,198 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package amdgpu
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GPU selectors of AMD_VISIBLE_DEVICES, written as <selector>:<value>
+const (
+	// Selects the GPUs of a NUMA node, e.g. numa:0
+	SELECTOR_NUMA = "numa"
+
+	// Selects the GPUs of an XGMI hive, e.g. xgmi-hive:0x4252874d42efcdfd
+	SELECTOR_XGMI_HIVE = "xgmi-hive"
+
+	// Selects the GPUs in a compute or memory partition mode, e.g. partition:cpx
+	SELECTOR_PARTITION = "partition"
+
+	// Selects the GPUs of an architecture, e.g. model:gfx942
+	SELECTOR_MODEL = "model"
+
+	// Selects a number of GPUs close to each other, e.g. count:4
+	SELECTOR_COUNT = "count"
+)
+
+// Distance tiers between two GPUs, refined by the weight of the KFD link
+// between them
+const (
+	distanceSameGPU   = 0
+	distanceSameHive  = 1000
+	distanceSameNUMA  = 2000
+	distanceOther     = 3000
+	distanceNoLink    = 999
+	distanceMaxWeight = 999
+)
+
+// ParseSelector splits a GPU selector into its name and value. ok is false
+// if s is not a selector, e.g. a GPU Id or a unique ID.
+func ParseSelector(s string) (name string, value string, ok bool) {
+	name, value, found := strings.Cut(s, ":")
+	if !found {
+		return "", "", false
+	}
+
+	switch strings.ToLower(name) {
+	case SELECTOR_NUMA, SELECTOR_XGMI_HIVE, SELECTOR_PARTITION, SELECTOR_MODEL, SELECTOR_COUNT:
+		return strings.ToLower(name), strings.TrimSpace(value), true
+	}
+
+	return "", "", false
+}
+
+// ParseCount returns the number of GPUs of a count selector value
+func ParseCount(value string) (int, error) {
+	count, err := strconv.Atoi(value)
+	if err != nil || count <= 0 {
+		return 0, fmt.Errorf("invalid GPU count %q", value)
+	}
+	return count, nil
+}
+
+// MatchSelector returns the GPU Ids of the GPUs matching a numa,
+// xgmi-hive, partition or model selector
+func MatchSelector(gpus []GPUInfo, name string, value string) ([]int, error) {
+	var match func(GPUInfo) bool
+
+	switch name {
+	case SELECTOR_NUMA:
+		node, err := strconv.Atoi(value)
+		if err != nil || node < 0 {
+			return nil, fmt.Errorf("invalid NUMA node %q", value)
+		}
+		match = func(gpu GPUInfo) bool { return gpu.NUMANode == node }
+	case SELECTOR_XGMI_HIVE:
+		hive, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(value), "0x"), 16, 64)
+		if err != nil || hive == 0 {
+			return nil, fmt.Errorf("invalid XGMI hive %q", value)
+		}
+		hiveID := fmt.Sprintf("0x%x", hive)
+		match = func(gpu GPUInfo) bool { return gpu.HiveID == hiveID }
+	case SELECTOR_PARTITION:
+		mode := strings.ToLower(value)
+		if mode == "" {
+			return nil, fmt.Errorf("missing partition mode")
+		}
+		match = func(gpu GPUInfo) bool { return gpu.ComputePartition == mode || gpu.MemoryPartition == mode }
+	case SELECTOR_MODEL:
+		model := strings.ToLower(value)
+		if model == "" {
+			return nil, fmt.Errorf("missing GPU model")
+		}
+		match = func(gpu GPUInfo) bool { return gpu.GfxTargetVersion == model }
+	default:
+		return nil, fmt.Errorf("unsupported GPU selector %q", name)
+	}
+
+	ret := []int{}
+	for _, gpu := range gpus {
+		if match(gpu) {
+			ret = append(ret, gpu.Index)
+		}
+	}
+
+	return ret, nil
+}
+
+// Distance returns the relative distance between two GPUs. Partitions of
+// the same physical GPU are the closest, then GPUs of the same XGMI hive,
+// then GPUs of the same NUMA node. Within a tier, GPUs with a lower KFD
+// link weight are closer.
+func Distance(a GPUInfo, b GPUInfo) int {
+	if a.PCIBusID != "" && a.PCIBusID == b.PCIBusID {
+		return distanceSameGPU
+	}
+
+	weight := distanceNoLink
+	for _, link := range a.IOLinks {
+		if link.NodeTo == b.KFDNodeID && link.Weight < weight {
+			weight = min(link.Weight, distanceMaxWeight)
+		}
+	}
+
+	switch {
+	case a.HiveID != "" && a.HiveID == b.HiveID:
+		return distanceSameHive + weight
+	case a.NUMANode >= 0 && a.NUMANode == b.NUMANode:
+		return distanceSameNUMA + weight
+	default:
+		return distanceOther + weight
+	}
+}
+
+// PickClosestGPUs returns count GPU Ids among candidates, picking the
+// GPUs with the lowest total distance between each other. Ties are
+// broken by the lowest GPU Ids.
+func PickClosestGPUs(gpus []GPUInfo, candidates []int, count int) ([]int, error) {
+	if count > len(candidates) {
+		return nil, fmt.Errorf("%d GPUs requested, only %d available", count, len(candidates))
+	}
+
+	byId := make(map[int]GPUInfo)
+	for _, gpu := range gpus {
+		byId[gpu.Index] = gpu
+	}
+	candidates = slices.Clone(candidates)
+	sort.Ints(candidates)
+
+	var best []int
+	bestCost := math.MaxInt
+	for _, seed := range candidates {
+		picked := []int{seed}
+		cost := 0
+		for len(picked) < count {
+			next, nextCost := -1, math.MaxInt
+			for _, c := range candidates {
+				if slices.Contains(picked, c) {
+					continue
+				}
+				d := 0
+				for _, p := range picked {
+					d += Distance(byId[p], byId[c])
+				}
+				if d < nextCost {
+					next, nextCost = c, d
+				}
+			}
+			picked = append(picked, next)
+			cost += nextCost
+		}
+
+		if cost < bestCost {
+			best, bestCost = picked, cost
+		}
+	}
+
+	sort.Ints(best)
+	return best, nil
+}
@@ -0,0 +1,128 @@
+package amdgpu
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestInventory(t *testing.T) []GPUInfo {
+	root, err := filepath.Abs("../../tests/amdgpu/inventory")
+	assert.NoError(t, err)
+
+	gpus, err := GetGPUInventoryWithFS(&rootFS{root: root})
+	assert.NoError(t, err)
+	assert.Len(t, gpus, 3)
+
+	return gpus
+}
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+		value string
+		ok    bool
+	}{
+		{"numa:0", SELECTOR_NUMA, "0", true},
+		{"NUMA:1", SELECTOR_NUMA, "1", true},
+		{"xgmi-hive:0x4252874d42efcdfd", SELECTOR_XGMI_HIVE, "0x4252874d42efcdfd", true},
+		{"partition:cpx", SELECTOR_PARTITION, "cpx", true},
+		{"model:gfx942", SELECTOR_MODEL, "gfx942", true},
+		{"count:4", SELECTOR_COUNT, "4", true},
+		{"0", "", "", false},
+		{"0-3", "", "", false},
+		{"0x1234567890abcdef", "", "", false},
+		{"foo:bar", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			name, value, ok := ParseSelector(tt.input)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.name, name)
+			assert.Equal(t, tt.value, value)
+		})
+	}
+}
+
+func TestMatchSelector(t *testing.T) {
+	gpus := getTestInventory(t)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected []int
+		wantErr  bool
+	}{
+		{SELECTOR_NUMA, "0", []int{0}, false},
+		{SELECTOR_NUMA, "1", []int{1, 2}, false},
+		{SELECTOR_NUMA, "2", []int{}, false},
+		{SELECTOR_NUMA, "x", nil, true},
+		{SELECTOR_XGMI_HIVE, "0x4252874D42EFCDFD", []int{1, 2}, false},
+		{SELECTOR_XGMI_HIVE, "4252874d42efcdfd", []int{1, 2}, false},
+		{SELECTOR_XGMI_HIVE, "0", nil, true},
+		{SELECTOR_PARTITION, "cpx", []int{1, 2}, false},
+		{SELECTOR_PARTITION, "NPS1", []int{0, 1, 2}, false},
+		{SELECTOR_MODEL, "gfx942", []int{0, 1, 2}, false},
+		{SELECTOR_MODEL, "gfx90a", []int{}, false},
+		{SELECTOR_COUNT, "2", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+":"+tt.value, func(t *testing.T) {
+			ids, err := MatchSelector(gpus, tt.name, tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func TestParseCount(t *testing.T) {
+	count, err := ParseCount("4")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	for _, v := range []string{"0", "-1", "four", ""} {
+		_, err := ParseCount(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestPickClosestGPUs(t *testing.T) {
+	gpus := getTestInventory(t)
+
+	// Partitions of the same GPU are closer than GPUs linked through PCIe
+	assert.Less(t, Distance(gpus[1], gpus[2]), Distance(gpus[0], gpus[1]))
+
+	tests := []struct {
+		name       string
+		candidates []int
+		count      int
+		expected   []int
+		wantErr    bool
+	}{
+		{"pick partitions of the same GPU", []int{0, 1, 2}, 2, []int{1, 2}, false},
+		{"single GPU", []int{0, 1, 2}, 1, []int{0}, false},
+		{"all GPUs", []int{2, 0, 1}, 3, []int{0, 1, 2}, false},
+		{"only free GPUs", []int{0, 2}, 2, []int{0, 2}, false},
+		{"not enough GPUs", []int{0, 2}, 3, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := PickClosestGPUs(gpus, tt.candidates, tt.count)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
@@ -17,14 +17,19 @@
 package cdi
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/ROCm/container-toolkit/internal/amdgpu"
+	"github.com/ROCm/container-toolkit/internal/config"
+	"github.com/ROCm/container-toolkit/internal/rocm"
 	"tags.cncf.io/container-device-interface/specs-go"
 )
 
@@ -32,6 +37,12 @@ import (
 const (
 	// Default full CDI spec path
 	CDI_SPEC_PATH = "/var/run/cdi/amd.json"
+
+	// Kind of the AMD GPU CDI devices
+	CDI_KIND = "amd.com/gpu"
+
+	// Annotation recording the driver capabilities the spec was generated with
+	CAPABILITIES_ANNOTATION = "amd.com/driver-capabilities"
 )
 
 // GetGPUs is the type for functions that return the lists of all the GPU devices on the system
@@ -56,6 +67,14 @@ type Interface interface {
 
 	// ValidateSpec validated the existing CDI spec on the disk
 	ValidateSpec() (bool, error)
+
+	// GetContainerEdits returns the combined container edits of the given
+	// devices in the existing CDI spec on the disk
+	GetContainerEdits(devices []string) (*specs.ContainerEdits, error)
+
+	// SetDriverCapabilities selects the host ROCm libraries and tools
+	// mounted by the generated spec
+	SetDriverCapabilities(caps rocm.Capabilities)
 }
 
 // cdi_t implements the CDI interface
@@ -71,6 +90,12 @@ type cdi_t struct {
 
 	// getGPU is the function that returns the device info of the given GPU
 	getGPU GetGPU
+
+	// rocm discovers the host ROCm files mounted by the spec
+	rocm rocm.Interface
+
+	// caps are the driver capabilities the spec is generated with
+	caps rocm.Capabilities
 }
 
 func readSpecFromFile(f string) (*specs.Spec, error) {
@@ -157,9 +182,47 @@ func (cdi *cdi_t) GenerateSpec() error {
 	cdiDevs = append(cdiDevs, allCdiDev)
 	cdi.spec.Devices = cdiDevs
 
+	return cdi.addROCmEdits()
+}
+
+// addROCmEdits adds the container edits mounting the host ROCm files of the
+// selected driver capabilities to the spec, common to all the devices
+func (cdi *cdi_t) addROCmEdits() error {
+	cdi.spec.ContainerEdits = specs.ContainerEdits{}
+	delete(cdi.spec.Annotations, CAPABILITIES_ANNOTATION)
+	if cdi.caps.IsEmpty() {
+		return nil
+	}
+
+	files, err := cdi.rocm.Discover(cdi.caps)
+	if err != nil {
+		return fmt.Errorf("discovering ROCm files: %w", err)
+	}
+
+	for _, f := range files.All() {
+		cdi.spec.ContainerEdits.Mounts = append(cdi.spec.ContainerEdits.Mounts, &specs.Mount{
+			HostPath:      f,
+			ContainerPath: f,
+			Type:          "bind",
+			Options:       []string{"bind", "ro", "nosuid", "nodev"},
+		})
+	}
+	if len(files.Libraries) > 0 {
+		cdi.spec.ContainerEdits.Env = []string{"LD_LIBRARY_PATH=" + strings.Join(files.LibDirs(), ":")}
+	}
+
+	if cdi.spec.Annotations == nil {
+		cdi.spec.Annotations = map[string]string{}
+	}
+	cdi.spec.Annotations[CAPABILITIES_ANNOTATION] = cdi.caps.String()
+
 	return nil
 }
 
+func (cdi *cdi_t) SetDriverCapabilities(caps rocm.Capabilities) {
+	cdi.caps = caps
+}
+
 func (cdi *cdi_t) GetSpec() specs.Spec {
 	return cdi.spec
 }
@@ -172,20 +235,20 @@ func (cdi *cdi_t) WriteSpec() error {
 		}
 	}
 
-	file, err := os.Create(cdi.specPath)
-	if err != nil {
-		return fmt.Errorf("creating CDI spec file %s: %w", cdi.specPath, err)
-	}
-
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
 	encoder.SetIndent("", "  ")
 	if err := encoder.Encode(cdi.spec); err != nil {
 		return fmt.Errorf("encoding CDI spec to %s: %w", cdi.specPath, err)
 	}
 
-	return nil
+	if err := os.WriteFile(cdi.specPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("creating CDI spec file %s: %w", cdi.specPath, err)
+	}
+
+	// The manifest lets runtime configure --remove delete the spec
+	// without touching the specs of other vendors
+	return RecordSpec(cdi.specPath, buf.Bytes())
 }
 
 func (cdi *cdi_t) FormatSpec() (string, error) {
@@ -203,6 +266,13 @@ func (cdi *cdi_t) ValidateSpec() (bool, error) {
 		return false, err
 	}
 
+	// Regenerate with the driver capabilities of the saved spec
+	caps, err := rocm.ParseCapabilities(savedCDISpec.Annotations[CAPABILITIES_ANNOTATION])
+	if err != nil {
+		return false, fmt.Errorf("parsing %s annotation: %w", CAPABILITIES_ANNOTATION, err)
+	}
+	cdi.SetDriverCapabilities(caps)
+
 	err = cdi.GenerateSpec()
 	if err != nil {
 		return false, err
@@ -211,14 +281,78 @@ func (cdi *cdi_t) ValidateSpec() (bool, error) {
 	return reflect.DeepEqual(*savedCDISpec, cdi.spec), nil
 }
 
+// ParseDeviceName returns the device name of a fully qualified CDI device
+// name, e.g. "0" for "amd.com/gpu=0". Unqualified names are returned as is.
+func ParseDeviceName(name string) (string, error) {
+	parts := strings.SplitN(name, "=", 2)
+	if len(parts) == 1 {
+		return name, nil
+	}
+	if parts[0] != CDI_KIND {
+		return "", fmt.Errorf("unsupported CDI device kind %q in %q", parts[0], name)
+	}
+	if parts[1] == "" {
+		return "", fmt.Errorf("missing device name in %q", name)
+	}
+
+	return parts[1], nil
+}
+
+func (cdi *cdi_t) GetContainerEdits(devices []string) (*specs.ContainerEdits, error) {
+	savedCDISpec, err := readSpecFromFile(cdi.specPath)
+	if err != nil {
+		return nil, err
+	}
+	if savedCDISpec.Kind != CDI_KIND {
+		return nil, fmt.Errorf("unexpected kind %q in CDI spec file %s", savedCDISpec.Kind, cdi.specPath)
+	}
+
+	edits := &specs.ContainerEdits{}
+	addEdits := func(e specs.ContainerEdits) {
+		for _, dn := range e.DeviceNodes {
+			if !slices.ContainsFunc(edits.DeviceNodes, func(d *specs.DeviceNode) bool { return d.Path == dn.Path }) {
+				edits.DeviceNodes = append(edits.DeviceNodes, dn)
+			}
+		}
+		for _, env := range e.Env {
+			if !slices.Contains(edits.Env, env) {
+				edits.Env = append(edits.Env, env)
+			}
+		}
+		edits.Mounts = append(edits.Mounts, e.Mounts...)
+		edits.Hooks = append(edits.Hooks, e.Hooks...)
+	}
+
+	addEdits(savedCDISpec.ContainerEdits)
+	for _, name := range devices {
+		devName, err := ParseDeviceName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		idx := slices.IndexFunc(savedCDISpec.Devices, func(d specs.Device) bool { return d.Name == devName })
+		if idx < 0 {
+			return nil, fmt.Errorf("CDI device %s=%s not found in %s", CDI_KIND, devName, cdi.specPath)
+		}
+		addEdits(savedCDISpec.Devices[idx].ContainerEdits)
+	}
+
+	return edits, nil
+}
+
 func New(sp string) (Interface, error) {
 	if sp == "" {
 		sp = CDI_SPEC_PATH
 	}
 
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+
 	spec := specs.Spec{
 		Version: "0.6.0",
-		Kind:    "amd.com/gpu",
+		Kind:    CDI_KIND,
 		Devices: []specs.Device{},
 	}
 
@@ -227,6 +361,7 @@ func New(sp string) (Interface, error) {
 		specPath: sp,
 		getGPUs:  amdgpu.GetAMDGPUs,
 		getGPU:   amdgpu.GetAMDGPU,
+		rocm:     rocm.New(cfg.ROCm.Path),
 	}
 
 	return cdi, nil
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/ROCm/container-toolkit/internal/amdgpu"
+	"github.com/ROCm/container-toolkit/internal/rocm"
 	"github.com/stretchr/testify/assert"
 	"tags.cncf.io/container-device-interface/specs-go"
 )
@@ -175,8 +176,164 @@ func TestWriteSpec_OverwritesExisting(t *testing.T) {
 	assert.Equal(t, second, *read, "file should contain second spec after overwrite")
 }
 
+func TestGetContainerEdits(t *testing.T) {
+	specPath := filepath.Join(t.TempDir(), "amd.json")
+
+	cdi := &cdi_t{
+		spec:     specs.Spec{Version: "0.6.0", Kind: CDI_KIND},
+		specPath: specPath,
+		getGPUs:  mockGetAMDGPUs,
+		getGPU:   mockGetAMDGPU,
+	}
+	assert.NoError(t, cdi.GenerateSpec())
+	assert.NoError(t, cdi.WriteSpec())
+
+	edits, err := cdi.GetContainerEdits([]string{"amd.com/gpu=1", "0"})
+	assert.NoError(t, err)
+	paths := []string{}
+	for _, dn := range edits.DeviceNodes {
+		paths = append(paths, dn.Path)
+	}
+	assert.Equal(t, []string{"/dev/dri/renderD129", "/dev/dri/card2", "/dev/kfd", "/dev/dri/renderD128", "/dev/dri/card1"}, paths,
+		"device nodes should be merged without duplicates")
+
+	_, err = cdi.GetContainerEdits([]string{"amd.com/gpu=7"})
+	assert.Error(t, err, "unknown device should fail")
+
+	_, err = cdi.GetContainerEdits([]string{"nvidia.com/gpu=0"})
+	assert.Error(t, err, "foreign device kind should fail")
+
+	cdi.specPath = filepath.Join(t.TempDir(), "missing.json")
+	_, err = cdi.GetContainerEdits([]string{"0"})
+	assert.Error(t, err, "missing spec file should fail")
+}
+
+func TestParseDeviceName(t *testing.T) {
+	name, err := ParseDeviceName("amd.com/gpu=all")
+	assert.NoError(t, err)
+	assert.Equal(t, "all", name)
+
+	name, err = ParseDeviceName("3")
+	assert.NoError(t, err)
+	assert.Equal(t, "3", name)
+
+	_, err = ParseDeviceName("amd.com/gpu=")
+	assert.Error(t, err)
+}
+
+func TestGenerateSpecWithCapabilities(t *testing.T) {
+	root := t.TempDir()
+	lib := filepath.Join(root, "opt", "rocm", "lib", "libamdhip64.so.6")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(lib), 0755))
+	assert.NoError(t, os.WriteFile(lib, []byte{}, 0644))
+
+	specPath := filepath.Join(t.TempDir(), "amd.json")
+	cdi := &cdi_t{
+		spec:     specs.Spec{Version: "0.6.0", Kind: CDI_KIND},
+		specPath: specPath,
+		getGPUs:  mockGetAMDGPUs,
+		getGPU:   mockGetAMDGPU,
+		rocm:     rocm.NewWithRoot(root, "/opt/rocm"),
+	}
+	cdi.SetDriverCapabilities(rocm.Capabilities{Compute: true})
+	assert.NoError(t, cdi.GenerateSpec())
+
+	edits := cdi.GetSpec().ContainerEdits
+	assert.Len(t, edits.Mounts, 1)
+	assert.Equal(t, "/opt/rocm/lib/libamdhip64.so.6", edits.Mounts[0].ContainerPath)
+	assert.Equal(t, []string{"LD_LIBRARY_PATH=/opt/rocm/lib"}, edits.Env)
+	assert.Equal(t, "compute", cdi.GetSpec().Annotations[CAPABILITIES_ANNOTATION])
+	assert.NoError(t, cdi.WriteSpec())
+
+	// Validation regenerates the spec with the saved capabilities
+	cdi.SetDriverCapabilities(rocm.Capabilities{})
+	valid, err := cdi.ValidateSpec()
+	assert.NoError(t, err)
+	assert.True(t, valid, "spec generated with capabilities should be valid")
+}
+
 func Assert(t *testing.T, b bool, errString string) {
 	if !b {
 		t.Errorf(errString)
 	}
 }
+
+func TestWriteSpec_RecordsManifest(t *testing.T) {
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "amd.json")
+
+	cdi := &cdi_t{
+		spec:     dummySpec,
+		specPath: specPath,
+		getGPUs:  mockGetAMDGPUs,
+		getGPU:   mockGetAMDGPU,
+	}
+	assert.NoError(t, cdi.WriteSpec(), "first WriteSpec")
+	assert.NoError(t, cdi.WriteSpec(), "second WriteSpec")
+
+	data, err := os.ReadFile(specPath)
+	assert.NoError(t, err)
+	m, err := LoadManifest(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []ManifestEntry{{Name: "amd.json", SHA256: checksum(data)}}, m.Entries(), "spec should be listed once")
+
+	// The manifest is in the format of sha256sum
+	manifest, err := os.ReadFile(ManifestPath(dir))
+	assert.NoError(t, err)
+	assert.Equal(t, checksum(data)+"  amd.json\n", string(manifest))
+}
+
+func TestRemoveSpecs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	write("amd.json", "amd")
+	write("changed.json", "changed")
+	write("nvidia.yaml", "nvidia")
+	write("target.json", "target")
+	assert.NoError(t, os.Symlink(filepath.Join(dir, "target.json"), filepath.Join(dir, "link.json")))
+	assert.NoError(t, RecordSpec(filepath.Join(dir, "amd.json"), []byte("amd")))
+	assert.NoError(t, RecordSpec(filepath.Join(dir, "changed.json"), []byte("original")))
+	assert.NoError(t, RecordSpec(filepath.Join(dir, "link.json"), []byte("target")))
+	assert.NoError(t, RecordSpec(filepath.Join(dir, "gone.json"), []byte("gone")))
+
+	removed, skipped, err := RemoveSpecs(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "amd.json")}, removed)
+	assert.Equal(t, []SkippedSpec{
+		{Path: filepath.Join(dir, "changed.json"), Reason: "it was changed since amd-ctk created it"},
+		{Path: filepath.Join(dir, "link.json"), Reason: "it was not created by amd-ctk"},
+	}, skipped)
+
+	for _, name := range []string{"changed.json", "nvidia.yaml", "target.json", "link.json"} {
+		_, err := os.Lstat(filepath.Join(dir, name))
+		assert.NoError(t, err, "%s should not be removed", name)
+	}
+	_, err = os.Stat(ManifestPath(dir))
+	assert.True(t, os.IsNotExist(err), "the manifest should be removed once empty")
+
+	// Nothing is removed without a manifest
+	removed, skipped, err = RemoveSpecs(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+	assert.Empty(t, skipped)
+	_, err = os.Stat(filepath.Join(dir, "nvidia.yaml"))
+	assert.NoError(t, err)
+}
+
+func TestLoadManifest_Invalid(t *testing.T) {
+	for _, content := range []string{
+		"not a manifest\n",
+		fmt.Sprintf("%064d  ../outside.json\n", 0),
+		fmt.Sprintf("%064d  /etc/cdi/amd.json\n", 0),
+	} {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(ManifestPath(dir), []byte(content), 0644))
+		_, _, err := RemoveSpecs(dir)
+		assert.Error(t, err, "manifest %q should be rejected", content)
+	}
+
+	assert.Error(t, RecordSpec(filepath.Join(t.TempDir(), MANIFEST_FILE), []byte{}), "the manifest cannot list itself")
+}
@@ -0,0 +1,211 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the \"License\");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an \"AS IS\" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package cdi
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Name of the manifest of the CDI specs written by amd-ctk in a directory.
+// It is in the format of sha256sum, and has no .json or .yaml extension
+// so that CDI does not read it as a spec.
+const MANIFEST_FILE = ".amd-ctk.sha256"
+
+// ManifestEntry is a CDI spec written by amd-ctk
+type ManifestEntry struct {
+	// Name is the name of the spec file in the directory of the manifest
+	Name string
+
+	// SHA256 is the checksum of the spec as written by amd-ctk
+	SHA256 string
+}
+
+// SkippedSpec is a spec listed in the manifest that was not removed
+type SkippedSpec struct {
+	// Path is the path of the spec
+	Path string
+
+	// Reason tells why the spec was not removed
+	Reason string
+}
+
+// Manifest lists the CDI specs written by amd-ctk in a directory, so
+// that they can be removed without touching the specs of other vendors
+type Manifest struct {
+	dir     string
+	entries []ManifestEntry
+}
+
+// ManifestPath returns the path of the manifest of a directory
+func ManifestPath(dir string) string {
+	return filepath.Join(dir, MANIFEST_FILE)
+}
+
+// LoadManifest reads the manifest of a directory. The manifest is empty
+// if amd-ctk has not written any spec to the directory.
+func LoadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{dir: dir}
+
+	data, err := os.ReadFile(ManifestPath(dir))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading CDI manifest %s: %w", ManifestPath(dir), err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok || len(sum) != sha256.Size*2 || !validName(name) {
+			return nil, fmt.Errorf("invalid line in CDI manifest %s: %q", ManifestPath(dir), line)
+		}
+		m.entries = append(m.entries, ManifestEntry{Name: name, SHA256: sum})
+	}
+	return m, nil
+}
+
+// Entries returns the specs listed in the manifest
+func (m *Manifest) Entries() []ManifestEntry {
+	return slices.Clone(m.entries)
+}
+
+// Add records a spec written to the directory of the manifest
+func (m *Manifest) Add(name string, data []byte) error {
+	if !validName(name) {
+		return fmt.Errorf("invalid CDI spec name for the manifest: %q", name)
+	}
+	m.forget(name)
+	m.entries = append(m.entries, ManifestEntry{Name: name, SHA256: checksum(data)})
+	return nil
+}
+
+// Save writes the manifest, or deletes it once it lists no spec
+func (m *Manifest) Save() error {
+	path := ManifestPath(m.dir)
+	if len(m.entries) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing CDI manifest %s: %w", path, err)
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, e := range m.entries {
+		fmt.Fprintf(&buf, "%s  %s\n", e.SHA256, e.Name)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing CDI manifest %s: %w", path, err)
+	}
+	return nil
+}
+
+// RemoveSpecs deletes the specs listed in the manifest and returns their
+// paths. A spec replaced or changed since amd-ctk wrote it is not deleted
+// but returned as skipped, and is dropped from the manifest as it no
+// longer belongs to amd-ctk.
+func (m *Manifest) RemoveSpecs() ([]string, []SkippedSpec, error) {
+	removed := []string{}
+	skipped := []SkippedSpec{}
+	for _, e := range m.Entries() {
+		path := filepath.Join(m.dir, e.Name)
+
+		fi, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			m.forget(e.Name)
+			continue
+		}
+		if err != nil {
+			return removed, skipped, fmt.Errorf("reading CDI spec %s: %w", path, err)
+		}
+
+		if !fi.Mode().IsRegular() {
+			skipped = append(skipped, SkippedSpec{Path: path, Reason: "it was not created by amd-ctk"})
+			m.forget(e.Name)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return removed, skipped, fmt.Errorf("reading CDI spec %s: %w", path, err)
+		}
+		if checksum(data) != e.SHA256 {
+			skipped = append(skipped, SkippedSpec{Path: path, Reason: "it was changed since amd-ctk created it"})
+			m.forget(e.Name)
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return removed, skipped, fmt.Errorf("removing CDI spec %s: %w", path, err)
+		}
+		m.forget(e.Name)
+		removed = append(removed, path)
+	}
+	return removed, skipped, m.Save()
+}
+
+// RecordSpec adds a spec written by amd-ctk to the manifest of its
+// directory
+func RecordSpec(path string, data []byte) error {
+	m, err := LoadManifest(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if err := m.Add(filepath.Base(path), data); err != nil {
+		return err
+	}
+	return m.Save()
+}
+
+// RemoveSpecs deletes the specs written by amd-ctk to a directory, see
+// Manifest.RemoveSpecs
+func RemoveSpecs(dir string) ([]string, []SkippedSpec, error) {
+	m, err := LoadManifest(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m.RemoveSpecs()
+}
+
+// forget drops a spec from the manifest
+func (m *Manifest) forget(name string) {
+	m.entries = slices.DeleteFunc(m.entries, func(e ManifestEntry) bool { return e.Name == name })
+}
+
+// validName reports whether a spec name can be listed in the manifest,
+// i.e. is a plain file name of the directory of the manifest
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && name != MANIFEST_FILE &&
+		!strings.ContainsAny(name, "/\n\\")
+}
+
+// checksum returns the hex encoded SHA-256 of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,448 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ROCm/container-toolkit/internal/rootless"
+	"github.com/pelletier/go-toml"
+)
+
+// Constants
+const (
+	// Default path of the AMD Container Toolkit config file
+	DEFAULT_CONFIG_PATH = "/etc/amd-container-toolkit/config.toml"
+
+	// Environment variable that overrides the config file path
+	CONFIG_PATH_ENV = "AMD_CTK_CONFIG"
+
+	// Prefix of the environment variables that override config keys. The
+	// variable of a key is the prefix followed by the key in upper case,
+	// with "." and "-" replaced by "_", e.g. AMD_CTK_GPU_TRACKER_FILE.
+	ENV_PREFIX = "AMD_CTK_"
+
+	// Version of the config file schema written by this release
+	CURRENT_VERSION = 1
+
+	// Root directory of the container states of the amd runtime registered
+	// with CRI-O, passed to the low-level runtime as its root
+	CRIO_RUNTIME_ROOT = "/run/amd-container-runtime"
+
+	// Runtime mode that builds the GPU devices from sysfs on every container create
+	RUNTIME_MODE_LEGACY = "legacy"
+
+	// Runtime mode that applies the GPU devices of the CDI spec on the disk
+	RUNTIME_MODE_CDI = "cdi"
+
+	// Runtime mode that adds amd-container-runtime-hook to the OCI spec,
+	// which sets up the GPUs when the container is created
+	RUNTIME_MODE_HOOK = "hook"
+)
+
+// RuntimeConfig holds the settings of amd-container-runtime
+type RuntimeConfig struct {
+	// Runtimes is the ordered list of low-level runtime candidates. Each
+	// entry is either an absolute path or a name looked up in PATH.
+	Runtimes []string `toml:"runtimes"`
+
+	// LogDir is the directory of the runtime log files. Rootless users
+	// default to the state directory of the toolkit under XDG_STATE_HOME.
+	LogDir string `toml:"log-dir"`
+
+	// Mode selects how GPU devices are injected into containers, either
+	// "legacy", "cdi" or "hook"
+	Mode string `toml:"mode"`
+
+	// CDISpecPath is the CDI spec applied in the "cdi" mode
+	CDISpecPath string `toml:"cdi-spec-path"`
+}
+
+// HookConfig holds the settings of amd-container-runtime-hook
+type HookConfig struct {
+	// Path is where the OCI hook executable is on the disk
+	Path string `toml:"path"`
+}
+
+// ROCmConfig holds the settings of the host ROCm installation
+type ROCmConfig struct {
+	// Path is the ROCm installation directory whose user-space libraries
+	// and tools are mounted into containers
+	Path string `toml:"path"`
+}
+
+// AmdCtkConfig holds the settings of amd-ctk
+type AmdCtkConfig struct {
+	// Path is where the amd-ctk executable is on the disk
+	Path string `toml:"path"`
+
+	// BackupDir is the directory of the backups of the container engine
+	// configuration files, kept in a subdirectory per engine. Rootless
+	// users default to the state directory of the toolkit.
+	BackupDir string `toml:"backup-dir"`
+}
+
+// GPUTrackerConfig holds the settings of the GPU Tracker
+type GPUTrackerConfig struct {
+	// File is the path to the GPU Tracker state file
+	File string `toml:"file"`
+
+	// LockFile is the path to the GPU Tracker lock file
+	LockFile string `toml:"lock-file"`
+
+	// RuntimeRoots are the state directories of the low-level runtimes,
+	// where a container that still exists has a directory named after its
+	// ID, either directly or under a namespace directory
+	RuntimeRoots []string `toml:"runtime-roots"`
+}
+
+// Config is the AMD Container Toolkit configuration
+type Config struct {
+	// Version is the schema version of the config file
+	Version int `toml:"version"`
+
+	// Runtime holds the settings of amd-container-runtime
+	Runtime RuntimeConfig `toml:"runtime"`
+
+	// Hook holds the settings of amd-container-runtime-hook
+	Hook HookConfig `toml:"hook"`
+
+	// AmdCtk holds the settings of amd-ctk
+	AmdCtk AmdCtkConfig `toml:"amd-ctk"`
+
+	// GPUTracker holds the settings of the GPU Tracker
+	GPUTracker GPUTrackerConfig `toml:"gpu-tracker"`
+
+	// ROCm holds the settings of the host ROCm installation
+	ROCm ROCmConfig `toml:"rocm"`
+}
+
+// configPath is the config file path set through SetPath
+var configPath string
+
+// isRootless reports whether the process runs without the privileges of
+// the host root user
+var isRootless = rootless.IsRootless
+
+// stateDir returns the directory of the toolkit state of a rootless user
+var stateDir = rootless.StateDir
+
+// SetPath sets the config file path, taking precedence over the
+// environment and the default path
+func SetPath(path string) {
+	configPath = path
+}
+
+// Path returns the path of the config file
+func Path() string {
+	if configPath != "" {
+		return configPath
+	}
+	if p := os.Getenv(CONFIG_PATH_ENV); p != "" {
+		return p
+	}
+	return DEFAULT_CONFIG_PATH
+}
+
+// Default returns the default configuration
+func Default() *Config {
+	return &Config{
+		Version: CURRENT_VERSION,
+		Runtime: RuntimeConfig{
+			Runtimes:    []string{"runc", "crun"},
+			LogDir:      "/var/log",
+			Mode:        RUNTIME_MODE_LEGACY,
+			CDISpecPath: "/etc/cdi/amd.json",
+		},
+		Hook: HookConfig{
+			Path: "/usr/local/bin/amd-container-runtime-hook",
+		},
+		AmdCtk: AmdCtkConfig{
+			Path:      "/usr/local/bin/amd-ctk",
+			BackupDir: "/var/lib/amd-container-toolkit/backups",
+		},
+		GPUTracker: GPUTrackerConfig{
+			File:     "/var/log/gpu-tracker.json",
+			LockFile: "/var/log/gpu-tracker.lock",
+			RuntimeRoots: []string{
+				"/run/runc",
+				"/run/crun",
+				"/run/docker/runtime-runc",
+				"/run/containerd/runc",
+				CRIO_RUNTIME_ROOT,
+			},
+		},
+		ROCm: ROCmConfig{
+			Path: "/opt/rocm",
+		},
+	}
+}
+
+// LoadFile reads the config file at path on top of the defaults, without
+// applying the environment overrides. A missing config file is not an error.
+func LoadFile(path string) (*Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("reading config file %s: %w", path, err)
+	}
+
+	if err := toml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("decoding config file %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Load reads the config file and applies the environment overrides
+func Load() (*Config, error) {
+	cfg, err := LoadFile(Path())
+	if err != nil {
+		return nil, err
+	}
+
+	if isRootless() {
+		if err := cfg.applyRootlessDefaults(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range Keys() {
+		if v, ok := os.LookupEnv(EnvName(key)); ok && v != "" {
+			if err := cfg.Set(key, v); err != nil {
+				return nil, fmt.Errorf("applying %s: %w", EnvName(key), err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// applyRootlessDefaults moves the files a rootless user cannot write under
+// /var/log to the state directory of the user. Paths set in the config file
+// are kept.
+func (cfg *Config) applyRootlessDefaults() error {
+	defaults := Default()
+	files := []struct {
+		value        *string
+		defaultValue string
+		name         string
+	}{
+		{&cfg.Runtime.LogDir, defaults.Runtime.LogDir, ""},
+		{&cfg.AmdCtk.BackupDir, defaults.AmdCtk.BackupDir, filepath.Base(defaults.AmdCtk.BackupDir)},
+		{&cfg.GPUTracker.File, defaults.GPUTracker.File, filepath.Base(defaults.GPUTracker.File)},
+		{&cfg.GPUTracker.LockFile, defaults.GPUTracker.LockFile, filepath.Base(defaults.GPUTracker.LockFile)},
+	}
+
+	dir := ""
+	for _, f := range files {
+		if *f.value != f.defaultValue {
+			continue
+		}
+		if dir == "" {
+			var err error
+			if dir, err = stateDir(); err != nil {
+				return err
+			}
+		}
+		*f.value = filepath.Join(dir, f.name)
+	}
+	return nil
+}
+
+// Save writes the config to the file at path
+func (cfg *Config) Save(path string) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	data, err := cfg.Format()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", path, err)
+	}
+
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		return fmt.Errorf("writing config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Format returns the config as a TOML document
+func (cfg *Config) Format() (string, error) {
+	data, err := toml.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("encoding config: %w", err)
+	}
+	return string(data), nil
+}
+
+// Validate checks the config for unsupported versions and invalid values
+func (cfg *Config) Validate() error {
+	if cfg.Version < 1 || cfg.Version > CURRENT_VERSION {
+		return fmt.Errorf("unsupported config version %d", cfg.Version)
+	}
+
+	if len(cfg.Runtime.Runtimes) == 0 {
+		return fmt.Errorf("runtime.runtimes: no low-level runtimes configured")
+	}
+	for _, r := range cfg.Runtime.Runtimes {
+		if strings.TrimSpace(r) == "" {
+			return fmt.Errorf("runtime.runtimes: empty runtime name")
+		}
+	}
+
+	switch cfg.Runtime.Mode {
+	case RUNTIME_MODE_LEGACY, RUNTIME_MODE_CDI, RUNTIME_MODE_HOOK:
+	default:
+		return fmt.Errorf("runtime.mode: %q must be either %q, %q or %q", cfg.Runtime.Mode, RUNTIME_MODE_LEGACY, RUNTIME_MODE_CDI, RUNTIME_MODE_HOOK)
+	}
+
+	paths := map[string]string{
+		"runtime.log-dir":       cfg.Runtime.LogDir,
+		"runtime.cdi-spec-path": cfg.Runtime.CDISpecPath,
+		"hook.path":             cfg.Hook.Path,
+		"amd-ctk.path":          cfg.AmdCtk.Path,
+		"amd-ctk.backup-dir":    cfg.AmdCtk.BackupDir,
+		"gpu-tracker.file":      cfg.GPUTracker.File,
+		"gpu-tracker.lock-file": cfg.GPUTracker.LockFile,
+		"rocm.path":             cfg.ROCm.Path,
+	}
+	for _, key := range Keys() {
+		if p, exists := paths[key]; exists && !filepath.IsAbs(p) {
+			return fmt.Errorf("%s: %q is not an absolute path", key, p)
+		}
+	}
+	for _, p := range cfg.GPUTracker.RuntimeRoots {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("gpu-tracker.runtime-roots: %q is not an absolute path", p)
+		}
+	}
+
+	return nil
+}
+
+// EnvName returns the environment variable that overrides the given key
+func EnvName(key string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// Keys returns the dotted names of all the config keys
+func Keys() []string {
+	keys := []string{}
+	walkFields(reflect.ValueOf(Default()).Elem(), "", func(key string, _ reflect.Value) {
+		keys = append(keys, key)
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+// walkFields calls fn for every leaf field of v with its dotted TOML key
+func walkFields(v reflect.Value, prefix string, fn func(string, reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := prefix + t.Field(i).Tag.Get("toml")
+		if t.Field(i).Type.Kind() == reflect.Struct {
+			walkFields(v.Field(i), key+".", fn)
+			continue
+		}
+		fn(key, v.Field(i))
+	}
+}
+
+// field returns the field of the config for the given key
+func (cfg *Config) field(key string) (reflect.Value, error) {
+	var ret reflect.Value
+	walkFields(reflect.ValueOf(cfg).Elem(), "", func(k string, v reflect.Value) {
+		if k == key {
+			ret = v
+		}
+	})
+	if !ret.IsValid() {
+		return ret, fmt.Errorf("unknown config key %q", key)
+	}
+	return ret, nil
+}
+
+// Get returns the value of the given key as a string. Lists are
+// returned as comma separated values.
+func (cfg *Config) Get(key string) (string, error) {
+	v, err := cfg.field(key)
+	if err != nil {
+		return "", err
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		return strings.Join(v.Interface().([]string), ","), nil
+	default:
+		return fmt.Sprintf("%v", v.Interface()), nil
+	}
+}
+
+// Set sets the given key from its string value. Lists are set from
+// comma separated values.
+func (cfg *Config) Set(key string, value string) error {
+	v, err := cfg.field(key)
+	if err != nil {
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Slice:
+		list := []string{}
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	case reflect.Int:
+		var i int
+		if _, err := fmt.Sscanf(value, "%d", &i); err != nil {
+			return fmt.Errorf("%s: %q is not an integer", key, value)
+		}
+		v.SetInt(int64(i))
+	default:
+		return fmt.Errorf("%s: unsupported value type %v", key, v.Kind())
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	f := filepath.Join(t.TempDir(), "config.toml")
+	err := os.WriteFile(f, []byte(content), 0644)
+	Assert(t, err == nil, fmt.Sprintf("failed to write config file, Err: %v", err))
+	return f
+}
+
+func TestLoadDefault(t *testing.T) {
+	t.Setenv(CONFIG_PATH_ENV, filepath.Join(t.TempDir(), "missing.toml"))
+	t.Setenv(EnvName("runtime.runtimes"), "")
+
+	cfg, err := Load()
+	Assert(t, err == nil, fmt.Sprintf("Load() returned error %v", err))
+	Assert(t, slices.Equal(cfg.Runtime.Runtimes, Default().Runtime.Runtimes),
+		fmt.Sprintf("expected default runtimes %v, got %v", Default().Runtime.Runtimes, cfg.Runtime.Runtimes))
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Setenv(CONFIG_PATH_ENV, writeConfig(t, "[runtime]\nruntimes = [\"/usr/local/sbin/runc\", \"crun\"]\n"))
+	t.Setenv(EnvName("runtime.runtimes"), "")
+
+	cfg, err := Load()
+	Assert(t, err == nil, fmt.Sprintf("Load() returned error %v", err))
+	expected := []string{"/usr/local/sbin/runc", "crun"}
+	Assert(t, slices.Equal(cfg.Runtime.Runtimes, expected), fmt.Sprintf("expected runtimes %v, got %v", expected, cfg.Runtime.Runtimes))
+}
+
+func TestLoadEnvOverride(t *testing.T) {
+	t.Setenv(CONFIG_PATH_ENV, writeConfig(t, "[runtime]\nruntimes = [\"runc\"]\n"))
+	t.Setenv(EnvName("runtime.runtimes"), "youki,/usr/bin/runsc")
+
+	cfg, err := Load()
+	Assert(t, err == nil, fmt.Sprintf("Load() returned error %v", err))
+	expected := []string{"youki", "/usr/bin/runsc"}
+	Assert(t, slices.Equal(cfg.Runtime.Runtimes, expected), fmt.Sprintf("expected runtimes %v, got %v", expected, cfg.Runtime.Runtimes))
+}
+
+func TestLoadInvalid(t *testing.T) {
+	t.Setenv(EnvName("runtime.runtimes"), "")
+
+	t.Setenv(CONFIG_PATH_ENV, writeConfig(t, "[runtime\n"))
+	_, err := Load()
+	Assert(t, err != nil, "Load() did not return error for malformed config")
+
+	t.Setenv(CONFIG_PATH_ENV, writeConfig(t, "[runtime]\nruntimes = []\n"))
+	_, err = Load()
+	Assert(t, err != nil, "Load() did not return error for empty runtimes")
+}
+
+func TestLoadRootless(t *testing.T) {
+	origIsRootless, origStateDir := isRootless, stateDir
+	defer func() { isRootless, stateDir = origIsRootless, origStateDir }()
+	isRootless = func() bool { return true }
+	stateDir = func() (string, error) { return "/home/user/.local/state/amd-container-toolkit", nil }
+
+	t.Setenv(CONFIG_PATH_ENV, writeConfig(t, "[gpu-tracker]\nlock-file = \"/run/user/1000/gpu-tracker.lock\"\n"))
+	t.Setenv(EnvName("gpu-tracker.file"), "")
+	t.Setenv(EnvName("runtime.log-dir"), "")
+
+	cfg, err := Load()
+	Assert(t, err == nil, fmt.Sprintf("Load() returned error %v", err))
+	Assert(t, cfg.Runtime.LogDir == "/home/user/.local/state/amd-container-toolkit", fmt.Sprintf("unexpected log dir %v", cfg.Runtime.LogDir))
+	Assert(t, cfg.AmdCtk.BackupDir == "/home/user/.local/state/amd-container-toolkit/backups", fmt.Sprintf("unexpected backup dir %v", cfg.AmdCtk.BackupDir))
+	Assert(t, cfg.GPUTracker.File == "/home/user/.local/state/amd-container-toolkit/gpu-tracker.json", fmt.Sprintf("unexpected GPU Tracker file %v", cfg.GPUTracker.File))
+	Assert(t, cfg.GPUTracker.LockFile == "/run/user/1000/gpu-tracker.lock", fmt.Sprintf("the lock file of the config file should be kept, got %v", cfg.GPUTracker.LockFile))
+
+	// The environment overrides the rootless defaults
+	t.Setenv(EnvName("gpu-tracker.file"), "/tmp/gpu-tracker.json")
+	cfg, err = Load()
+	Assert(t, err == nil && cfg.GPUTracker.File == "/tmp/gpu-tracker.json", fmt.Sprintf("unexpected GPU Tracker file %v, err %v", cfg.GPUTracker.File, err))
+
+	stateDir = func() (string, error) { return "", fmt.Errorf("no home directory") }
+	_, err = Load()
+	Assert(t, err != nil, "Load() should fail without a state directory")
+}
+
+func TestLoadOverrides(t *testing.T) {
+	t.Setenv(CONFIG_PATH_ENV, writeConfig(t, "version = 1\n[gpu-tracker]\nfile = \"/run/amd/gpu-tracker.json\"\n"))
+	t.Setenv(EnvName("gpu-tracker.lock-file"), "/run/amd/gpu-tracker.lock")
+	t.Setenv(EnvName("runtime.runtimes"), "")
+
+	cfg, err := Load()
+	Assert(t, err == nil, fmt.Sprintf("Load() returned error %v", err))
+	Assert(t, cfg.GPUTracker.File == "/run/amd/gpu-tracker.json", fmt.Sprintf("unexpected tracker file %v", cfg.GPUTracker.File))
+	Assert(t, cfg.GPUTracker.LockFile == "/run/amd/gpu-tracker.lock", fmt.Sprintf("unexpected tracker lock file %v", cfg.GPUTracker.LockFile))
+	Assert(t, cfg.AmdCtk.Path == Default().AmdCtk.Path, fmt.Sprintf("unexpected amd-ctk path %v", cfg.AmdCtk.Path))
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	Assert(t, cfg.Validate() == nil, fmt.Sprintf("default config is invalid, Err: %v", cfg.Validate()))
+
+	cfg.Version = CURRENT_VERSION + 1
+	Assert(t, cfg.Validate() != nil, "Validate() did not return error for unsupported version")
+
+	cfg = Default()
+	cfg.Hook.Path = "amd-container-runtime-hook"
+	Assert(t, cfg.Validate() != nil, "Validate() did not return error for relative path")
+
+	cfg = Default()
+	cfg.GPUTracker.RuntimeRoots = []string{"/run/runc", "run/crun"}
+	Assert(t, cfg.Validate() != nil, "Validate() did not return error for relative runtime root")
+
+	cfg = Default()
+	cfg.Runtime.Mode = RUNTIME_MODE_HOOK
+	Assert(t, cfg.Validate() == nil, fmt.Sprintf("hook mode is invalid, Err: %v", cfg.Validate()))
+
+	cfg.Runtime.Mode = "oci-hook"
+	Assert(t, cfg.Validate() != nil, "Validate() did not return error for unknown runtime mode")
+}
+
+func TestGetSet(t *testing.T) {
+	cfg := Default()
+
+	err := cfg.Set("runtime.runtimes", "crun, runc")
+	Assert(t, err == nil, fmt.Sprintf("Set() returned error %v", err))
+	v, err := cfg.Get("runtime.runtimes")
+	Assert(t, err == nil && v == "crun,runc", fmt.Sprintf("unexpected runtimes %v, Err: %v", v, err))
+
+	err = cfg.Set("amd-ctk.path", "/usr/bin/amd-ctk")
+	Assert(t, err == nil && cfg.AmdCtk.Path == "/usr/bin/amd-ctk", fmt.Sprintf("unexpected amd-ctk path %v, Err: %v", cfg.AmdCtk.Path, err))
+
+	err = cfg.Set("version", "one")
+	Assert(t, err != nil, "Set() did not return error for invalid integer")
+
+	_, err = cfg.Get("runtime.unknown")
+	Assert(t, err != nil, "Get() did not return error for unknown key")
+
+	Assert(t, slices.Contains(Keys(), "gpu-tracker.lock-file"), fmt.Sprintf("unexpected keys %v", Keys()))
+	Assert(t, EnvName("gpu-tracker.lock-file") == "AMD_CTK_GPU_TRACKER_LOCK_FILE", fmt.Sprintf("unexpected env name %v", EnvName("gpu-tracker.lock-file")))
+}
+
+func TestSave(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "etc", "config.toml")
+	cfg := Default()
+	cfg.Runtime.Runtimes = []string{"/usr/bin/crun"}
+
+	err := cfg.Save(f)
+	Assert(t, err == nil, fmt.Sprintf("Save() returned error %v", err))
+
+	saved, err := LoadFile(f)
+	Assert(t, err == nil, fmt.Sprintf("LoadFile() returned error %v", err))
+	Assert(t, slices.Equal(saved.Runtime.Runtimes, cfg.Runtime.Runtimes), fmt.Sprintf("expected runtimes %v, got %v", cfg.Runtime.Runtimes, saved.Runtime.Runtimes))
+	Assert(t, saved.Version == CURRENT_VERSION, fmt.Sprintf("expected version %v, got %v", CURRENT_VERSION, saved.Version))
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
@@ -23,13 +23,16 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ROCm/container-toolkit/internal/amdgpu"
+	"github.com/ROCm/container-toolkit/internal/config"
 	"github.com/gofrs/flock"
 )
 
@@ -41,6 +44,12 @@ const (
 	EXCLUSIVE_ACCESS Accessibility = "Exclusive"
 )
 
+// SharedLimitedAccess returns the accessibility of GPUs that can be used
+// by at most maxContainers containers at any instance, e.g. Shared(4)
+func SharedLimitedAccess(maxContainers int) Accessibility {
+	return Accessibility(fmt.Sprintf("%s(%d)", SHARED_ACCESS, maxContainers))
+}
+
 // accessibility is the internal int representation used for JSON serialization
 // of the tracker file, kept for backward compatibility with existing tracker files.
 type accessibility int
@@ -48,14 +57,20 @@ type accessibility int
 const (
 	sharedAccessInt accessibility = iota
 	exclusiveAccessInt
+	sharedLimitedAccessInt
 )
 
-func (a accessibility) toAccessibility() (Accessibility, error) {
+func (a accessibility) toAccessibility(maxContainers int) (Accessibility, error) {
 	switch a {
 	case sharedAccessInt:
 		return SHARED_ACCESS, nil
 	case exclusiveAccessInt:
 		return EXCLUSIVE_ACCESS, nil
+	case sharedLimitedAccessInt:
+		if maxContainers < 1 {
+			return "", fmt.Errorf("invalid maximum number of containers: %d", maxContainers)
+		}
+		return SharedLimitedAccess(maxContainers), nil
 	default:
 		return "", fmt.Errorf("invalid accessibility value: %d", int(a))
 	}
@@ -63,14 +78,17 @@ func (a accessibility) toAccessibility() (Accessibility, error) {
 
 // GPUStatusEntry represents the status of a single GPU
 type GPUStatusEntry struct {
-	GPUId         int
-	UUID          string
-	Accessibility Accessibility
-	ContainerIds  []string
+	GPUId         int           `json:"gpuId" yaml:"gpuId"`
+	UUID          string        `json:"uuid" yaml:"uuid"`
+	Accessibility Accessibility `json:"accessibility" yaml:"accessibility"`
+	MaxContainers int           `json:"maxContainers" yaml:"maxContainers"`
+	ContainerIds  []string      `json:"containerIds" yaml:"containerIds"`
+	Leases        []LeaseEntry  `json:"leases" yaml:"leases"`
 }
 
 // AccessibilityResult contains the outcome of a MakeGPUsExclusive or MakeGPUsShared operation
 type AccessibilityResult struct {
+	Accessibility Accessibility
 	Changed       []int
 	NotChanged    []int
 	InvalidGPUs   []string
@@ -105,11 +123,27 @@ type Interface interface {
 	// by any number of containers at any instance
 	MakeGPUsShared(gpus string) (*AccessibilityResult, error)
 
+	// Make specified GPUs shared such that they can be used
+	// by at most maxContainers containers at any instance
+	MakeGPUsSharedLimited(gpus string, maxContainers int) (*AccessibilityResult, error)
+
 	// Reserve GPUs for a container
-	ReserveGPUs(gpus string, containerId string) ([]int, error)
+	ReserveGPUs(gpus string, containerId string, lease Lease) ([]int, error)
+
+	// Renew the lease of the GPUs reserved for a container
+	RenewLease(containerId string) error
+
+	// Show the containers waiting for GPUs
+	ShowQueue() ([]QueueEntry, error)
 
 	// Release all GPUs linked to a container
 	ReleaseGPUs(containerId string) error
+
+	// Release the GPUs of the containers that no longer exist, and
+	// return these containers along with the containers kept because
+	// they could not be found. The GPUs of the containers that could not
+	// be found are also released when releaseUnknown is set.
+	Reconcile(releaseUnknown bool) ([]string, []string, error)
 }
 
 type gpu_status_t struct {
@@ -122,11 +156,36 @@ type gpu_status_t struct {
 	// GPU accessibility (int for backward-compatible JSON serialization)
 	Accessibility accessibility `json:"accessibility"`
 
+	// Maximum number of containers a Shared(N) GPU can be assigned to
+	MaxContainers int `json:"maxContainers,omitempty"`
+
 	// Container Ids of the containers to which the GPU is assigned
 	ContainerIds []string `json:"containerIds"`
 }
 
+// maxContainers returns the maximum number of containers the GPU can be
+// assigned to at any instance, 0 if there is no limit
+func (s gpu_status_t) maxContainers() int {
+	switch s.Accessibility {
+	case exclusiveAccessInt:
+		return 1
+	case sharedLimitedAccessInt:
+		return s.MaxContainers
+	default:
+		return 0
+	}
+}
+
+// isAvailable reports whether the GPU can be assigned to one more container
+func (s gpu_status_t) isAvailable() bool {
+	limit := s.maxContainers()
+	return limit == 0 || len(s.ContainerIds) < limit
+}
+
 type gpu_tracker_data_t struct {
+	// Version of the GPU Tracker file
+	Version int `json:"version"`
+
 	// Status of GPU Tracker
 	Enabled bool `json:"enabled"`
 
@@ -135,6 +194,15 @@ type gpu_tracker_data_t struct {
 
 	// Info of all GPUs
 	GPUsInfo map[int]amdgpu.DeviceInfo `json:"gpusInfo"`
+
+	// ID of the boot the reservations were made in
+	BootId string `json:"bootId,omitempty"`
+
+	// Leases of the containers GPUs are reserved for
+	Leases map[string]lease_t `json:"leases"`
+
+	// Containers waiting for GPUs in use, in the order they get them
+	Queue []waiter_t `json:"queue,omitempty"`
 }
 
 // isGPUTrackerInitializedTYpe is the type for functions
@@ -161,6 +229,26 @@ type writeGPUTrackerFileType func(gpu_tracker_data_t) error
 // validate the GPUs info
 type validateGPUsInfoType func(map[int]amdgpu.DeviceInfo) (bool, error)
 
+// getGPUInventoryType is the type for functions that
+// return the GPU inventory
+type getGPUInventoryType func() ([]amdgpu.GPUInfo, error)
+
+// getBootIdType is the type for functions that
+// return the ID of the current boot
+type getBootIdType func() (string, error)
+
+// containerExistsType is the type for functions that
+// check if a container still exists in the low-level runtime
+type containerExistsType func(string) (bool, error)
+
+// bundleExistsType is the type for functions that
+// check if the bundle directory of a container still exists
+type bundleExistsType func(string) bool
+
+// processExistsType is the type for functions that
+// check if a process is still running
+type processExistsType func(int) bool
+
 type gpu_tracker_t struct {
 	// path to GPU Tracker lock file
 	gpuTrackerLockFile string
@@ -182,16 +270,37 @@ type gpu_tracker_t struct {
 
 	// function to validate GPUs info
 	validateGPUsInfo validateGPUsInfoType
-}
 
-const (
-	gpuTrackerFile     = "/var/log/gpu-tracker.json"
-	gpuTrackerLockFile = "/var/log/gpu-tracker.lock"
-)
+	// function to get the GPU inventory
+	getGPUInventory getGPUInventoryType
+
+	// function to get the ID of the current boot
+	getBootId getBootIdType
+
+	// function to check if a container still exists
+	containerExists containerExistsType
+
+	// function to check if the bundle of a container still exists
+	bundleExists bundleExistsType
+
+	// function to check if the runtime of a waiting container still runs
+	processExists processExistsType
+
+	// function to get the current time
+	now func() time.Time
+
+	// function to wait before trying to reserve GPUs again
+	sleep func(time.Duration)
+}
 
 const defaultLockTimeout = 10 * time.Second
 
 func acquireLock(lockFile string, timeout time.Duration) (*flock.Flock, error) {
+	// The state directory of a rootless user may not exist yet
+	if err := os.MkdirAll(filepath.Dir(lockFile), 0755); err != nil {
+		return nil, fmt.Errorf("creating lock directory: %w", err)
+	}
+
 	lock := flock.New(lockFile)
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
@@ -246,8 +355,25 @@ func parseGPUsList(gpus string) ([]int, []string, []string, error) {
 		uuidToGPUIdMap = make(map[string][]int) // Continue with empty map
 	}
 
+	// The inventory is only read if a selector is used
+	var inventory []amdgpu.GPUInfo
+
 	for _, c := range strings.Split(gpus, ",") {
-		if strings.HasPrefix(c, "0x") || strings.HasPrefix(c, "0X") ||
+		if name, value, ok := amdgpu.ParseSelector(c); ok && name != amdgpu.SELECTOR_COUNT {
+			if inventory == nil {
+				inventory, err = amdgpu.GetGPUInventory()
+				if err != nil {
+					return []int{}, []string{}, []string{}, fmt.Errorf("getting AMD GPU inventory: %w", err)
+				}
+			}
+			gpuIds, err := amdgpu.MatchSelector(inventory, name, value)
+			if err != nil {
+				slog.Debug("Invalid GPU selector", "selector", c, "error", err)
+				invalidGPUs = append(invalidGPUs, c)
+			} else {
+				validGPUs = append(validGPUs, gpuIds...)
+			}
+		} else if strings.HasPrefix(c, "0x") || strings.HasPrefix(c, "0X") ||
 			(len(c) > 8 && isHexString(c)) {
 			uuid := strings.ToLower(c)
 			if !strings.HasPrefix(uuid, "0x") {
@@ -294,11 +420,71 @@ func parseGPUsList(gpus string) ([]int, []string, []string, error) {
 	}
 
 	sort.Ints(validGPUs)
+	validGPUs = slices.Compact(validGPUs)
 
 	return validGPUs, invalidGPUs, invalidGPUsRange, nil
 }
 
-func isGPUTrackerInitialized() (bool, error) {
+// splitCount removes the count selector from a GPU list, returning the
+// other GPUs, "all" if there are none, and the requested count, 0 if
+// there is no count selector
+func splitCount(gpus string) (string, int, error) {
+	rest := []string{}
+	count := 0
+	for _, c := range strings.Split(gpus, ",") {
+		if name, value, ok := amdgpu.ParseSelector(c); ok && name == amdgpu.SELECTOR_COUNT {
+			n, err := amdgpu.ParseCount(value)
+			if err != nil {
+				return "", 0, err
+			}
+			if count != 0 && n != count {
+				return "", 0, fmt.Errorf("conflicting GPU counts %d and %d", count, n)
+			}
+			count = n
+		} else {
+			rest = append(rest, c)
+		}
+	}
+
+	if count > 0 && len(rest) == 0 {
+		return "all", count, nil
+	}
+
+	return strings.Join(rest, ","), count, nil
+}
+
+// errNotEnoughFreeGPUs is returned when fewer GPUs than requested with
+// count:N are free
+var errNotEnoughFreeGPUs = errors.New("not enough free GPUs")
+
+// pickGPUs returns count GPUs among validGPUs that are not used by any
+// container, picking the GPUs closest to each other
+func (gpuTracker *gpu_tracker_t) pickGPUs(validGPUs []int, count int, gpusTrackerData gpu_tracker_data_t) ([]int, error) {
+	free := []int{}
+	for _, gpuId := range validGPUs {
+		if !gpusTrackerData.Enabled || len(gpusTrackerData.GPUsStatus[gpuId].ContainerIds) == 0 {
+			free = append(free, gpuId)
+		}
+	}
+
+	inventory, err := gpuTracker.getGPUInventory()
+	if err != nil {
+		return nil, fmt.Errorf("getting AMD GPU inventory: %w", err)
+	}
+
+	if len(free) < count {
+		return nil, fmt.Errorf("%d GPUs requested, only %d free: %w", count, len(free), errNotEnoughFreeGPUs)
+	}
+
+	picked, err := amdgpu.PickClosestGPUs(inventory, free, count)
+	if err != nil {
+		return nil, fmt.Errorf("selecting free GPUs: %w", err)
+	}
+
+	return picked, nil
+}
+
+func isGPUTrackerInitialized(gpuTrackerFile string) (bool, error) {
 	gpuTrackerInitialized := false
 	_, err := os.Stat(gpuTrackerFile)
 	if err == nil {
@@ -312,7 +498,7 @@ func isGPUTrackerInitialized() (bool, error) {
 	return gpuTrackerInitialized, nil
 }
 
-func readGPUTrackerFile() (gpu_tracker_data_t, error) {
+func readGPUTrackerFile(gpuTrackerFile string) (gpu_tracker_data_t, error) {
 	file, err := os.Open(gpuTrackerFile)
 	if err != nil {
 		return gpu_tracker_data_t{GPUsStatus: make(map[int]gpu_status_t), GPUsInfo: make(map[int]amdgpu.DeviceInfo)},
@@ -327,10 +513,14 @@ func readGPUTrackerFile() (gpu_tracker_data_t, error) {
 			fmt.Errorf("decoding GPU tracker JSON: %w", err)
 	}
 
+	if err := migrateGPUTrackerData(&gpuTrackerData); err != nil {
+		return gpu_tracker_data_t{GPUsStatus: make(map[int]gpu_status_t), GPUsInfo: make(map[int]amdgpu.DeviceInfo)}, err
+	}
+
 	return gpuTrackerData, nil
 }
 
-func writeGPUTrackerFile(gpuTrackerData gpu_tracker_data_t) error {
+func writeGPUTrackerFile(gpuTrackerFile string, gpuTrackerData gpu_tracker_data_t) error {
 	tempPath := gpuTrackerFile + ".tmp"
 	tempFile, err := os.Create(tempPath)
 	if err != nil {
@@ -359,7 +549,7 @@ func writeGPUTrackerFile(gpuTrackerData gpu_tracker_data_t) error {
 	return nil
 }
 
-func initializeGPUTracker() error {
+func initializeGPUTracker(gpuTrackerFile string) error {
 	gpusInfo, err := amdgpu.GetAMDGPUs()
 	if err != nil {
 		return fmt.Errorf("getting AMD GPU info: %w", err)
@@ -380,7 +570,13 @@ func initializeGPUTracker() error {
 		}
 	}
 
-	gpuTrackerData := gpu_tracker_data_t{Enabled: false, GPUsStatus: make(map[int]gpu_status_t), GPUsInfo: make(map[int]amdgpu.DeviceInfo)}
+	gpuTrackerData := gpu_tracker_data_t{
+		Version:    GPU_TRACKER_VERSION,
+		Enabled:    false,
+		GPUsStatus: make(map[int]gpu_status_t),
+		GPUsInfo:   make(map[int]amdgpu.DeviceInfo),
+		Leases:     make(map[string]lease_t),
+	}
 	for gpuId, gpuInfo := range gpusInfo {
 		gpuTrackerData.GPUsInfo[gpuId] = gpuInfo
 		gpuTrackerData.GPUsStatus[gpuId] = gpu_status_t{
@@ -391,7 +587,7 @@ func initializeGPUTracker() error {
 		}
 	}
 
-	return writeGPUTrackerFile(gpuTrackerData)
+	return writeGPUTrackerFile(gpuTrackerFile, gpuTrackerData)
 }
 
 func validateGPUsInfo(savedGPUsInfo map[int]amdgpu.DeviceInfo) (bool, error) {
@@ -579,7 +775,7 @@ func (gpuTracker *gpu_tracker_t) ShowStatus() ([]GPUStatusEntry, error) {
 		}
 	}
 
-	gpusTrackerData, err := gpuTracker.readGPUTrackerFile()
+	gpusTrackerData, err := gpuTracker.loadGPUTrackerData()
 	if err != nil {
 		return nil, err
 	}
@@ -592,9 +788,11 @@ func (gpuTracker *gpu_tracker_t) ShowStatus() ([]GPUStatusEntry, error) {
 		return nil, fmt.Errorf("GPU info mismatch: please reset GPU Tracker")
 	}
 
+	now := gpuTracker.now()
 	var entries []GPUStatusEntry
 	for gpuId := 0; gpuId < len(gpusTrackerData.GPUsStatus); gpuId++ {
-		acc, err := gpusTrackerData.GPUsStatus[gpuId].Accessibility.toAccessibility()
+		status := gpusTrackerData.GPUsStatus[gpuId]
+		acc, err := status.Accessibility.toAccessibility(status.MaxContainers)
 		if err != nil {
 			return nil, fmt.Errorf("GPU %d: %w", gpuId, err)
 		}
@@ -602,7 +800,9 @@ func (gpuTracker *gpu_tracker_t) ShowStatus() ([]GPUStatusEntry, error) {
 			GPUId:         gpuId,
 			UUID:          gpusTrackerData.GPUsStatus[gpuId].UUID,
 			Accessibility: acc,
+			MaxContainers: status.maxContainers(),
 			ContainerIds:  gpusTrackerData.GPUsStatus[gpuId].ContainerIds,
+			Leases:        leaseEntries(gpusTrackerData, gpusTrackerData.GPUsStatus[gpuId].ContainerIds, now),
 		})
 	}
 
@@ -610,6 +810,24 @@ func (gpuTracker *gpu_tracker_t) ShowStatus() ([]GPUStatusEntry, error) {
 }
 
 func (gpuTracker *gpu_tracker_t) MakeGPUsExclusive(gpus string) (*AccessibilityResult, error) {
+	return gpuTracker.setAccessibility(gpus, exclusiveAccessInt, 0)
+}
+
+func (gpuTracker *gpu_tracker_t) MakeGPUsShared(gpus string) (*AccessibilityResult, error) {
+	return gpuTracker.setAccessibility(gpus, sharedAccessInt, 0)
+}
+
+func (gpuTracker *gpu_tracker_t) MakeGPUsSharedLimited(gpus string, maxContainers int) (*AccessibilityResult, error) {
+	if maxContainers < 1 {
+		return nil, fmt.Errorf("invalid maximum number of containers: %d", maxContainers)
+	}
+	return gpuTracker.setAccessibility(gpus, sharedLimitedAccessInt, maxContainers)
+}
+
+// setAccessibility changes the accessibility of the specified GPUs. GPUs
+// already assigned to more containers than the new accessibility allows
+// are left unchanged.
+func (gpuTracker *gpu_tracker_t) setAccessibility(gpus string, acc accessibility, maxContainers int) (*AccessibilityResult, error) {
 	lock, err := acquireLock(gpuTracker.gpuTrackerLockFile, defaultLockTimeout)
 	if err != nil {
 		return nil, err
@@ -627,7 +845,7 @@ func (gpuTracker *gpu_tracker_t) MakeGPUsExclusive(gpus string) (*AccessibilityR
 		}
 	}
 
-	gpusTrackerData, err := gpuTracker.readGPUTrackerFile()
+	gpusTrackerData, err := gpuTracker.loadGPUTrackerData()
 	if err != nil {
 		return nil, err
 	}
@@ -645,23 +863,27 @@ func (gpuTracker *gpu_tracker_t) MakeGPUsExclusive(gpus string) (*AccessibilityR
 		return nil, err
 	}
 
+	resAcc, err := acc.toAccessibility(maxContainers)
+	if err != nil {
+		return nil, err
+	}
+
 	res := &AccessibilityResult{
+		Accessibility: resAcc,
 		InvalidGPUs:   invalidGPUs,
 		InvalidRanges: invalidGPUsRange,
 	}
 
 	for _, gpuId := range validGPUs {
-		if len(gpusTrackerData.GPUsStatus[gpuId].ContainerIds) < 2 {
-			gpusTrackerData.GPUsStatus[gpuId] = gpu_status_t{
-				UUID:          gpusTrackerData.GPUsStatus[gpuId].UUID,
-				PartitionType: gpusTrackerData.GPUsStatus[gpuId].PartitionType,
-				Accessibility: exclusiveAccessInt,
-				ContainerIds:  gpusTrackerData.GPUsStatus[gpuId].ContainerIds,
-			}
-			res.Changed = append(res.Changed, gpuId)
-		} else {
+		status := gpusTrackerData.GPUsStatus[gpuId]
+		status.Accessibility = acc
+		status.MaxContainers = maxContainers
+		if limit := status.maxContainers(); limit > 0 && len(status.ContainerIds) > limit {
 			res.NotChanged = append(res.NotChanged, gpuId)
+			continue
 		}
+		gpusTrackerData.GPUsStatus[gpuId] = status
+		res.Changed = append(res.Changed, gpuId)
 	}
 
 	if err := gpuTracker.writeGPUTrackerFile(gpusTrackerData); err != nil {
@@ -671,88 +893,46 @@ func (gpuTracker *gpu_tracker_t) MakeGPUsExclusive(gpus string) (*AccessibilityR
 	return res, nil
 }
 
-func (gpuTracker *gpu_tracker_t) MakeGPUsShared(gpus string) (*AccessibilityResult, error) {
+// tryReserveGPUs makes one attempt to reserve GPUs for a container. It
+// returns true if the GPUs are in use and the container has been queued
+// to wait for them until deadline.
+func (gpuTracker *gpu_tracker_t) tryReserveGPUs(gpus string, containerId string, lease Lease, deadline time.Time) ([]int, bool, error) {
 	lock, err := acquireLock(gpuTracker.gpuTrackerLockFile, defaultLockTimeout)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer lock.Unlock()
 
 	gpuTrackerInitialized, err := gpuTracker.isGPUTrackerInitialized()
 	if err != nil {
-		return nil, err
+		return []int{}, false, err
 	}
 
 	if !gpuTrackerInitialized {
 		if err := gpuTracker.initializeGPUTracker(); err != nil {
-			return nil, err
-		}
-	}
-
-	gpusTrackerData, err := gpuTracker.readGPUTrackerFile()
-	if err != nil {
-		return nil, err
-	}
-
-	result, err := gpuTracker.validateGPUsInfo(gpusTrackerData.GPUsInfo)
-	if err != nil {
-		return nil, fmt.Errorf("validate GPU info: %w", err)
-	}
-	if !result {
-		return nil, fmt.Errorf("GPU info mismatch: please reset GPU Tracker")
-	}
-
-	validGPUs, invalidGPUs, invalidGPUsRange, err := gpuTracker.parseGPUsList(gpus)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, gpuId := range validGPUs {
-		gpusTrackerData.GPUsStatus[gpuId] = gpu_status_t{
-			UUID:          gpusTrackerData.GPUsStatus[gpuId].UUID,
-			PartitionType: gpusTrackerData.GPUsStatus[gpuId].PartitionType,
-			Accessibility: sharedAccessInt,
-			ContainerIds:  gpusTrackerData.GPUsStatus[gpuId].ContainerIds,
+			return []int{}, false, err
 		}
 	}
 
-	if err := gpuTracker.writeGPUTrackerFile(gpusTrackerData); err != nil {
-		return nil, err
-	}
-
-	return &AccessibilityResult{
-		Changed:       validGPUs,
-		InvalidGPUs:   invalidGPUs,
-		InvalidRanges: invalidGPUsRange,
-	}, nil
-}
-
-func (gpuTracker *gpu_tracker_t) ReserveGPUs(gpus string, containerId string) ([]int, error) {
-	lock, err := acquireLock(gpuTracker.gpuTrackerLockFile, defaultLockTimeout)
-	if err != nil {
-		return nil, err
-	}
-	defer lock.Unlock()
-
-	gpuTrackerInitialized, err := gpuTracker.isGPUTrackerInitialized()
+	gpusTrackerData, err := gpuTracker.loadGPUTrackerData()
 	if err != nil {
-		return []int{}, err
+		return []int{}, false, err
 	}
 
-	if !gpuTrackerInitialized {
-		if err := gpuTracker.initializeGPUTracker(); err != nil {
-			return []int{}, err
-		}
-	}
+	// Expired leases and waiters are dropped before they can block the
+	// reservation
+	now := gpuTracker.now()
+	releaseExpired(&gpusTrackerData, now)
+	dropExpiredWaiters(&gpusTrackerData, now, gpuTracker.processExists)
 
-	gpusTrackerData, err := gpuTracker.readGPUTrackerFile()
+	gpus, count, err := splitCount(gpus)
 	if err != nil {
-		return []int{}, err
+		return []int{}, false, err
 	}
 
 	validGPUs, invalidGPUs, invalidGPUsRange, err := gpuTracker.parseGPUsList(gpus)
 	if err != nil {
-		return []int{}, err
+		return []int{}, false, err
 	}
 	if len(invalidGPUsRange) > 0 {
 		slog.Warn("Ignoring GPUs Ranges as they are invalid", "ranges", invalidGPUsRange)
@@ -761,49 +941,143 @@ func (gpuTracker *gpu_tracker_t) ReserveGPUs(gpus string, containerId string) ([
 		slog.Warn("Ignoring GPUs as they are invalid", "gpus", invalidGPUs)
 	}
 
+	// GPUs the container waits for if they are in use
+	waitFor := validGPUs
+	var pickErr error
+	if count > 0 {
+		candidates := validGPUs
+		if gpusTrackerData.Enabled {
+			// GPUs awaited by queued containers are left to them
+			awaited := awaitedGPUs(gpusTrackerData, containerId)
+			candidates = slices.DeleteFunc(slices.Clone(validGPUs), func(gpuId int) bool {
+				return awaited[gpuId]
+			})
+		}
+		validGPUs, pickErr = gpuTracker.pickGPUs(candidates, count, gpusTrackerData)
+		if pickErr != nil {
+			// The container waits for enough of the selected GPUs to be
+			// free, unless there are not as many GPUs as requested
+			canWait := gpusTrackerData.Enabled && lease.WaitTimeout > 0 && count <= len(waitFor)
+			if !canWait || !errors.Is(pickErr, errNotEnoughFreeGPUs) {
+				return []int{}, false, pickErr
+			}
+		}
+	}
+
 	if !gpusTrackerData.Enabled {
 		slog.Debug("GPU Tracker is disabled")
-		return validGPUs, nil
+		return validGPUs, false, nil
 	}
 
 	result, err := gpuTracker.validateGPUsInfo(gpusTrackerData.GPUsInfo)
 	if err != nil {
-		return []int{}, fmt.Errorf("validate GPU info: %w", err)
+		return []int{}, false, fmt.Errorf("validate GPU info: %w", err)
 	}
 	if !result {
-		return []int{}, fmt.Errorf("GPU info mismatch: please reset GPU Tracker")
+		return []int{}, false, fmt.Errorf("GPU info mismatch: please reset GPU Tracker")
 	}
 
-	var allocatedGPUs []int
+	// GPUs awaited by containers queued before this one are left to them,
+	// so that the containers get their GPUs in the order they asked
+	awaited := awaitedGPUs(gpusTrackerData, containerId)
+
+	// The reservation is staged on copies of the GPU status, so that no
+	// GPU is reserved unless the policy allows it
+	staged := make(map[int]gpu_status_t)
+	var availableGPUs []int
 	var unavailableGPUs []int
+	var fullGPUs []int
+	var awaitedByOthers []int
 	for _, gpuId := range validGPUs {
-		if gpusTrackerData.GPUsStatus[gpuId].Accessibility == sharedAccessInt ||
-			(gpusTrackerData.GPUsStatus[gpuId].Accessibility == exclusiveAccessInt &&
-				len(gpusTrackerData.GPUsStatus[gpuId].ContainerIds) == 0) {
-			gpusTrackerData.GPUsStatus[gpuId] = gpu_status_t{
-				UUID:          gpusTrackerData.GPUsStatus[gpuId].UUID,
-				PartitionType: gpusTrackerData.GPUsStatus[gpuId].PartitionType,
-				Accessibility: gpusTrackerData.GPUsStatus[gpuId].Accessibility,
-				ContainerIds:  append(gpusTrackerData.GPUsStatus[gpuId].ContainerIds, containerId),
-			}
-			allocatedGPUs = append(allocatedGPUs, gpuId)
-		} else {
+		status, found := staged[gpuId]
+		if !found {
+			status = gpusTrackerData.GPUsStatus[gpuId]
+		}
+		if awaited[gpuId] {
+			awaitedByOthers = append(awaitedByOthers, gpuId)
+		} else if status.isAvailable() {
+			status.ContainerIds = append(slices.Clone(status.ContainerIds), containerId)
+			staged[gpuId] = status
+			availableGPUs = append(availableGPUs, gpuId)
+		} else if status.Accessibility == exclusiveAccessInt {
 			unavailableGPUs = append(unavailableGPUs, gpuId)
+		} else {
+			fullGPUs = append(fullGPUs, gpuId)
+		}
+	}
+
+	var errs []error
+	if pickErr != nil {
+		errs = append(errs, pickErr)
+	}
+	if len(unavailableGPUs) > 0 {
+		errs = append(errs, fmt.Errorf("GPUs %v are exclusive and already in use", unavailableGPUs))
+	}
+	if len(fullGPUs) > 0 {
+		errs = append(errs, fmt.Errorf("GPUs %v are already in use by the maximum number of containers", fullGPUs))
+	}
+	if len(awaitedByOthers) > 0 {
+		errs = append(errs, fmt.Errorf("GPUs %v are awaited by containers queued before", awaitedByOthers))
+	}
+	reserveErr := errors.Join(errs...)
+
+	if reserveErr != nil && lease.WaitTimeout > 0 {
+		if now.Before(deadline) {
+			if queuePosition(gpusTrackerData, containerId) < 0 {
+				gpusTrackerData.Queue = append(gpusTrackerData.Queue, waiter_t{
+					ContainerId: containerId,
+					GPUs:        waitFor,
+					Pid:         lease.Pid,
+					EnqueuedAt:  now,
+					Deadline:    deadline,
+				})
+				slog.Info("Waiting for GPUs in use", "gpus", waitFor, "position", len(gpusTrackerData.Queue),
+					"timeout", lease.WaitTimeout, "error", reserveErr)
+			}
+			if err := gpuTracker.writeGPUTrackerFile(gpusTrackerData); err != nil {
+				return []int{}, false, err
+			}
+			return []int{}, true, nil
+		}
+		reserveErr = fmt.Errorf("timed out after %v waiting for GPUs: %w", lease.WaitTimeout, reserveErr)
+	}
+	removeWaiter(&gpusTrackerData, containerId)
+
+	var allocatedGPUs []int
+	if reserveErr == nil || lease.Policy == BEST_EFFORT {
+		allocatedGPUs = availableGPUs
+		for gpuId, status := range staged {
+			gpusTrackerData.GPUsStatus[gpuId] = status
+		}
+	}
+
+	if len(allocatedGPUs) > 0 {
+		gpusTrackerData.Leases[containerId] = lease_t{
+			CreatedAt:  now,
+			Pid:        lease.Pid,
+			Bundle:     lease.Bundle,
+			TTLSeconds: int64(lease.TTL.Seconds()),
 		}
 	}
 
+	// The file is written even if nothing is reserved, to keep the
+	// release of the expired leases and waiters
 	if err := gpuTracker.writeGPUTrackerFile(gpusTrackerData); err != nil {
-		return []int{}, err
+		return []int{}, false, err
+	}
+
+	if reserveErr != nil && len(allocatedGPUs) == 0 {
+		return []int{}, false, reserveErr
 	}
 
 	if len(allocatedGPUs) > 0 {
 		slog.Info("GPUs allocated", "gpus", allocatedGPUs)
 	}
-	if len(unavailableGPUs) > 0 {
-		return []int{}, fmt.Errorf("GPUs %v are exclusive and already in use", unavailableGPUs)
+	if reserveErr != nil {
+		slog.Warn("Reserved only the available GPUs", "policy", lease.Policy, "error", reserveErr)
 	}
 
-	return allocatedGPUs, nil
+	return allocatedGPUs, false, nil
 }
 
 func (gpuTracker *gpu_tracker_t) ReleaseGPUs(containerId string) error {
@@ -828,7 +1102,7 @@ func (gpuTracker *gpu_tracker_t) ReleaseGPUs(containerId string) error {
 	}
 
 	if gpuTrackerInitialized {
-		gpusTrackerData, err := gpuTracker.readGPUTrackerFile()
+		gpusTrackerData, err := gpuTracker.loadGPUTrackerData()
 		if err != nil {
 			return err
 		}
@@ -837,16 +1111,16 @@ func (gpuTracker *gpu_tracker_t) ReleaseGPUs(containerId string) error {
 		for gpuId, _ := range gpusTrackerData.GPUsStatus {
 			containerIds, released := removeContainerId(containerId, gpusTrackerData.GPUsStatus[gpuId].ContainerIds)
 			if released {
-				gpusTrackerData.GPUsStatus[gpuId] = gpu_status_t{
-					UUID:          gpusTrackerData.GPUsStatus[gpuId].UUID,
-					PartitionType: gpusTrackerData.GPUsStatus[gpuId].PartitionType,
-					Accessibility: gpusTrackerData.GPUsStatus[gpuId].Accessibility,
-					ContainerIds:  containerIds,
-				}
+				status := gpusTrackerData.GPUsStatus[gpuId]
+				status.ContainerIds = containerIds
+				gpusTrackerData.GPUsStatus[gpuId] = status
 				releasedGPUs = append(releasedGPUs, gpuId)
 			}
 		}
 
+		delete(gpusTrackerData.Leases, containerId)
+		removeWaiter(&gpusTrackerData, containerId)
+
 		if err := gpuTracker.writeGPUTrackerFile(gpusTrackerData); err != nil {
 			return err
 		}
@@ -858,14 +1132,38 @@ func (gpuTracker *gpu_tracker_t) ReleaseGPUs(containerId string) error {
 }
 
 func New() (Interface, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	gpuTrackerFile := cfg.GPUTracker.File
+	runtimeRoots := cfg.GPUTracker.RuntimeRoots
 	gpuTracker := &gpu_tracker_t{
-		gpuTrackerLockFile:      gpuTrackerLockFile,
-		isGPUTrackerInitialized: isGPUTrackerInitialized,
-		initializeGPUTracker:    initializeGPUTracker,
-		parseGPUsList:           parseGPUsList,
-		readGPUTrackerFile:      readGPUTrackerFile,
-		writeGPUTrackerFile:     writeGPUTrackerFile,
-		validateGPUsInfo:        validateGPUsInfo,
+		gpuTrackerLockFile: cfg.GPUTracker.LockFile,
+		isGPUTrackerInitialized: func() (bool, error) {
+			return isGPUTrackerInitialized(gpuTrackerFile)
+		},
+		initializeGPUTracker: func() error {
+			return initializeGPUTracker(gpuTrackerFile)
+		},
+		parseGPUsList: parseGPUsList,
+		readGPUTrackerFile: func() (gpu_tracker_data_t, error) {
+			return readGPUTrackerFile(gpuTrackerFile)
+		},
+		writeGPUTrackerFile: func(gpuTrackerData gpu_tracker_data_t) error {
+			return writeGPUTrackerFile(gpuTrackerFile, gpuTrackerData)
+		},
+		validateGPUsInfo: validateGPUsInfo,
+		getGPUInventory:  amdgpu.GetGPUInventory,
+		getBootId:        getBootId,
+		now:              time.Now,
+		sleep:            time.Sleep,
+		containerExists: func(containerId string) (bool, error) {
+			return containerExists(runtimeRoots, containerId)
+		},
+		bundleExists:  bundleExists,
+		processExists: processExists,
 	}
 	return gpuTracker, nil
 }
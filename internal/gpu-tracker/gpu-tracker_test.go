@@ -1,11 +1,14 @@
 package gpuTracker
 
 import (
+	"encoding/json"
 	"fmt"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ROCm/container-toolkit/internal/amdgpu"
 )
@@ -139,7 +142,13 @@ func mockParseGPUsList(gpus string) ([]int, []string, []string, error) {
 
 func mockReadGPUTrackerFile() (gpu_tracker_data_t, error) {
 	return gpu_tracker_data_t{
+		Version: GPU_TRACKER_VERSION,
 		Enabled: true,
+		BootId:  "boot-1",
+		Leases: map[string]lease_t{
+			"container_1": {},
+			"container_2": {},
+		},
 		GPUsStatus: map[int]gpu_status_t{
 			0: {
 				UUID:          "0xef2c1799a1f3e2ed",
@@ -181,6 +190,10 @@ func mockValidateGPUsInfo(map[int]amdgpu.DeviceInfo) (bool, error) {
 	return true, nil
 }
 
+func mockGetBootId() (string, error) {
+	return "boot-1", nil
+}
+
 func TestInterface(t *testing.T) {
 	gpuTracker := &gpu_tracker_t{
 		gpuTrackerLockFile:      "/tmp/gpu-tracker.lock",
@@ -190,6 +203,8 @@ func TestInterface(t *testing.T) {
 		readGPUTrackerFile:      mockReadGPUTrackerFile,
 		writeGPUTrackerFile:     mockWriteGPUTrackerFile,
 		validateGPUsInfo:        mockValidateGPUsInfo,
+		getBootId:               mockGetBootId,
+		now:                     time.Now,
 	}
 
 	err := gpuTracker.Init()
@@ -211,21 +226,228 @@ func TestInterface(t *testing.T) {
 	Assert(t, err == nil, fmt.Sprintf("MakeGPUsShared() returned error %v", err))
 
 	// Reserve Shared GPU
-	_, err = gpuTracker.ReserveGPUs("0xef2c1799a1f3e2ed", "container_3")
+	_, err = gpuTracker.ReserveGPUs("0xef2c1799a1f3e2ed", "container_3", Lease{})
 	Assert(t, err == nil, fmt.Sprintf("ReserveGPUs() returned error %v", err))
 
 	// Reserve Exclusive GPU that is already assigned
-	_, err = gpuTracker.ReserveGPUs("0x1234567890abcdef", "container_3")
+	_, err = gpuTracker.ReserveGPUs("0x1234567890abcdef", "container_3", Lease{})
 	Assert(t, err != nil, fmt.Sprintf("ReserveGPUs() did not returned error when expected"))
 
 	// Reserve Shared and Exclusive GPU that are already assigned
-	_, err = gpuTracker.ReserveGPUs("0,0x1234567890abcdef", "container_3")
+	_, err = gpuTracker.ReserveGPUs("0,0x1234567890abcdef", "container_3", Lease{})
 	Assert(t, err != nil, fmt.Sprintf("ReserveGPUs() did not returned error when expected"))
 
 	err = gpuTracker.ReleaseGPUs("container_1")
 	Assert(t, err == nil, fmt.Sprintf("ReleaseGPUs() returned error %v", err))
 }
 
+func TestSharedLimited(t *testing.T) {
+	stored, _ := mockReadGPUTrackerFile()
+	gpuTracker := &gpu_tracker_t{
+		gpuTrackerLockFile:      "/tmp/gpu-tracker.lock",
+		isGPUTrackerInitialized: mockIsGPUTrackerInitialized,
+		initializeGPUTracker:    mockInitializeGPUTracker,
+		parseGPUsList:           mockParseGPUsList,
+		readGPUTrackerFile: func() (gpu_tracker_data_t, error) {
+			return stored, nil
+		},
+		writeGPUTrackerFile: func(data gpu_tracker_data_t) error {
+			stored = data
+			return nil
+		},
+		validateGPUsInfo: mockValidateGPUsInfo,
+		getBootId:        mockGetBootId,
+		now:              time.Now,
+	}
+
+	_, err := gpuTracker.MakeGPUsSharedLimited("0", 0)
+	Assert(t, err != nil, "MakeGPUsSharedLimited() accepted 0 containers")
+
+	// GPU 0 is used by 2 containers
+	res, err := gpuTracker.MakeGPUsSharedLimited("0", 1)
+	Assert(t, err == nil, fmt.Sprintf("MakeGPUsSharedLimited() returned error %v", err))
+	Assert(t, len(res.Changed) == 0 && len(res.NotChanged) == 1 && res.NotChanged[0] == 0,
+		fmt.Sprintf("MakeGPUsSharedLimited() changed a GPU used by too many containers: %+v", res))
+
+	res, err = gpuTracker.MakeGPUsSharedLimited("0", 2)
+	Assert(t, err == nil, fmt.Sprintf("MakeGPUsSharedLimited() returned error %v", err))
+	Assert(t, len(res.Changed) == 1 && res.Accessibility == "Shared(2)", fmt.Sprintf("unexpected result %+v", res))
+
+	_, err = gpuTracker.ReserveGPUs("0", "container_3", Lease{})
+	Assert(t, err != nil, "ReserveGPUs() did not return error when the GPU is used by the maximum number of containers")
+
+	_, err = gpuTracker.MakeGPUsSharedLimited("0", 3)
+	Assert(t, err == nil, fmt.Sprintf("MakeGPUsSharedLimited() returned error %v", err))
+
+	_, err = gpuTracker.ReserveGPUs("0", "container_3", Lease{})
+	Assert(t, err == nil, fmt.Sprintf("ReserveGPUs() returned error %v", err))
+
+	entries, err := gpuTracker.ShowStatus()
+	Assert(t, err == nil, fmt.Sprintf("ShowStatus() returned error %v", err))
+	Assert(t, entries[0].Accessibility == SharedLimitedAccess(3) && entries[0].MaxContainers == 3 && len(entries[0].ContainerIds) == 3,
+		fmt.Sprintf("unexpected status of GPU 0: %+v", entries[0]))
+	Assert(t, entries[1].Accessibility == EXCLUSIVE_ACCESS && entries[1].MaxContainers == 1,
+		fmt.Sprintf("unexpected status of GPU 1: %+v", entries[1]))
+
+	// The limit is kept when the tracker file is written and read back
+	data, err := json.Marshal(stored.GPUsStatus[0])
+	Assert(t, err == nil, fmt.Sprintf("failed to marshal GPU status, Err: %v", err))
+	var status gpu_status_t
+	err = json.Unmarshal(data, &status)
+	Assert(t, err == nil, fmt.Sprintf("failed to unmarshal GPU status, Err: %v", err))
+	Assert(t, status.Accessibility == sharedLimitedAccessInt && status.MaxContainers == 3,
+		fmt.Sprintf("unexpected GPU status %s", data))
+
+	// Releasing a container makes room for another one
+	err = gpuTracker.ReleaseGPUs("container_1")
+	Assert(t, err == nil, fmt.Sprintf("ReleaseGPUs() returned error %v", err))
+	Assert(t, stored.GPUsStatus[0].MaxContainers == 3, "ReleaseGPUs() dropped the maximum number of containers")
+	_, err = gpuTracker.ReserveGPUs("0", "container_4", Lease{})
+	Assert(t, err == nil, fmt.Sprintf("ReserveGPUs() returned error %v", err))
+
+	_, err = gpuTracker.MakeGPUsShared("0")
+	Assert(t, err == nil, fmt.Sprintf("MakeGPUsShared() returned error %v", err))
+	Assert(t, stored.GPUsStatus[0].Accessibility == sharedAccessInt && stored.GPUsStatus[0].MaxContainers == 0,
+		fmt.Sprintf("MakeGPUsShared() kept the limit: %+v", stored.GPUsStatus[0]))
+}
+
+func mockGetGPUInventory() ([]amdgpu.GPUInfo, error) {
+	return []amdgpu.GPUInfo{
+		{Index: 0, PCIBusID: "0000:05:00.0", NUMANode: 0, KFDNodeID: 1},
+		{Index: 1, PCIBusID: "0000:85:00.0", NUMANode: 1, KFDNodeID: 2},
+	}, nil
+}
+
+func TestReserveGPUsCount(t *testing.T) {
+	var written gpu_tracker_data_t
+	gpuTracker := &gpu_tracker_t{
+		gpuTrackerLockFile:      "/tmp/gpu-tracker.lock",
+		isGPUTrackerInitialized: mockIsGPUTrackerInitialized,
+		initializeGPUTracker:    mockInitializeGPUTracker,
+		parseGPUsList:           mockParseGPUsList,
+		readGPUTrackerFile: func() (gpu_tracker_data_t, error) {
+			data, err := mockReadGPUTrackerFile()
+			data.GPUsStatus[1] = gpu_status_t{
+				UUID:          "0x1234567890abcdef",
+				Accessibility: exclusiveAccessInt,
+				ContainerIds:  []string{},
+			}
+			return data, err
+		},
+		writeGPUTrackerFile: func(data gpu_tracker_data_t) error {
+			written = data
+			return nil
+		},
+		validateGPUsInfo: mockValidateGPUsInfo,
+		getGPUInventory:  mockGetGPUInventory,
+		getBootId:        mockGetBootId,
+		now:              time.Now,
+	}
+
+	// GPU 0 is used by containers, GPU 1 is the only free GPU
+	gpuIds, err := gpuTracker.ReserveGPUs("count:1", "container_3", Lease{})
+	Assert(t, err == nil, fmt.Sprintf("ReserveGPUs() returned error %v", err))
+	Assert(t, len(gpuIds) == 1 && gpuIds[0] == 1, fmt.Sprintf("ReserveGPUs() reserved %v instead of [1]", gpuIds))
+	Assert(t, len(written.GPUsStatus[1].ContainerIds) == 1, fmt.Sprintf("GPU 1 not reserved: %+v", written.GPUsStatus[1]))
+
+	_, err = gpuTracker.ReserveGPUs("count:2", "container_3", Lease{})
+	Assert(t, err != nil, "ReserveGPUs() did not return error when not enough GPUs are free")
+
+	_, err = gpuTracker.ReserveGPUs("0,count:1", "container_3", Lease{})
+	Assert(t, err != nil, "ReserveGPUs() did not return error when the selected GPUs are in use")
+
+	_, err = gpuTracker.ReserveGPUs("count:0", "container_3", Lease{})
+	Assert(t, err != nil, "ReserveGPUs() accepted an invalid count")
+}
+
+func TestReserveGPUsPartialFailure(t *testing.T) {
+	var stored gpu_tracker_data_t
+	writes := 0
+	gpuTracker := &gpu_tracker_t{
+		gpuTrackerLockFile:      "/tmp/gpu-tracker.lock",
+		isGPUTrackerInitialized: mockIsGPUTrackerInitialized,
+		initializeGPUTracker:    mockInitializeGPUTracker,
+		parseGPUsList:           mockParseGPUsList,
+		readGPUTrackerFile: func() (gpu_tracker_data_t, error) {
+			return stored, nil
+		},
+		writeGPUTrackerFile: func(data gpu_tracker_data_t) error {
+			stored = data
+			writes++
+			return nil
+		},
+		validateGPUsInfo: mockValidateGPUsInfo,
+		getBootId:        mockGetBootId,
+		now:              time.Now,
+	}
+
+	// GPU 0 is shared and GPU 1 is exclusive and used by container_1
+	stored, _ = mockReadGPUTrackerFile()
+	gpuIds, err := gpuTracker.ReserveGPUs("0,1", "container_3", Lease{})
+	Assert(t, err != nil && len(gpuIds) == 0, fmt.Sprintf("ReserveGPUs() returned %v, %v instead of an error", gpuIds, err))
+	Assert(t, !slices.Contains(stored.GPUsStatus[0].ContainerIds, "container_3"), fmt.Sprintf("GPU 0 reserved on failure: %+v", stored.GPUsStatus[0]))
+	_, found := stored.Leases["container_3"]
+	Assert(t, !found, "lease recorded on failure")
+
+	// The same with the policy set explicitly
+	gpuIds, err = gpuTracker.ReserveGPUs("0,1", "container_3", Lease{Policy: ALL_OR_NOTHING})
+	Assert(t, err != nil && len(gpuIds) == 0, fmt.Sprintf("ReserveGPUs() returned %v, %v instead of an error", gpuIds, err))
+	Assert(t, len(stored.GPUsStatus[0].ContainerIds) == 2, fmt.Sprintf("GPU 0 reserved on failure: %+v", stored.GPUsStatus[0]))
+
+	// A GPU listed twice cannot exceed the limit of its accessibility
+	stored, _ = mockReadGPUTrackerFile()
+	stored.GPUsStatus[1] = gpu_status_t{UUID: "0x1234567890abcdef", Accessibility: exclusiveAccessInt, ContainerIds: []string{}}
+	gpuIds, err = gpuTracker.ReserveGPUs("1,1", "container_3", Lease{})
+	Assert(t, err != nil && len(stored.GPUsStatus[1].ContainerIds) == 0,
+		fmt.Sprintf("ReserveGPUs() returned %v, %v and left GPU 1 %+v", gpuIds, err, stored.GPUsStatus[1]))
+
+	// Best effort reserves the available GPUs only
+	stored, _ = mockReadGPUTrackerFile()
+	gpuIds, err = gpuTracker.ReserveGPUs("0,1", "container_3", Lease{Policy: BEST_EFFORT})
+	Assert(t, err == nil, fmt.Sprintf("ReserveGPUs() returned error %v", err))
+	Assert(t, len(gpuIds) == 1 && gpuIds[0] == 0, fmt.Sprintf("ReserveGPUs() reserved %v instead of [0]", gpuIds))
+	Assert(t, slices.Contains(stored.GPUsStatus[0].ContainerIds, "container_3"), fmt.Sprintf("GPU 0 not reserved: %+v", stored.GPUsStatus[0]))
+	Assert(t, !slices.Contains(stored.GPUsStatus[1].ContainerIds, "container_3"), fmt.Sprintf("GPU 1 reserved: %+v", stored.GPUsStatus[1]))
+	_, found = stored.Leases["container_3"]
+	Assert(t, found, "lease not recorded")
+
+	// Best effort fails when none of the GPUs are available
+	writes = 0
+	gpuIds, err = gpuTracker.ReserveGPUs("1", "container_4", Lease{Policy: BEST_EFFORT})
+	Assert(t, err != nil && len(gpuIds) == 0, fmt.Sprintf("ReserveGPUs() returned %v, %v instead of an error", gpuIds, err))
+	_, found = stored.Leases["container_4"]
+	Assert(t, !found && writes == 1, fmt.Sprintf("unexpected lease or %d writes on failure", writes))
+
+	// Both exclusive and full Shared(N) GPUs are reported
+	stored, _ = mockReadGPUTrackerFile()
+	stored.GPUsStatus[0] = gpu_status_t{UUID: "0xef2c1799a1f3e2ed", Accessibility: sharedLimitedAccessInt, MaxContainers: 2,
+		ContainerIds: []string{"container_1", "container_2"}}
+	_, err = gpuTracker.ReserveGPUs("0,1", "container_3", Lease{})
+	Assert(t, err != nil && strings.Contains(err.Error(), "[1] are exclusive") && strings.Contains(err.Error(), "[0] are already in use by the maximum"),
+		fmt.Sprintf("unexpected error %v", err))
+}
+
+func TestSplitCount(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		count    int
+		wantErr  bool
+	}{
+		{"0,1", "0,1", 0, false},
+		{"count:2", "all", 2, false},
+		{"numa:0,count:2", "numa:0", 2, false},
+		{"count:2,count:3", "", 0, true},
+		{"count:x", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		gpus, count, err := splitCount(tt.input)
+		Assert(t, (err != nil) == tt.wantErr, fmt.Sprintf("%s: unexpected error %v", tt.input, err))
+		Assert(t, gpus == tt.expected && count == tt.count, fmt.Sprintf("%s: got %q, %d", tt.input, gpus, count))
+	}
+}
+
 func Assert(t *testing.T, b bool, errString string) {
 	if !b {
 		t.Errorf(errString)
@@ -0,0 +1,278 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package gpuTracker
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// Version of the GPU Tracker file written by this release. Files
+	// without a version are version 1, from before the leases.
+	GPU_TRACKER_VERSION = 2
+
+	// Environment variable of a container that sets the TTL of its lease,
+	// e.g. AMD_GPU_LEASE_TTL=24h
+	LEASE_TTL_ENV = "AMD_GPU_LEASE_TTL"
+
+	// Environment variable of a container that sets its ReservePolicy,
+	// e.g. AMD_GPU_RESERVE_POLICY=best-effort
+	RESERVE_POLICY_ENV = "AMD_GPU_RESERVE_POLICY"
+
+	// Environment variable of a container that sets how long it waits for
+	// GPUs in use, e.g. AMD_GPU_WAIT_TIMEOUT=300s
+	WAIT_TIMEOUT_ENV = "AMD_GPU_WAIT_TIMEOUT"
+)
+
+// ReservePolicy decides what ReserveGPUs does when some of the requested
+// GPUs are in use
+type ReservePolicy string
+
+const (
+	// Reserve all the requested GPUs or none of them
+	ALL_OR_NOTHING ReservePolicy = "all-or-nothing"
+
+	// Reserve the requested GPUs that are available
+	BEST_EFFORT ReservePolicy = "best-effort"
+)
+
+// Lease describes the reservation of GPUs requested by a container
+type Lease struct {
+	// Pid is the PID of the runtime making the reservation
+	Pid int
+
+	// Bundle is the bundle directory of the container
+	Bundle string
+
+	// TTL is how long the reservation lasts unless renewed, 0 for as long
+	// as the container exists
+	TTL time.Duration
+
+	// Policy is the ReservePolicy of the reservation, ALL_OR_NOTHING if
+	// it is empty
+	Policy ReservePolicy
+
+	// WaitTimeout is how long to wait in the queue for GPUs in use, 0 to
+	// fail at once
+	WaitTimeout time.Duration
+}
+
+// LeaseEntry represents the reservation of a GPU by a container
+type LeaseEntry struct {
+	ContainerId string `json:"containerId" yaml:"containerId"`
+	CreatedAt   string `json:"createdAt" yaml:"createdAt"`
+	AgeSeconds  int64  `json:"ageSeconds" yaml:"ageSeconds"`
+	Pid         int    `json:"pid" yaml:"pid"`
+	Bundle      string `json:"bundle" yaml:"bundle"`
+	TTLSeconds  int64  `json:"ttlSeconds" yaml:"ttlSeconds"`
+	Expired     bool   `json:"expired" yaml:"expired"`
+}
+
+type lease_t struct {
+	// Time the reservation was made, zero if it predates the leases
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Time the lease was last renewed, zero if it never was
+	RenewedAt time.Time `json:"renewedAt"`
+
+	// PID of the runtime that made the reservation
+	Pid int `json:"pid"`
+
+	// Bundle directory of the container
+	Bundle string `json:"bundle"`
+
+	// TTL of the lease in seconds, 0 if it does not expire
+	TTLSeconds int64 `json:"ttlSeconds"`
+}
+
+// expired reports whether the TTL of the lease has passed since it was
+// created or last renewed
+func (l lease_t) expired(now time.Time) bool {
+	if l.TTLSeconds <= 0 {
+		return false
+	}
+	start := l.CreatedAt
+	if l.RenewedAt.After(start) {
+		start = l.RenewedAt
+	}
+	return !start.IsZero() && now.Sub(start) > time.Duration(l.TTLSeconds)*time.Second
+}
+
+// entry returns the status of the lease of a container. The age of a lease
+// that predates the leases is unknown, and reported as -1.
+func (l lease_t) entry(containerId string, now time.Time) LeaseEntry {
+	e := LeaseEntry{
+		ContainerId: containerId,
+		AgeSeconds:  -1,
+		Pid:         l.Pid,
+		Bundle:      l.Bundle,
+		TTLSeconds:  l.TTLSeconds,
+		Expired:     l.expired(now),
+	}
+	if !l.CreatedAt.IsZero() {
+		e.CreatedAt = l.CreatedAt.UTC().Format(time.RFC3339)
+		e.AgeSeconds = int64(now.Sub(l.CreatedAt).Seconds())
+	}
+	return e
+}
+
+// LeaseTTLFromEnv returns the lease TTL requested in the environment of a
+// container, 0 if none is
+func LeaseTTLFromEnv(env []string) (time.Duration, error) {
+	return durationFromEnv(env, LEASE_TTL_ENV, "24h")
+}
+
+// durationFromEnv returns the duration set by the variable name in the
+// environment of a container, 0 if it is not set
+func durationFromEnv(env []string, name string, example string) (time.Duration, error) {
+	for _, e := range env {
+		key, value, found := strings.Cut(e, "=")
+		if !found || key != name || value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid %s %q: must be a positive duration, e.g. %s", name, value, example)
+		}
+		return d, nil
+	}
+	return 0, nil
+}
+
+// ReservePolicyFromEnv returns the ReservePolicy requested in the
+// environment of a container, ALL_OR_NOTHING if none is
+func ReservePolicyFromEnv(env []string) (ReservePolicy, error) {
+	for _, e := range env {
+		name, value, found := strings.Cut(e, "=")
+		if !found || name != RESERVE_POLICY_ENV || value == "" {
+			continue
+		}
+		switch policy := ReservePolicy(value); policy {
+		case ALL_OR_NOTHING, BEST_EFFORT:
+			return policy, nil
+		default:
+			return "", fmt.Errorf("invalid %s %q: must be either %s or %s", RESERVE_POLICY_ENV, value, ALL_OR_NOTHING, BEST_EFFORT)
+		}
+	}
+	return ALL_OR_NOTHING, nil
+}
+
+// LeaseFromEnv returns the Lease requested in the environment of a
+// container. The caller sets the Pid and Bundle of the lease.
+func LeaseFromEnv(env []string) (Lease, error) {
+	ttl, err := LeaseTTLFromEnv(env)
+	if err != nil {
+		return Lease{}, err
+	}
+
+	policy, err := ReservePolicyFromEnv(env)
+	if err != nil {
+		return Lease{}, err
+	}
+
+	waitTimeout, err := durationFromEnv(env, WAIT_TIMEOUT_ENV, "300s")
+	if err != nil {
+		return Lease{}, err
+	}
+
+	return Lease{TTL: ttl, Policy: policy, WaitTimeout: waitTimeout}, nil
+}
+
+// migrateGPUTrackerData upgrades GPU Tracker data read from an older file
+// to the current version
+func migrateGPUTrackerData(gpusTrackerData *gpu_tracker_data_t) error {
+	if gpusTrackerData.Version > GPU_TRACKER_VERSION {
+		return fmt.Errorf("GPU Tracker file version %d is newer than the supported version %d", gpusTrackerData.Version, GPU_TRACKER_VERSION)
+	}
+
+	if gpusTrackerData.Leases == nil {
+		gpusTrackerData.Leases = make(map[string]lease_t)
+	}
+
+	// Version 1 only had the container IDs, the reservations are kept with
+	// leases of unknown age that do not expire
+	if gpusTrackerData.Version < 2 {
+		for _, status := range gpusTrackerData.GPUsStatus {
+			for _, id := range status.ContainerIds {
+				if _, exists := gpusTrackerData.Leases[id]; !exists {
+					gpusTrackerData.Leases[id] = lease_t{}
+				}
+			}
+		}
+	}
+
+	gpusTrackerData.Version = GPU_TRACKER_VERSION
+	return nil
+}
+
+// releaseExpired releases the GPUs of the containers whose lease expired,
+// and returns these containers
+func releaseExpired(gpusTrackerData *gpu_tracker_data_t, now time.Time) []string {
+	released := releaseContainers(gpusTrackerData, func(id string) bool {
+		return !gpusTrackerData.Leases[id].expired(now)
+	})
+	if len(released) > 0 {
+		slog.Info("Released GPUs of containers whose lease expired", "containers", released)
+	}
+	return released
+}
+
+func (gpuTracker *gpu_tracker_t) RenewLease(containerId string) error {
+	lock, err := acquireLock(gpuTracker.gpuTrackerLockFile, defaultLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	gpuTrackerInitialized, err := gpuTracker.isGPUTrackerInitialized()
+	if err != nil {
+		return err
+	}
+	if !gpuTrackerInitialized {
+		return fmt.Errorf("container %v has no reservation", containerId)
+	}
+
+	gpusTrackerData, err := gpuTracker.loadGPUTrackerData()
+	if err != nil {
+		return err
+	}
+
+	lease, exists := gpusTrackerData.Leases[containerId]
+	if !exists {
+		return fmt.Errorf("container %v has no reservation", containerId)
+	}
+	now := gpuTracker.now()
+	if lease.expired(now) {
+		return fmt.Errorf("lease of container %v has expired", containerId)
+	}
+
+	lease.RenewedAt = now
+	gpusTrackerData.Leases[containerId] = lease
+	return gpuTracker.writeGPUTrackerFile(gpusTrackerData)
+}
+
+// leaseEntries returns the status of the leases of the given containers
+func leaseEntries(gpusTrackerData gpu_tracker_data_t, containerIds []string, now time.Time) []LeaseEntry {
+	entries := []LeaseEntry{}
+	for _, id := range containerIds {
+		entries = append(entries, gpusTrackerData.Leases[id].entry(id, now))
+	}
+	return entries
+}
@@ -0,0 +1,131 @@
+package gpuTracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMigrateGPUTrackerData(t *testing.T) {
+	// A file written before the leases, without a version
+	legacy := `{"enabled": true, "gpusStatus": {"0": {"uuid": "0x1", "accessibility": 1, "containerIds": ["c1"]},
+		"1": {"uuid": "0x2", "accessibility": 0, "containerIds": ["c1", "c2"]}}, "gpusInfo": {}}`
+	f := filepath.Join(t.TempDir(), "gpu-tracker.json")
+	Assert(t, os.WriteFile(f, []byte(legacy), 0644) == nil, "failed to write GPU Tracker file")
+
+	data, err := readGPUTrackerFile(f)
+	Assert(t, err == nil, fmt.Sprintf("readGPUTrackerFile() returned error %v", err))
+	Assert(t, data.Version == GPU_TRACKER_VERSION, fmt.Sprintf("unexpected version %v", data.Version))
+	Assert(t, len(data.Leases) == 2, fmt.Sprintf("unexpected leases %v", data.Leases))
+	entry := data.Leases["c2"].entry("c2", time.Now())
+	Assert(t, entry.AgeSeconds == -1 && entry.CreatedAt == "" && !entry.Expired, fmt.Sprintf("unexpected migrated lease %+v", entry))
+
+	Assert(t, writeGPUTrackerFile(f, data) == nil, "failed to write GPU Tracker file")
+	raw := map[string]interface{}{}
+	content, _ := os.ReadFile(f)
+	Assert(t, json.Unmarshal(content, &raw) == nil && raw["version"] == float64(GPU_TRACKER_VERSION), fmt.Sprintf("unexpected file %s", content))
+
+	// Files of a newer release are not understood
+	Assert(t, os.WriteFile(f, []byte(`{"version": 99, "gpusStatus": {}, "gpusInfo": {}}`), 0644) == nil, "failed to write GPU Tracker file")
+	_, err = readGPUTrackerFile(f)
+	Assert(t, err != nil, "readGPUTrackerFile() did not return error for a newer version")
+}
+
+func TestLeaseExpired(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		lease   lease_t
+		expired bool
+	}{
+		{lease_t{CreatedAt: now.Add(-2 * time.Hour)}, false},
+		{lease_t{CreatedAt: now.Add(-2 * time.Hour), TTLSeconds: 3600}, true},
+		{lease_t{CreatedAt: now.Add(-30 * time.Minute), TTLSeconds: 3600}, false},
+		{lease_t{CreatedAt: now.Add(-2 * time.Hour), RenewedAt: now.Add(-10 * time.Minute), TTLSeconds: 3600}, false},
+		{lease_t{TTLSeconds: 3600}, false},
+	}
+	for i, tc := range tests {
+		Assert(t, tc.lease.expired(now) == tc.expired, fmt.Sprintf("%d: expected expired %v for %+v", i, tc.expired, tc.lease))
+	}
+
+	e := lease_t{CreatedAt: now.Add(-90 * time.Minute), Pid: 42, TTLSeconds: 3600}.entry("c1", now)
+	Assert(t, e.AgeSeconds == 5400 && e.CreatedAt == "2025-06-01T10:30:00Z" && e.Expired, fmt.Sprintf("unexpected lease entry %+v", e))
+}
+
+func TestLeaseTTLFromEnv(t *testing.T) {
+	ttl, err := LeaseTTLFromEnv([]string{"PATH=/usr/bin", "AMD_GPU_LEASE_TTL=90m"})
+	Assert(t, err == nil && ttl == 90*time.Minute, fmt.Sprintf("unexpected TTL %v, err %v", ttl, err))
+
+	ttl, err = LeaseTTLFromEnv([]string{"PATH=/usr/bin"})
+	Assert(t, err == nil && ttl == 0, fmt.Sprintf("unexpected TTL %v, err %v", ttl, err))
+
+	_, err = LeaseTTLFromEnv([]string{"AMD_GPU_LEASE_TTL=1 day"})
+	Assert(t, err != nil, "LeaseTTLFromEnv() accepted an invalid duration")
+}
+
+func TestLeaseFromEnv(t *testing.T) {
+	lease, err := LeaseFromEnv([]string{"AMD_GPU_LEASE_TTL=1h", "AMD_GPU_RESERVE_POLICY=best-effort"})
+	Assert(t, err == nil && lease.TTL == time.Hour && lease.Policy == BEST_EFFORT, fmt.Sprintf("unexpected lease %+v, err %v", lease, err))
+
+	lease, err = LeaseFromEnv([]string{"PATH=/usr/bin"})
+	Assert(t, err == nil && lease.TTL == 0 && lease.Policy == ALL_OR_NOTHING, fmt.Sprintf("unexpected lease %+v, err %v", lease, err))
+
+	_, err = LeaseFromEnv([]string{"AMD_GPU_RESERVE_POLICY=some"})
+	Assert(t, err != nil, "LeaseFromEnv() accepted an invalid policy")
+
+	lease, err = LeaseFromEnv([]string{"AMD_GPU_WAIT_TIMEOUT=300s"})
+	Assert(t, err == nil && lease.WaitTimeout == 5*time.Minute, fmt.Sprintf("unexpected lease %+v, err %v", lease, err))
+
+	_, err = LeaseFromEnv([]string{"AMD_GPU_WAIT_TIMEOUT=-1s"})
+	Assert(t, err != nil, "LeaseFromEnv() accepted a negative wait timeout")
+}
+
+func TestReserveGPUsLease(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	data, _ := mockReadGPUTrackerFile()
+	// The exclusive GPU 1 is held by container_1, whose lease has expired
+	data.Leases["container_1"] = lease_t{CreatedAt: now.Add(-2 * time.Hour), TTLSeconds: 3600}
+
+	gpuTracker := &gpu_tracker_t{
+		gpuTrackerLockFile:      filepath.Join(t.TempDir(), "gpu-tracker.lock"),
+		isGPUTrackerInitialized: mockIsGPUTrackerInitialized,
+		parseGPUsList:           mockParseGPUsList,
+		readGPUTrackerFile:      func() (gpu_tracker_data_t, error) { return data, nil },
+		writeGPUTrackerFile: func(d gpu_tracker_data_t) error {
+			data = d
+			return nil
+		},
+		validateGPUsInfo: mockValidateGPUsInfo,
+		getBootId:        mockGetBootId,
+		now:              func() time.Time { return now },
+	}
+
+	gpuIds, err := gpuTracker.ReserveGPUs("1", "container_3", Lease{Pid: 42, Bundle: "/run/bundle", TTL: time.Hour})
+	Assert(t, err == nil && slices.Equal(gpuIds, []int{1}), fmt.Sprintf("ReserveGPUs() = %v, %v", gpuIds, err))
+	Assert(t, slices.Equal(data.GPUsStatus[1].ContainerIds, []string{"container_3"}), fmt.Sprintf("unexpected containers of GPU 1 %v", data.GPUsStatus[1].ContainerIds))
+	Assert(t, slices.Equal(data.GPUsStatus[0].ContainerIds, []string{"container_2"}), fmt.Sprintf("the expired lease was not released from GPU 0: %v", data.GPUsStatus[0].ContainerIds))
+	_, exists := data.Leases["container_1"]
+	Assert(t, !exists, "the expired lease was not removed")
+
+	lease := data.Leases["container_3"]
+	Assert(t, lease.CreatedAt.Equal(now) && lease.Pid == 42 && lease.Bundle == "/run/bundle" && lease.TTLSeconds == 3600, fmt.Sprintf("unexpected lease %+v", lease))
+
+	// Renewing restarts the TTL
+	now = now.Add(50 * time.Minute)
+	Assert(t, gpuTracker.RenewLease("container_3") == nil, "RenewLease() failed")
+	now = now.Add(50 * time.Minute)
+	Assert(t, !data.Leases["container_3"].expired(now), "the renewed lease has expired")
+	Assert(t, gpuTracker.RenewLease("container_4") != nil, "RenewLease() did not return error for a container without reservation")
+
+	entries, err := gpuTracker.ShowStatus()
+	Assert(t, err == nil, fmt.Sprintf("ShowStatus() returned error %v", err))
+	Assert(t, len(entries[1].Leases) == 1 && entries[1].Leases[0].AgeSeconds == 6000, fmt.Sprintf("unexpected leases of GPU 1 %+v", entries[1].Leases))
+
+	Assert(t, gpuTracker.ReleaseGPUs("container_3") == nil, "ReleaseGPUs() failed")
+	_, exists = data.Leases["container_3"]
+	Assert(t, !exists, "the lease of a released container was not removed")
+}
@@ -0,0 +1,171 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package gpuTracker
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// waitPollInterval is how often a container waiting for GPUs tries to
+// reserve them again
+const waitPollInterval = time.Second
+
+// QueueEntry represents a container waiting for GPUs in use
+type QueueEntry struct {
+	Position         int    `json:"position" yaml:"position"`
+	ContainerId      string `json:"containerId" yaml:"containerId"`
+	GPUs             []int  `json:"gpus" yaml:"gpus"`
+	Pid              int    `json:"pid" yaml:"pid"`
+	WaitingSeconds   int64  `json:"waitingSeconds" yaml:"waitingSeconds"`
+	RemainingSeconds int64  `json:"remainingSeconds" yaml:"remainingSeconds"`
+}
+
+type waiter_t struct {
+	// Container Id of the waiting container
+	ContainerId string `json:"containerId"`
+
+	// GPUs the container waits for
+	GPUs []int `json:"gpus"`
+
+	// PID of the runtime waiting for the GPUs
+	Pid int `json:"pid"`
+
+	// Time the container joined the queue
+	EnqueuedAt time.Time `json:"enqueuedAt"`
+
+	// Time the container stops waiting
+	Deadline time.Time `json:"deadline"`
+}
+
+// queuePosition returns the index of a container in the queue, -1 if it
+// is not waiting
+func queuePosition(gpusTrackerData gpu_tracker_data_t, containerId string) int {
+	return slices.IndexFunc(gpusTrackerData.Queue, func(w waiter_t) bool {
+		return w.ContainerId == containerId
+	})
+}
+
+// removeWaiter removes a container from the queue
+func removeWaiter(gpusTrackerData *gpu_tracker_data_t, containerId string) {
+	gpusTrackerData.Queue = slices.DeleteFunc(gpusTrackerData.Queue, func(w waiter_t) bool {
+		return w.ContainerId == containerId
+	})
+}
+
+// dropExpiredWaiters removes the containers that waited past their
+// deadline, and those whose runtime is gone, e.g. because it was killed
+// while waiting
+func dropExpiredWaiters(gpusTrackerData *gpu_tracker_data_t, now time.Time, processExists processExistsType) {
+	gpusTrackerData.Queue = slices.DeleteFunc(gpusTrackerData.Queue, func(w waiter_t) bool {
+		if now.After(w.Deadline) {
+			slog.Info("Dropped container that waited for GPUs past its deadline", "container", w.ContainerId)
+			return true
+		}
+		if w.Pid > 0 && processExists != nil && !processExists(w.Pid) {
+			slog.Info("Dropped container whose runtime exited while waiting for GPUs", "container", w.ContainerId, "pid", w.Pid)
+			return true
+		}
+		return false
+	})
+}
+
+// processExists returns false only if no process has the given PID, so
+// that a process of another user is not taken for an exited one
+func processExists(pid int) bool {
+	return unix.Kill(pid, 0) != unix.ESRCH
+}
+
+// awaitedGPUs returns the GPUs that containers queued before containerId
+// wait for, or that all the queued containers wait for if containerId is
+// not in the queue. Only GPUs limited to a number of containers are
+// returned, as waiting for the others is never needed.
+func awaitedGPUs(gpusTrackerData gpu_tracker_data_t, containerId string) map[int]bool {
+	ahead := gpusTrackerData.Queue
+	if pos := queuePosition(gpusTrackerData, containerId); pos >= 0 {
+		ahead = ahead[:pos]
+	}
+
+	awaited := make(map[int]bool)
+	for _, w := range ahead {
+		for _, gpuId := range w.GPUs {
+			if gpusTrackerData.GPUsStatus[gpuId].maxContainers() > 0 {
+				awaited[gpuId] = true
+			}
+		}
+	}
+	return awaited
+}
+
+// queueEntries returns the entries of the queue, in the order the
+// containers get their GPUs
+func queueEntries(gpusTrackerData gpu_tracker_data_t, now time.Time) []QueueEntry {
+	entries := []QueueEntry{}
+	for idx, w := range gpusTrackerData.Queue {
+		entries = append(entries, QueueEntry{
+			Position:         idx + 1,
+			ContainerId:      w.ContainerId,
+			GPUs:             w.GPUs,
+			Pid:              w.Pid,
+			WaitingSeconds:   int64(now.Sub(w.EnqueuedAt).Seconds()),
+			RemainingSeconds: max(int64(w.Deadline.Sub(now).Seconds()), 0),
+		})
+	}
+	return entries
+}
+
+func (gpuTracker *gpu_tracker_t) ShowQueue() ([]QueueEntry, error) {
+	lock, err := acquireLock(gpuTracker.gpuTrackerLockFile, defaultLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	gpuTrackerInitialized, err := gpuTracker.isGPUTrackerInitialized()
+	if err != nil {
+		return nil, err
+	}
+
+	if !gpuTrackerInitialized {
+		if err := gpuTracker.initializeGPUTracker(); err != nil {
+			return nil, err
+		}
+	}
+
+	gpusTrackerData, err := gpuTracker.loadGPUTrackerData()
+	if err != nil {
+		return nil, err
+	}
+
+	return queueEntries(gpusTrackerData, gpuTracker.now()), nil
+}
+
+// ReserveGPUs reserves GPUs for a container. If the lease has a wait
+// timeout, the container waits in the queue while the GPUs are in use.
+func (gpuTracker *gpu_tracker_t) ReserveGPUs(gpus string, containerId string, lease Lease) ([]int, error) {
+	deadline := gpuTracker.now().Add(lease.WaitTimeout)
+	for {
+		gpuIds, queued, err := gpuTracker.tryReserveGPUs(gpus, containerId, lease, deadline)
+		if !queued {
+			return gpuIds, err
+		}
+		gpuTracker.sleep(waitPollInterval)
+	}
+}
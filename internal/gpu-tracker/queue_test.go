@@ -0,0 +1,179 @@
+package gpuTracker
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newQueueTestTracker returns a GPU Tracker on in-memory data, whose
+// clock advances when it sleeps. onSleep is called on every sleep.
+func newQueueTestTracker(data *gpu_tracker_data_t, now *time.Time, onSleep func()) *gpu_tracker_t {
+	return &gpu_tracker_t{
+		gpuTrackerLockFile:      "/tmp/gpu-tracker.lock",
+		isGPUTrackerInitialized: mockIsGPUTrackerInitialized,
+		initializeGPUTracker:    mockInitializeGPUTracker,
+		parseGPUsList:           mockParseGPUsList,
+		readGPUTrackerFile: func() (gpu_tracker_data_t, error) {
+			return *data, nil
+		},
+		writeGPUTrackerFile: func(written gpu_tracker_data_t) error {
+			*data = written
+			return nil
+		},
+		validateGPUsInfo: mockValidateGPUsInfo,
+		getGPUInventory:  mockGetGPUInventory,
+		getBootId:        mockGetBootId,
+		processExists:    func(int) bool { return true },
+		now:              func() time.Time { return *now },
+		sleep: func(d time.Duration) {
+			*now = now.Add(d)
+			onSleep()
+		},
+	}
+}
+
+func TestReserveGPUsWait(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	data, _ := mockReadGPUTrackerFile()
+
+	// container_1 releases the exclusive GPU 1 after 3 seconds
+	var queued []QueueEntry
+	sleeps := 0
+	var gpuTracker *gpu_tracker_t
+	gpuTracker = newQueueTestTracker(&data, &now, func() {
+		sleeps++
+		if sleeps == 1 {
+			queued, _ = gpuTracker.ShowQueue()
+		}
+		if sleeps == 3 {
+			Assert(t, gpuTracker.ReleaseGPUs("container_1") == nil, "ReleaseGPUs() failed")
+		}
+	})
+
+	gpuIds, err := gpuTracker.ReserveGPUs("1", "container_3", Lease{Pid: 42, WaitTimeout: time.Minute})
+	Assert(t, err == nil && slices.Equal(gpuIds, []int{1}), fmt.Sprintf("ReserveGPUs() = %v, %v", gpuIds, err))
+	Assert(t, sleeps == 3, fmt.Sprintf("ReserveGPUs() waited %d times instead of 3", sleeps))
+	Assert(t, len(queued) == 1 && queued[0].ContainerId == "container_3" && queued[0].Pid == 42 && slices.Equal(queued[0].GPUs, []int{1}) &&
+		queued[0].Position == 1 && queued[0].WaitingSeconds == 1 && queued[0].RemainingSeconds == 59, fmt.Sprintf("unexpected queue %+v", queued))
+	Assert(t, len(data.Queue) == 0, fmt.Sprintf("container_3 still in the queue %+v", data.Queue))
+	Assert(t, slices.Equal(data.GPUsStatus[1].ContainerIds, []string{"container_3"}), fmt.Sprintf("unexpected containers of GPU 1 %v", data.GPUsStatus[1].ContainerIds))
+
+	// Without a wait timeout, the reservation fails at once
+	_, err = gpuTracker.ReserveGPUs("1", "container_4", Lease{})
+	Assert(t, err != nil && sleeps == 3, fmt.Sprintf("ReserveGPUs() = %v after %d sleeps", err, sleeps))
+}
+
+func TestReserveGPUsWaitTimeout(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	data, _ := mockReadGPUTrackerFile()
+	sleeps := 0
+	gpuTracker := newQueueTestTracker(&data, &now, func() { sleeps++ })
+
+	_, err := gpuTracker.ReserveGPUs("0,1", "container_3", Lease{WaitTimeout: 5 * time.Second})
+	Assert(t, err != nil && strings.Contains(err.Error(), "timed out after 5s") && strings.Contains(err.Error(), "exclusive"),
+		fmt.Sprintf("unexpected error %v", err))
+	Assert(t, sleeps == 5, fmt.Sprintf("ReserveGPUs() waited %d times instead of 5", sleeps))
+	Assert(t, len(data.Queue) == 0, fmt.Sprintf("container_3 still in the queue %+v", data.Queue))
+	Assert(t, !slices.Contains(data.GPUsStatus[0].ContainerIds, "container_3"), "GPU 0 reserved after the timeout")
+
+	// With best effort, the available GPUs are reserved after the timeout
+	gpuIds, err := gpuTracker.ReserveGPUs("0,1", "container_3", Lease{Policy: BEST_EFFORT, WaitTimeout: 5 * time.Second})
+	Assert(t, err == nil && slices.Equal(gpuIds, []int{0}), fmt.Sprintf("ReserveGPUs() = %v, %v", gpuIds, err))
+}
+
+func TestReserveGPUsCountWait(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	data, _ := mockReadGPUTrackerFile()
+
+	// No GPU is free until container_1 releases them after 2 seconds
+	var queued []QueueEntry
+	sleeps := 0
+	var gpuTracker *gpu_tracker_t
+	gpuTracker = newQueueTestTracker(&data, &now, func() {
+		sleeps++
+		if sleeps == 1 {
+			queued, _ = gpuTracker.ShowQueue()
+		}
+		if sleeps == 2 {
+			Assert(t, gpuTracker.ReleaseGPUs("container_1") == nil, "ReleaseGPUs() failed")
+		}
+	})
+
+	gpuIds, err := gpuTracker.ReserveGPUs("count:1", "container_3", Lease{WaitTimeout: time.Minute})
+	Assert(t, err == nil && slices.Equal(gpuIds, []int{1}), fmt.Sprintf("ReserveGPUs() = %v, %v", gpuIds, err))
+	Assert(t, sleeps == 2, fmt.Sprintf("ReserveGPUs() waited %d times instead of 2", sleeps))
+	Assert(t, len(queued) == 1 && queued[0].ContainerId == "container_3" && slices.Equal(queued[0].GPUs, []int{0, 1}),
+		fmt.Sprintf("unexpected queue %+v", queued))
+	Assert(t, len(data.Queue) == 0, fmt.Sprintf("container_3 still in the queue %+v", data.Queue))
+
+	// A request of more GPUs than selected never waits
+	_, err = gpuTracker.ReserveGPUs("count:3", "container_4", Lease{WaitTimeout: time.Minute})
+	Assert(t, err != nil && sleeps == 2, fmt.Sprintf("ReserveGPUs() = %v after %d sleeps", err, sleeps))
+}
+
+func TestDropExpiredWaiters(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	data := gpu_tracker_data_t{Queue: []waiter_t{
+		{ContainerId: "container_1", GPUs: []int{0}, Pid: 41, EnqueuedAt: now, Deadline: now.Add(-time.Second)},
+		{ContainerId: "container_2", GPUs: []int{0}, Pid: 42, EnqueuedAt: now, Deadline: now.Add(time.Minute)},
+		{ContainerId: "container_3", GPUs: []int{0}, Pid: 43, EnqueuedAt: now, Deadline: now.Add(time.Minute)},
+		{ContainerId: "container_4", GPUs: []int{0}, EnqueuedAt: now, Deadline: now.Add(time.Minute)},
+	}}
+
+	// The runtime of container_2 has exited
+	dropExpiredWaiters(&data, now, func(pid int) bool { return pid != 42 })
+	var containerIds []string
+	for _, w := range data.Queue {
+		containerIds = append(containerIds, w.ContainerId)
+	}
+	Assert(t, slices.Equal(containerIds, []string{"container_3", "container_4"}), fmt.Sprintf("unexpected queue %v", containerIds))
+}
+
+func TestQueueFairness(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	data, _ := mockReadGPUTrackerFile()
+	// GPU 1 is free, but container_3 has been waiting for it
+	data.GPUsStatus[1] = gpu_status_t{UUID: "0x1234567890abcdef", Accessibility: exclusiveAccessInt, ContainerIds: []string{}}
+	data.Queue = []waiter_t{
+		{ContainerId: "container_3", GPUs: []int{0, 1}, EnqueuedAt: now.Add(-time.Second), Deadline: now.Add(time.Minute)},
+	}
+	gpuTracker := newQueueTestTracker(&data, &now, func() {})
+
+	_, err := gpuTracker.ReserveGPUs("1", "container_4", Lease{})
+	Assert(t, err != nil && strings.Contains(err.Error(), "awaited"), fmt.Sprintf("ReserveGPUs() did not leave GPU 1 to the queue: %v", err))
+
+	// The shared GPU 0 is not held back
+	_, err = gpuTracker.ReserveGPUs("0", "container_4", Lease{})
+	Assert(t, err == nil, fmt.Sprintf("ReserveGPUs() returned error %v", err))
+
+	_, err = gpuTracker.ReserveGPUs("count:1", "container_5", Lease{})
+	Assert(t, err != nil, "ReserveGPUs() picked the GPU awaited by the queue")
+
+	// A later waiter queues behind, then the first waiter gets the GPU
+	_, queued, err := gpuTracker.tryReserveGPUs("1", "container_5", Lease{WaitTimeout: time.Minute}, now.Add(time.Minute))
+	Assert(t, err == nil && queued, fmt.Sprintf("tryReserveGPUs() = %v, %v", queued, err))
+	Assert(t, len(data.Queue) == 2 && data.Queue[1].ContainerId == "container_5", fmt.Sprintf("unexpected queue %+v", data.Queue))
+
+	gpuIds, queued, err := gpuTracker.tryReserveGPUs("0,1", "container_3", Lease{WaitTimeout: time.Minute}, now.Add(time.Minute))
+	Assert(t, err == nil && !queued && slices.Equal(gpuIds, []int{0, 1}), fmt.Sprintf("tryReserveGPUs() = %v, %v, %v", gpuIds, queued, err))
+	Assert(t, len(data.Queue) == 1 && data.Queue[0].ContainerId == "container_5", fmt.Sprintf("unexpected queue %+v", data.Queue))
+
+	// A waiter past its deadline no longer holds back the GPUs
+	data.Queue[0].Deadline = now.Add(-time.Second)
+	Assert(t, gpuTracker.ReleaseGPUs("container_3") == nil, "ReleaseGPUs() failed")
+	_, err = gpuTracker.ReserveGPUs("1", "container_6", Lease{})
+	Assert(t, err == nil && len(data.Queue) == 0, fmt.Sprintf("ReserveGPUs() = %v with queue %+v", err, data.Queue))
+}
+
+func TestReleaseGPUsRemovesWaiter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	data, _ := mockReadGPUTrackerFile()
+	data.Queue = []waiter_t{{ContainerId: "container_3", GPUs: []int{1}, EnqueuedAt: now, Deadline: now.Add(time.Minute)}}
+	gpuTracker := newQueueTestTracker(&data, &now, func() {})
+
+	Assert(t, gpuTracker.ReleaseGPUs("container_3") == nil, "ReleaseGPUs() failed")
+	Assert(t, len(data.Queue) == 0, fmt.Sprintf("container_3 still in the queue %+v", data.Queue))
+}
@@ -0,0 +1,234 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package gpuTracker
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bootIdFile holds the random ID the kernel generates on every boot
+const bootIdFile = "/proc/sys/kernel/random/boot_id"
+
+// reconcileGracePeriod is how long a reservation is kept by Reconcile
+// before the state directory of its container must exist
+const reconcileGracePeriod = time.Minute
+
+// runtimeStateFiles are the files runc and crun keep in the state directory
+// of a container
+var runtimeStateFiles = []string{"state.json", "status"}
+
+func getBootId() (string, error) {
+	data, err := os.ReadFile(bootIdFile)
+	if err != nil {
+		return "", fmt.Errorf("reading boot ID: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// isStateDir reports whether a directory is the state directory of a
+// container
+func isStateDir(dir string) bool {
+	for _, f := range runtimeStateFiles {
+		if fi, err := os.Stat(filepath.Join(dir, f)); err == nil && fi.Mode().IsRegular() {
+			return true
+		}
+	}
+	return false
+}
+
+// containerExists reports whether a container still exists in the state
+// directories of the low-level runtimes. A container has a state directory
+// named after its ID in the root of its runtime, or in a namespace
+// directory of the root, e.g. /run/docker/runtime-runc/moby/<id>. It is an
+// error when none of the roots exist, since no container could then be
+// found.
+func containerExists(roots []string, containerId string) (bool, error) {
+	if containerId == "" || containerId != filepath.Base(containerId) || strings.HasPrefix(containerId, ".") {
+		return false, nil
+	}
+
+	rootFound := false
+	for _, root := range roots {
+		if _, err := os.Stat(root); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return false, fmt.Errorf("checking runtime root %v: %w", root, err)
+		}
+		rootFound = true
+
+		if isStateDir(filepath.Join(root, containerId)) {
+			return true, nil
+		}
+		matches, err := filepath.Glob(filepath.Join(root, "*", containerId))
+		if err != nil {
+			return false, fmt.Errorf("searching runtime root %v: %w", root, err)
+		}
+		if slices.ContainsFunc(matches, isStateDir) {
+			return true, nil
+		}
+	}
+
+	if !rootFound {
+		return false, fmt.Errorf("none of the runtime roots %v exist", roots)
+	}
+	return false, nil
+}
+
+// bundleExists reports whether the bundle directory of a container exists.
+// The container engines remove the bundle when the container is deleted.
+func bundleExists(bundle string) bool {
+	fi, err := os.Stat(bundle)
+	return err == nil && fi.IsDir()
+}
+
+// releaseContainers removes the containers for which keep returns false
+// from the GPUs status, along with their leases, and returns the removed
+// containers
+func releaseContainers(gpusTrackerData *gpu_tracker_data_t, keep func(string) bool) []string {
+	released := []string{}
+	for gpuId, status := range gpusTrackerData.GPUsStatus {
+		containerIds := []string{}
+		for _, id := range status.ContainerIds {
+			if keep(id) {
+				containerIds = append(containerIds, id)
+			} else if !slices.Contains(released, id) {
+				released = append(released, id)
+			}
+		}
+		status.ContainerIds = containerIds
+		gpusTrackerData.GPUsStatus[gpuId] = status
+	}
+	for _, id := range released {
+		delete(gpusTrackerData.Leases, id)
+	}
+	sort.Strings(released)
+	return released
+}
+
+// loadGPUTrackerData reads the GPU Tracker file. On the first read after a
+// reboot, it releases the GPUs of the containers of the previous boot,
+// which cannot be running anymore.
+func (gpuTracker *gpu_tracker_t) loadGPUTrackerData() (gpu_tracker_data_t, error) {
+	gpusTrackerData, err := gpuTracker.readGPUTrackerFile()
+	if err != nil {
+		return gpusTrackerData, err
+	}
+
+	bootId, err := gpuTracker.getBootId()
+	if err != nil {
+		slog.Warn("Failed to get the boot ID, not checking for stale reservations", "error", err)
+		return gpusTrackerData, nil
+	}
+	if bootId == gpusTrackerData.BootId {
+		return gpusTrackerData, nil
+	}
+
+	// Reservations of files written before the boot ID was recorded are
+	// kept, as there is no telling when they were made
+	if gpusTrackerData.BootId != "" {
+		released := releaseContainers(&gpusTrackerData, func(string) bool { return false })
+		if len(released) > 0 {
+			slog.Info("Released GPUs used by containers of a previous boot", "containers", released)
+		}
+	}
+	gpusTrackerData.BootId = bootId
+
+	return gpusTrackerData, gpuTracker.writeGPUTrackerFile(gpusTrackerData)
+}
+
+// Reconcile releases the GPUs of the containers that no longer exist. A
+// container without a state directory in the runtime roots no longer
+// exists when its bundle directory is gone too. Containers without a
+// bundle, or whose bundle still exists, may be run by a runtime whose root
+// is not configured, and are only released with releaseUnknown.
+func (gpuTracker *gpu_tracker_t) Reconcile(releaseUnknown bool) ([]string, []string, error) {
+	lock, err := acquireLock(gpuTracker.gpuTrackerLockFile, defaultLockTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer lock.Unlock()
+
+	gpuTrackerInitialized, err := gpuTracker.isGPUTrackerInitialized()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !gpuTrackerInitialized {
+		return []string{}, []string{}, nil
+	}
+
+	gpusTrackerData, err := gpuTracker.loadGPUTrackerData()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := gpuTracker.now()
+	expired := releaseExpired(&gpusTrackerData, now)
+
+	// Check every container before releasing any, so that an error leaves
+	// the reservations as they are. The runtime reserves the GPUs before
+	// the low-level runtime creates the state directory, so recent
+	// reservations are kept.
+	exists := map[string]bool{}
+	unknown := []string{}
+	for _, status := range gpusTrackerData.GPUsStatus {
+		for _, id := range status.ContainerIds {
+			if _, checked := exists[id]; checked {
+				continue
+			}
+			lease := gpusTrackerData.Leases[id]
+			if !lease.CreatedAt.IsZero() && now.Sub(lease.CreatedAt) < reconcileGracePeriod {
+				exists[id] = true
+				continue
+			}
+			if exists[id], err = gpuTracker.containerExists(id); err != nil {
+				return nil, nil, fmt.Errorf("checking container %v: %w", id, err)
+			}
+			if !exists[id] && (lease.Bundle == "" || gpuTracker.bundleExists(lease.Bundle)) {
+				unknown = append(unknown, id)
+				exists[id] = !releaseUnknown
+			}
+		}
+	}
+	sort.Strings(unknown)
+
+	removed := releaseContainers(&gpusTrackerData, func(id string) bool { return exists[id] })
+	if len(removed) > 0 {
+		slog.Info("Released GPUs used by containers that no longer exist", "containers", removed)
+	}
+	if len(unknown) > 0 && !releaseUnknown {
+		slog.Warn("Kept GPUs used by containers not found in the runtime roots", "containers", unknown)
+	}
+
+	released := append(expired, removed...)
+	if len(released) == 0 {
+		return released, unknown, nil
+	}
+	sort.Strings(released)
+
+	if err := gpuTracker.writeGPUTrackerFile(gpusTrackerData); err != nil {
+		return nil, nil, err
+	}
+	return released, unknown, nil
+}
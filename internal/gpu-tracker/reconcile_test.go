@@ -0,0 +1,162 @@
+package gpuTracker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestContainerExists(t *testing.T) {
+	dir := t.TempDir()
+	runc := filepath.Join(dir, "runc")
+	docker := filepath.Join(dir, "docker", "runtime-runc")
+	states := map[string]string{
+		filepath.Join(runc, "container_1"):           "state.json",
+		filepath.Join(docker, "moby", "container_2"): "state.json",
+		filepath.Join(docker, "moby", "container_4"): "",
+	}
+	for d, f := range states {
+		Assert(t, os.MkdirAll(d, 0755) == nil, fmt.Sprintf("failed to create %v", d))
+		if f != "" {
+			Assert(t, os.WriteFile(filepath.Join(d, f), []byte("{}"), 0644) == nil, fmt.Sprintf("failed to write the state of %v", d))
+		}
+	}
+	roots := []string{runc, docker, filepath.Join(dir, "crun")}
+
+	tests := []struct {
+		containerId string
+		exists      bool
+	}{
+		{"container_1", true},
+		{"container_2", true},
+		{"container_3", false},
+		{"container_4", false},
+		{"moby", false},
+		{"../runc", false},
+		{"", false},
+	}
+	for _, tc := range tests {
+		exists, err := containerExists(roots, tc.containerId)
+		Assert(t, err == nil, fmt.Sprintf("containerExists(%q) returned error %v", tc.containerId, err))
+		Assert(t, exists == tc.exists, fmt.Sprintf("containerExists(%q) = %v, expected %v", tc.containerId, exists, tc.exists))
+	}
+
+	_, err := containerExists([]string{filepath.Join(dir, "missing")}, "container_1")
+	Assert(t, err != nil, "containerExists() did not return error without any runtime root")
+}
+
+func TestReconcile(t *testing.T) {
+	var written *gpu_tracker_data_t
+	gpuTracker := &gpu_tracker_t{
+		gpuTrackerLockFile:      filepath.Join(t.TempDir(), "gpu-tracker.lock"),
+		isGPUTrackerInitialized: mockIsGPUTrackerInitialized,
+		readGPUTrackerFile:      mockReadGPUTrackerFile,
+		writeGPUTrackerFile: func(data gpu_tracker_data_t) error {
+			written = &data
+			return nil
+		},
+		getBootId: mockGetBootId,
+		now:       time.Now,
+		containerExists: func(containerId string) (bool, error) {
+			return containerId == "container_2", nil
+		},
+		bundleExists: func(string) bool { return false },
+	}
+
+	// container_1 has no bundle telling that it was removed
+	released, unknown, err := gpuTracker.Reconcile(false)
+	Assert(t, err == nil, fmt.Sprintf("Reconcile() returned error %v", err))
+	Assert(t, len(released) == 0 && slices.Equal(unknown, []string{"container_1"}), fmt.Sprintf("unexpected released containers %v, unknown %v", released, unknown))
+	Assert(t, written == nil, "Reconcile() wrote the GPU Tracker file without releasing any GPU")
+
+	released, unknown, err = gpuTracker.Reconcile(true)
+	Assert(t, err == nil, fmt.Sprintf("Reconcile() returned error %v", err))
+	Assert(t, slices.Equal(released, []string{"container_1"}) && slices.Equal(unknown, []string{"container_1"}), fmt.Sprintf("unexpected released containers %v, unknown %v", released, unknown))
+	Assert(t, written != nil, "Reconcile() did not write the GPU Tracker file")
+	Assert(t, slices.Equal(written.GPUsStatus[0].ContainerIds, []string{"container_2"}), fmt.Sprintf("unexpected containers of GPU 0 %v", written.GPUsStatus[0].ContainerIds))
+	Assert(t, len(written.GPUsStatus[1].ContainerIds) == 0, fmt.Sprintf("unexpected containers of GPU 1 %v", written.GPUsStatus[1].ContainerIds))
+
+	// Nothing is released when a container cannot be checked
+	written = nil
+	gpuTracker.containerExists = func(string) (bool, error) { return false, fmt.Errorf("no runtime root") }
+	_, _, err = gpuTracker.Reconcile(true)
+	Assert(t, err != nil, "Reconcile() did not return error when containers cannot be checked")
+	Assert(t, written == nil, "Reconcile() wrote the GPU Tracker file on error")
+}
+
+func TestLoadAfterReboot(t *testing.T) {
+	var written *gpu_tracker_data_t
+	gpuTracker := &gpu_tracker_t{
+		readGPUTrackerFile: mockReadGPUTrackerFile,
+		writeGPUTrackerFile: func(data gpu_tracker_data_t) error {
+			written = &data
+			return nil
+		},
+		getBootId: mockGetBootId,
+	}
+
+	_, err := gpuTracker.loadGPUTrackerData()
+	Assert(t, err == nil && written == nil, fmt.Sprintf("the data of the current boot should be read as is, err %v", err))
+
+	gpuTracker.getBootId = func() (string, error) { return "boot-2", nil }
+	data, err := gpuTracker.loadGPUTrackerData()
+	Assert(t, err == nil, fmt.Sprintf("loadGPUTrackerData() returned error %v", err))
+	Assert(t, written != nil && written.BootId == "boot-2", "the new boot ID was not written")
+	for gpuId, status := range data.GPUsStatus {
+		Assert(t, len(status.ContainerIds) == 0, fmt.Sprintf("GPU %v still reserved for %v after a reboot", gpuId, status.ContainerIds))
+	}
+
+	// Files without a boot ID keep their reservations
+	gpuTracker.readGPUTrackerFile = func() (gpu_tracker_data_t, error) {
+		data, err := mockReadGPUTrackerFile()
+		data.BootId = ""
+		return data, err
+	}
+	data, err = gpuTracker.loadGPUTrackerData()
+	Assert(t, err == nil && data.BootId == "boot-2", fmt.Sprintf("unexpected boot ID %v, err %v", data.BootId, err))
+	Assert(t, len(data.GPUsStatus[0].ContainerIds) == 2, fmt.Sprintf("unexpected containers of GPU 0 %v", data.GPUsStatus[0].ContainerIds))
+}
+
+func TestReconcileLeases(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	data, _ := mockReadGPUTrackerFile()
+	// container_1 was just created and has no state directory yet,
+	// container_2 is running past the TTL of its lease
+	data.Leases["container_1"] = lease_t{CreatedAt: now.Add(-10 * time.Second), Bundle: "/run/bundles/container_1"}
+	data.Leases["container_2"] = lease_t{CreatedAt: now.Add(-2 * time.Hour), TTLSeconds: 3600}
+
+	gpuTracker := &gpu_tracker_t{
+		gpuTrackerLockFile:      filepath.Join(t.TempDir(), "gpu-tracker.lock"),
+		isGPUTrackerInitialized: mockIsGPUTrackerInitialized,
+		readGPUTrackerFile:      func() (gpu_tracker_data_t, error) { return data, nil },
+		writeGPUTrackerFile: func(d gpu_tracker_data_t) error {
+			data = d
+			return nil
+		},
+		getBootId:       mockGetBootId,
+		now:             func() time.Time { return now },
+		containerExists: func(containerId string) (bool, error) { return containerId == "container_2", nil },
+		bundleExists:    func(string) bool { return false },
+	}
+
+	released, _, err := gpuTracker.Reconcile(false)
+	Assert(t, err == nil, fmt.Sprintf("Reconcile() returned error %v", err))
+	Assert(t, slices.Equal(released, []string{"container_2"}), fmt.Sprintf("unexpected released containers %v", released))
+	Assert(t, slices.Equal(data.GPUsStatus[1].ContainerIds, []string{"container_1"}), fmt.Sprintf("unexpected containers of GPU 1 %v", data.GPUsStatus[1].ContainerIds))
+
+	// Past the grace period, the missing state directory and bundle
+	// release the GPUs
+	now = now.Add(reconcileGracePeriod)
+	gpuTracker.bundleExists = func(string) bool { return true }
+	released, unknown, err := gpuTracker.Reconcile(false)
+	Assert(t, err == nil && len(released) == 0 && slices.Equal(unknown, []string{"container_1"}),
+		fmt.Sprintf("a container with a bundle should be kept, released %v, unknown %v, err %v", released, unknown, err))
+
+	gpuTracker.bundleExists = func(string) bool { return false }
+	released, unknown, err = gpuTracker.Reconcile(false)
+	Assert(t, err == nil && slices.Equal(released, []string{"container_1"}) && len(unknown) == 0,
+		fmt.Sprintf("unexpected released containers %v, unknown %v, err %v", released, unknown, err))
+}
@@ -0,0 +1,548 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package hook
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ROCm/container-toolkit/internal/amdgpu"
+	"github.com/ROCm/container-toolkit/internal/config"
+	gpuTracker "github.com/ROCm/container-toolkit/internal/gpu-tracker"
+	"github.com/ROCm/container-toolkit/internal/rocm"
+	"github.com/ROCm/container-toolkit/internal/rootless"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// Constants
+const (
+	// ld.so.conf.d drop-in written into the container for the mounted libraries
+	ldConfFile = "/etc/ld.so.conf.d/amd-container-runtime.conf"
+
+	// Default mount point of the cgroup v1 devices controller
+	DEFAULT_CGROUP_DEVICES_ROOT = "/sys/fs/cgroup/devices"
+
+	// Argument passed to the hook by the OCI hooks descriptor of the
+	// container engine, as opposed to the hook added by the runtime
+	ENGINE_HOOK_ARG = "--engine-hook"
+)
+
+// Interface for hook package
+type Interface interface {
+	// Run performs the GPU setup (or teardown) for the container
+	Run() error
+}
+
+// GetGPUs is the type for functions that return the lists of all the GPU devices on the system
+type GetGPUs func() ([]amdgpu.DeviceInfo, error)
+
+// GetGPU is the type for functions that return the device information for the given GPU
+type GetGPU func(string) (amdgpu.AMDGPU, error)
+
+// ReserveGPUs is the type for functions that return a list of reserved GPUs
+type ReserveGPUs func(string, string, gpuTracker.Lease) ([]int, error)
+
+// ReleaseGPUs is the type for functions that release the GPUs held by a container
+type ReleaseGPUs func(string) error
+
+// Mknod is the type for functions that create a device node
+type Mknod func(path string, mode uint32, dev uint64) error
+
+// NsExec is the type for functions that run a command inside the
+// mount namespace of the given process
+type NsExec func(pid int, args ...string) error
+
+// hook_t implements the hook interface
+type hook_t struct {
+	// state is the container state passed by the runtime on stdin
+	state specs.State
+
+	// spec is the OCI spec read from the container bundle
+	spec *specs.Spec
+
+	// rootfs is the container root filesystem as seen from the container's
+	// mount namespace, before pivot_root
+	rootfs string
+
+	// hostRootfs is the container root filesystem as reachable from the
+	// hook's mount namespace, through /proc/<pid>/root
+	hostRootfs string
+
+	// procRoot is the procfs mount point
+	procRoot string
+
+	// cgroupDevicesRoot is the mount point of the cgroup v1 devices controller
+	cgroupDevicesRoot string
+
+	// rocm discovers the host ROCm files mounted into the container
+	rocm rocm.Interface
+
+	// getGPUs is the function that returns the list of GPUs in the system
+	getGPUs GetGPUs
+
+	// getGPU is the function that returns the device info of the given GPU
+	getGPU GetGPU
+
+	// reserveGPUs is the function that returns a list of reserved GPUs
+	reserveGPUs ReserveGPUs
+
+	// releaseGPUs is the function that releases the GPUs of a container
+	releaseGPUs ReleaseGPUs
+
+	// mknod is the function that creates device nodes
+	mknod Mknod
+
+	// nsExec is the function that runs commands in the container's mount namespace
+	nsExec NsExec
+
+	// rootless specifies if the hook runs without the privileges of the
+	// host root user, which cannot add devices cgroup rules
+	rootless bool
+
+	// canAccess reports whether the user can read and write a device node
+	canAccess func(string) bool
+
+	// engineHook specifies if the hook is run by the container engine
+	// from its OCI hooks descriptor, for every container
+	engineHook bool
+}
+
+func mknod(path string, mode uint32, dev uint64) error {
+	return unix.Mknod(path, mode, int(dev))
+}
+
+func nsExec(pid int, args ...string) error {
+	nsArgs := append([]string{"--target", strconv.Itoa(pid), "--mount", "--"}, args...)
+	out, err := exec.Command("nsenter", nsArgs...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("running %v in mount namespace of %d: %w: %s", args, pid, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// readState decodes the container state passed by the runtime
+func (h *hook_t) readState(r io.Reader) error {
+	if err := json.NewDecoder(r).Decode(&h.state); err != nil {
+		return fmt.Errorf("decoding container state: %w", err)
+	}
+
+	if h.state.Bundle == "" {
+		return fmt.Errorf("container state has no bundle path")
+	}
+
+	return nil
+}
+
+// getSpec reads the OCI spec from the container bundle
+func (h *hook_t) getSpec() error {
+	f := filepath.Join(h.state.Bundle, "config.json")
+
+	file, err := os.Open(f)
+	if err != nil {
+		return fmt.Errorf("opening OCI spec %s: %w", f, err)
+	}
+	defer file.Close()
+
+	var spec specs.Spec
+	if err := json.NewDecoder(file).Decode(&spec); err != nil {
+		return fmt.Errorf("decoding OCI spec %s: %w", f, err)
+	}
+	h.spec = &spec
+
+	if spec.Root == nil || spec.Root.Path == "" {
+		return fmt.Errorf("OCI spec %s has no root path", f)
+	}
+
+	h.rootfs = spec.Root.Path
+	if !filepath.IsAbs(h.rootfs) {
+		h.rootfs = filepath.Join(h.state.Bundle, h.rootfs)
+	}
+	h.hostRootfs = filepath.Join(h.procRoot, strconv.Itoa(h.state.Pid), "root", h.rootfs)
+
+	return nil
+}
+
+// getAMDEnv returns the value of "AMD_VISIBLE_DEVICES" or "DOCKER_RESOURCE_*"
+// environment variables in the spec
+func (h *hook_t) getAMDEnv() string {
+	if h.spec == nil || h.spec.Process == nil {
+		return ""
+	}
+
+	for _, env := range h.spec.Process.Env {
+		pts := strings.SplitN(env, "=", 2)
+		if len(pts) == 2 && (pts[0] == "AMD_VISIBLE_DEVICES" || strings.HasPrefix(pts[0], "DOCKER_RESOURCE_")) {
+			return pts[1]
+		}
+	}
+
+	return ""
+}
+
+// bindDeviceNode bind mounts the host device node of the GPU into the
+// container, as a rootless hook is not allowed to create device nodes
+func (h *hook_t) bindDeviceNode(gpu amdgpu.AMDGPU, path string) error {
+	if h.canAccess != nil && !h.canAccess(gpu.Path) {
+		return fmt.Errorf("GPU access is impossible for this user: device %s is not accessible, "+
+			"add the user to the group owning the device, usually render or video", gpu.Path)
+	}
+
+	if err := os.WriteFile(path, []byte{}, 0600); err != nil {
+		return fmt.Errorf("creating mount point %s: %w", gpu.Path, err)
+	}
+	if err := h.nsExec(h.state.Pid, "mount", "--bind", gpu.Path, filepath.Join(h.rootfs, gpu.Path)); err != nil {
+		return fmt.Errorf("bind mounting device node %s, GPU access is impossible for this user: %w", gpu.Path, err)
+	}
+
+	slog.Debug("Bind mounted device node in container", "device", gpu.Path)
+	return nil
+}
+
+// createDeviceNode creates the device node of the GPU in the container
+func (h *hook_t) createDeviceNode(gpu amdgpu.AMDGPU) error {
+	path := filepath.Join(h.hostRootfs, gpu.Path)
+	if _, err := os.Lstat(path); err == nil {
+		slog.Debug("Device node already present in container", "device", gpu.Path)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", gpu.Path, err)
+	}
+
+	if h.rootless {
+		return h.bindDeviceNode(gpu, path)
+	}
+
+	dev := unix.Mkdev(uint32(gpu.Major), uint32(gpu.Minor))
+	if err := h.mknod(path, unix.S_IFCHR|uint32(gpu.FileMode.Perm()), dev); err != nil {
+		return fmt.Errorf("creating device node %s: %w", gpu.Path, err)
+	}
+	if err := os.Chown(path, int(gpu.Uid), int(gpu.Gid)); err != nil {
+		return fmt.Errorf("changing owner of %s: %w", gpu.Path, err)
+	}
+
+	slog.Debug("Created device node in container", "device", gpu.Path)
+	return nil
+}
+
+// getDevicesCgroup returns the cgroup v1 devices controller directory of
+// the container, or an empty string on a unified (v2) hierarchy
+func (h *hook_t) getDevicesCgroup() (string, error) {
+	f := filepath.Join(h.procRoot, strconv.Itoa(h.state.Pid), "cgroup")
+	file, err := os.Open(f)
+	if err != nil {
+		return "", fmt.Errorf("opening %s: %w", f, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Each line is <hierarchy-id>:<controllers>:<path>
+		pts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(pts) != 3 {
+			continue
+		}
+		for _, ctrl := range strings.Split(pts[1], ",") {
+			if ctrl == "devices" {
+				return filepath.Join(h.cgroupDevicesRoot, pts[2]), nil
+			}
+		}
+	}
+
+	return "", scanner.Err()
+}
+
+// specAllowsDevice returns true if the devices cgroup rules of the OCI
+// spec allow the GPU, the last matching rule taking precedence
+func (h *hook_t) specAllowsDevice(gpu amdgpu.AMDGPU) bool {
+	if h.spec.Linux == nil || h.spec.Linux.Resources == nil {
+		return false
+	}
+
+	allowed := false
+	for _, rule := range h.spec.Linux.Resources.Devices {
+		if rule.Type != "" && rule.Type != "a" && rule.Type != gpu.DevType {
+			continue
+		}
+		if (rule.Major != nil && *rule.Major != gpu.Major) || (rule.Minor != nil && *rule.Minor != gpu.Minor) {
+			continue
+		}
+		allowed = rule.Allow
+	}
+
+	return allowed
+}
+
+// allowDevices adds device cgroup rules for the GPUs. On a unified (v2)
+// hierarchy the low-level runtime filters the devices with the rules of
+// the OCI spec, which the hook cannot change, so the spec must already
+// allow the GPUs.
+func (h *hook_t) allowDevices(gpus []amdgpu.AMDGPU) error {
+	cgroup, err := h.getDevicesCgroup()
+	if err != nil {
+		return err
+	}
+
+	if cgroup == "" {
+		for _, gpu := range gpus {
+			if !h.specAllowsDevice(gpu) {
+				return fmt.Errorf("device %s is not allowed by the OCI spec, and cgroup v2 device rules cannot be added from a hook: "+
+					"use amd-container-runtime in the hook mode, or allow the device when creating the container", gpu.Path)
+			}
+		}
+		slog.Debug("GPU devices allowed by the OCI spec", "container", h.state.ID)
+		return nil
+	}
+
+	allow := filepath.Join(cgroup, "devices.allow")
+	file, err := os.OpenFile(allow, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", allow, err)
+	}
+	defer file.Close()
+
+	// The devices controller accepts a single rule per write
+	for _, gpu := range gpus {
+		rule := fmt.Sprintf("%s %d:%d %s", gpu.DevType, gpu.Major, gpu.Minor, gpu.Access)
+		if _, err := file.WriteString(rule); err != nil {
+			return fmt.Errorf("writing %q to %s: %w", rule, allow, err)
+		}
+	}
+
+	return nil
+}
+
+// mountROCmLibs bind mounts the host ROCm libraries and tools requested
+// through AMD_DRIVER_CAPABILITIES into the container
+func (h *hook_t) mountROCmLibs() error {
+	env := []string{}
+	if h.spec.Process != nil {
+		env = h.spec.Process.Env
+	}
+
+	caps, err := rocm.GetCapabilities(env)
+	if err != nil {
+		return err
+	}
+	if caps.IsEmpty() {
+		return nil
+	}
+
+	files, err := h.rocm.Discover(caps)
+	if err != nil {
+		return fmt.Errorf("discovering ROCm files: %w", err)
+	}
+	if len(files.All()) == 0 {
+		slog.Warn("Host ROCm files not found", "capabilities", caps.String())
+		return nil
+	}
+
+	for _, f := range files.All() {
+		target := filepath.Join(h.hostRootfs, f)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("creating directory for mount point %s: %w", f, err)
+		}
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			if err := os.WriteFile(target, []byte{}, 0644); err != nil {
+				return fmt.Errorf("creating mount point %s: %w", f, err)
+			}
+		}
+
+		dst := filepath.Join(h.rootfs, f)
+		if err := h.nsExec(h.state.Pid, "mount", "--bind", f, dst); err != nil {
+			return err
+		}
+		if err := h.nsExec(h.state.Pid, "mount", "-o", "remount,bind,ro", dst); err != nil {
+			return err
+		}
+	}
+	slog.Debug("Mounted ROCm files in container", "capabilities", caps.String(), "files", files.All())
+
+	if len(files.Libraries) == 0 {
+		return nil
+	}
+
+	confPath := filepath.Join(h.hostRootfs, ldConfFile)
+	if err := os.MkdirAll(filepath.Dir(confPath), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", ldConfFile, err)
+	}
+	if err := os.WriteFile(confPath, []byte(strings.Join(files.LibDirs(), "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", ldConfFile, err)
+	}
+
+	return h.nsExec(h.state.Pid, "ldconfig", "-r", h.rootfs)
+}
+
+// setupGPUs reserves the requested GPUs and makes them available in the container
+func (h *hook_t) setupGPUs() error {
+	env := h.getAMDEnv()
+	if env == "" {
+		slog.Debug("No GPUs requested for container", "container", h.state.ID)
+		return nil
+	}
+
+	lease, err := gpuTracker.LeaseFromEnv(h.spec.Process.Env)
+	if err != nil {
+		return err
+	}
+	lease.Pid = os.Getpid()
+	lease.Bundle = h.state.Bundle
+
+	gpuIds, err := h.reserveGPUs(env, h.state.ID, lease)
+	if err != nil {
+		return err
+	}
+	if len(gpuIds) == 0 {
+		slog.Debug("No GPUs to be added to container", "container", h.state.ID)
+		return nil
+	}
+
+	devs, err := h.getGPUs()
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Requested GPUs for container", "gpu_indices", gpuIds)
+
+	paths := []string{}
+	for _, idx := range gpuIds {
+		if idx < 0 || idx >= len(devs) {
+			return fmt.Errorf("GPU %d not found in the GPU list", idx)
+		}
+		paths = append(paths, devs[idx].DrmDevices...)
+	}
+	paths = append(paths, "/dev/kfd")
+
+	gpus := []amdgpu.AMDGPU{}
+	for _, path := range paths {
+		gpu, err := h.getGPU(path)
+		if err != nil {
+			return err
+		}
+		if err := h.createDeviceNode(gpu); err != nil {
+			return err
+		}
+		gpus = append(gpus, gpu)
+	}
+
+	if h.rootless {
+		slog.Debug("Running rootless, skipping the devices cgroup rules", "container", h.state.ID)
+	} else if err := h.allowDevices(gpus); err != nil {
+		return err
+	}
+
+	return h.mountROCmLibs()
+}
+
+// handledByRuntime returns true if amd-container-runtime set up the GPUs
+// of the container, or added its own hook for it
+func (h *hook_t) handledByRuntime() bool {
+	if h.spec.Hooks == nil {
+		return false
+	}
+
+	for _, hook := range h.spec.Hooks.CreateRuntime {
+		if filepath.Base(hook.Path) == "amd-container-runtime-hook" && !slices.Contains(hook.Args, ENGINE_HOOK_ARG) {
+			return true
+		}
+	}
+	for _, hook := range h.spec.Hooks.Poststop {
+		if slices.Contains(hook.Args, "gpu-tracker") && slices.Contains(hook.Args, "release") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Run performs the GPU setup for the container, or releases its GPUs
+// once the container has stopped
+func (h *hook_t) Run() error {
+	if h.state.Status == specs.StateStopped {
+		// The bundle may be gone already, the GPUs are then released
+		// in any case
+		if err := h.getSpec(); err == nil && (h.getAMDEnv() == "" || (h.engineHook && h.handledByRuntime())) {
+			slog.Debug("No GPUs to be released for container", "container", h.state.ID)
+			return nil
+		}
+		return h.releaseGPUs(h.state.ID)
+	}
+
+	if err := h.getSpec(); err != nil {
+		return err
+	}
+
+	if h.engineHook && h.handledByRuntime() {
+		slog.Debug("GPUs set up by amd-container-runtime, skipping the engine hook", "container", h.state.ID)
+		return nil
+	}
+
+	if err := h.setupGPUs(); err != nil {
+		if e := h.releaseGPUs(h.state.ID); e != nil {
+			slog.Error("Failed to release GPUs", "container", h.state.ID, "error", e)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// New creates a hook instance from the container state read from r and
+// the arguments of the hook
+func New(r io.Reader, args []string) (Interface, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	gpuTracker, err := gpuTracker.New()
+	if err != nil {
+		return nil, err
+	}
+
+	h := &hook_t{
+		procRoot:          "/proc",
+		cgroupDevicesRoot: DEFAULT_CGROUP_DEVICES_ROOT,
+		rocm:              rocm.New(cfg.ROCm.Path),
+		getGPUs:           amdgpu.GetAMDGPUs,
+		getGPU:            amdgpu.GetAMDGPU,
+		reserveGPUs:       gpuTracker.ReserveGPUs,
+		releaseGPUs:       gpuTracker.ReleaseGPUs,
+		mknod:             mknod,
+		nsExec:            nsExec,
+		rootless:          rootless.IsRootless(),
+		canAccess:         rootless.CanAccess,
+		engineHook:        slices.Contains(args, ENGINE_HOOK_ARG),
+	}
+
+	if err := h.readState(r); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
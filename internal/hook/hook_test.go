@@ -0,0 +1,396 @@
+package hook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ROCm/container-toolkit/internal/amdgpu"
+	gpuTracker "github.com/ROCm/container-toolkit/internal/gpu-tracker"
+	"github.com/ROCm/container-toolkit/internal/rocm"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+const (
+	// Container init PID used in the tests
+	TEST_PID = 4242
+
+	// Container ID used in the tests
+	TEST_CONTAINER_ID = "f936e9ab998d8dd8000f9f61180754ae669ac89aa594d195ec8a5ef16e1a9919"
+)
+
+func mockGetAMDGPUs() ([]amdgpu.DeviceInfo, error) {
+	ret := []amdgpu.DeviceInfo{
+		{
+			DrmDevices: []string{
+				"/dev/dri/renderD128",
+				"/dev/dri/card1",
+			},
+			PartitionType: "",
+		},
+		{
+			DrmDevices: []string{
+				"/dev/dri/renderD129",
+				"/dev/dri/card2",
+			},
+			PartitionType: "",
+		},
+	}
+
+	return ret, nil
+}
+
+func mockGetAMDGPU(dev string) (amdgpu.AMDGPU, error) {
+	gpu := amdgpu.AMDGPU{
+		Path:     dev,
+		Major:    226,
+		Minor:    1,
+		FileMode: 432,
+		Gid:      uint32(os.Getgid()),
+		Uid:      uint32(os.Getuid()),
+		Allow:    true,
+		DevType:  "c",
+		Access:   "rwm",
+	}
+
+	return gpu, nil
+}
+
+func mockReserveGPUs(gpus string, containerId string, lease gpuTracker.Lease) ([]int, error) {
+	ret := []int{}
+	for _, c := range strings.Split(gpus, ",") {
+		i, err := strconv.Atoi(c)
+		if err != nil {
+			return []int{}, err
+		}
+		ret = append(ret, i)
+	}
+	return ret, nil
+}
+
+// mockHost holds the state recorded by the mocked host operations
+type mockHost struct {
+	nodes    []string
+	cmds     [][]string
+	released []string
+}
+
+func (m *mockHost) mknod(path string, mode uint32, dev uint64) error {
+	m.nodes = append(m.nodes, path)
+	return os.WriteFile(path, []byte{}, 0600)
+}
+
+func (m *mockHost) nsExec(pid int, args ...string) error {
+	m.cmds = append(m.cmds, args)
+	return nil
+}
+
+func (m *mockHost) releaseGPUs(containerId string) error {
+	m.released = append(m.released, containerId)
+	return nil
+}
+
+// setupBundle creates a bundle with the given env, along with a fake procfs
+// entry for the container init process
+func setupBundle(t *testing.T, env []string, cgroup string) *hook_t {
+	tmpDir := t.TempDir()
+	bundle := filepath.Join(tmpDir, "bundle")
+	procRoot := filepath.Join(tmpDir, "proc")
+
+	testSpec := fmt.Sprintf(`{
+		"root": {"path": "rootfs"},
+		"process": {"env": [%s]}
+	}`, `"`+strings.Join(env, `","`)+`"`)
+
+	err := os.MkdirAll(bundle, 0755)
+	Assert(t, err == nil, fmt.Sprintf("failed to create bundle, Err: %v", err))
+	err = os.WriteFile(filepath.Join(bundle, "config.json"), []byte(testSpec), 0644)
+	Assert(t, err == nil, fmt.Sprintf("failed to write test spec, Err: %v", err))
+
+	pidDir := filepath.Join(procRoot, strconv.Itoa(TEST_PID))
+	err = os.MkdirAll(filepath.Join(pidDir, "root", bundle, "rootfs"), 0755)
+	Assert(t, err == nil, fmt.Sprintf("failed to create rootfs, Err: %v", err))
+	err = os.WriteFile(filepath.Join(pidDir, "cgroup"), []byte(cgroup), 0644)
+	Assert(t, err == nil, fmt.Sprintf("failed to write cgroup file, Err: %v", err))
+
+	return &hook_t{
+		state: specs.State{
+			ID:     TEST_CONTAINER_ID,
+			Status: specs.StateCreating,
+			Pid:    TEST_PID,
+			Bundle: bundle,
+		},
+		procRoot:          procRoot,
+		cgroupDevicesRoot: filepath.Join(tmpDir, "cgroup", "devices"),
+		rocm:              rocm.NewWithRoot(filepath.Join(tmpDir, "host"), "/opt/rocm"),
+		getGPUs:           mockGetAMDGPUs,
+		getGPU:            mockGetAMDGPU,
+		reserveGPUs:       mockReserveGPUs,
+	}
+}
+
+func TestReadState(t *testing.T) {
+	h := &hook_t{}
+	err := h.readState(strings.NewReader(`{"ociVersion": "1.2.0", "id": "abc", "status": "creating", "pid": 42, "bundle": "/run/bundle"}`))
+	Assert(t, err == nil, fmt.Sprintf("readState returned error %v", err))
+	Assert(t, h.state.ID == "abc", fmt.Sprintf("expected id abc, got %v", h.state.ID))
+	Assert(t, h.state.Pid == 42, fmt.Sprintf("expected pid 42, got %v", h.state.Pid))
+	Assert(t, h.state.Bundle == "/run/bundle", fmt.Sprintf("expected bundle /run/bundle, got %v", h.state.Bundle))
+
+	h = &hook_t{}
+	err = h.readState(strings.NewReader(`{"id": "abc"}`))
+	Assert(t, err != nil, "readState did not return error for state without bundle")
+
+	err = h.readState(strings.NewReader(`not json`))
+	Assert(t, err != nil, "readState did not return error for invalid state")
+}
+
+func TestNoGPUsRequested(t *testing.T) {
+	m := &mockHost{}
+	h := setupBundle(t, []string{"PATH=/usr/bin"}, "0::/system.slice\n")
+	h.mknod = m.mknod
+	h.nsExec = m.nsExec
+	h.releaseGPUs = m.releaseGPUs
+
+	err := h.Run()
+	Assert(t, err == nil, fmt.Sprintf("Run() returned error %v", err))
+	Assert(t, len(m.nodes) == 0, fmt.Sprintf("expected no device nodes, got %v", m.nodes))
+	Assert(t, len(m.cmds) == 0, fmt.Sprintf("expected no commands, got %v", m.cmds))
+}
+
+func TestSetupGPUs(t *testing.T) {
+	m := &mockHost{}
+	h := setupBundle(t, []string{"AMD_VISIBLE_DEVICES=1", "AMD_DRIVER_CAPABILITIES=compute"}, "12:devices:/docker/abc\n0::/\n")
+	h.mknod = m.mknod
+	h.nsExec = m.nsExec
+	h.releaseGPUs = m.releaseGPUs
+
+	cgroupDir := filepath.Join(h.cgroupDevicesRoot, "docker", "abc")
+	err := os.MkdirAll(cgroupDir, 0755)
+	Assert(t, err == nil, fmt.Sprintf("failed to create cgroup dir, Err: %v", err))
+	err = os.WriteFile(filepath.Join(cgroupDir, "devices.allow"), []byte{}, 0644)
+	Assert(t, err == nil, fmt.Sprintf("failed to create devices.allow, Err: %v", err))
+
+	hostLibDir := filepath.Join(filepath.Dir(h.procRoot), "host", "opt", "rocm", "lib")
+	err = os.MkdirAll(hostLibDir, 0755)
+	Assert(t, err == nil, fmt.Sprintf("failed to create ROCm lib dir, Err: %v", err))
+	err = os.WriteFile(filepath.Join(hostLibDir, "libamdhip64.so.6"), []byte{}, 0644)
+	Assert(t, err == nil, fmt.Sprintf("failed to create ROCm lib, Err: %v", err))
+
+	err = h.Run()
+	Assert(t, err == nil, fmt.Sprintf("Run() returned error %v", err))
+
+	expectedNodes := []string{
+		filepath.Join(h.hostRootfs, "/dev/dri/renderD129"),
+		filepath.Join(h.hostRootfs, "/dev/dri/card2"),
+		filepath.Join(h.hostRootfs, "/dev/kfd"),
+	}
+	Assert(t, slices.Equal(m.nodes, expectedNodes), fmt.Sprintf("expected device nodes %v, got %v", expectedNodes, m.nodes))
+
+	rules, err := os.ReadFile(filepath.Join(cgroupDir, "devices.allow"))
+	Assert(t, err == nil, fmt.Sprintf("failed to read devices.allow, Err: %v", err))
+	expectedRules := strings.Repeat("c 226:1 rwm", 3)
+	Assert(t, string(rules) == expectedRules, fmt.Sprintf("expected device rules %q, got %q", expectedRules, rules))
+
+	lib := "/opt/rocm/lib/libamdhip64.so.6"
+	dst := filepath.Join(h.rootfs, lib)
+	expectedCmds := [][]string{
+		{"mount", "--bind", lib, dst},
+		{"mount", "-o", "remount,bind,ro", dst},
+		{"ldconfig", "-r", h.rootfs},
+	}
+	Assert(t, len(m.cmds) == len(expectedCmds), fmt.Sprintf("expected commands %v, got %v", expectedCmds, m.cmds))
+	for i := range expectedCmds {
+		if i < len(m.cmds) {
+			Assert(t, slices.Equal(m.cmds[i], expectedCmds[i]), fmt.Sprintf("expected command %v, got %v", expectedCmds[i], m.cmds[i]))
+		}
+	}
+
+	conf, err := os.ReadFile(filepath.Join(h.hostRootfs, ldConfFile))
+	Assert(t, err == nil, fmt.Sprintf("failed to read %s, Err: %v", ldConfFile, err))
+	Assert(t, string(conf) == "/opt/rocm/lib\n", fmt.Sprintf("unexpected %s content %q", ldConfFile, conf))
+	Assert(t, len(m.released) == 0, fmt.Sprintf("expected no released containers, got %v", m.released))
+}
+
+func TestSetupGPUsCgroupV2(t *testing.T) {
+	m := &mockHost{}
+	h := setupBundle(t, []string{"AMD_VISIBLE_DEVICES=0"}, "0::/system.slice/docker-abc.scope\n")
+	h.mknod = m.mknod
+	h.nsExec = m.nsExec
+	h.releaseGPUs = m.releaseGPUs
+
+	// The spec does not allow the GPUs, the container could not use them
+	err := h.Run()
+	Assert(t, err != nil && strings.Contains(err.Error(), "cgroup v2"), fmt.Sprintf("Run() returned error %v", err))
+	Assert(t, slices.Equal(m.released, []string{TEST_CONTAINER_ID}), fmt.Sprintf("expected GPUs of %v to be released, got %v", TEST_CONTAINER_ID, m.released))
+
+	// The runtime allowed the GPU majors in the spec
+	testSpec := `{
+		"root": {"path": "rootfs"},
+		"process": {"env": ["AMD_VISIBLE_DEVICES=0"]},
+		"linux": {"resources": {"devices": [
+			{"allow": false, "access": "rwm"},
+			{"allow": true, "type": "c", "major": 226, "access": "rwm"}
+		]}}
+	}`
+	err = os.WriteFile(filepath.Join(h.state.Bundle, "config.json"), []byte(testSpec), 0644)
+	Assert(t, err == nil, fmt.Sprintf("failed to write test spec, Err: %v", err))
+
+	err = h.Run()
+	Assert(t, err == nil, fmt.Sprintf("Run() returned error %v", err))
+	Assert(t, len(m.nodes) == 3, fmt.Sprintf("expected 3 device nodes, got %v", m.nodes))
+
+	// No driver capabilities requested, nothing gets mounted
+	Assert(t, len(m.cmds) == 0, fmt.Sprintf("expected no commands, got %v", m.cmds))
+}
+
+func TestSetupGPUsRootless(t *testing.T) {
+	m := &mockHost{}
+	h := setupBundle(t, []string{"AMD_VISIBLE_DEVICES=0"}, "12:devices:/user.slice/abc\n")
+	h.mknod = m.mknod
+	h.nsExec = m.nsExec
+	h.releaseGPUs = m.releaseGPUs
+	h.rootless = true
+
+	// The devices controller is not writable, no rule is written to it,
+	// and the device nodes are bind mounted from the host
+	err := h.Run()
+	Assert(t, err == nil, fmt.Sprintf("Run() returned error %v", err))
+	Assert(t, len(m.nodes) == 0, fmt.Sprintf("expected no device nodes, got %v", m.nodes))
+	expected := []string{"mount", "--bind", "/dev/dri/renderD128", filepath.Join(h.rootfs, "/dev/dri/renderD128")}
+	Assert(t, len(m.cmds) == 3 && slices.Equal(m.cmds[0], expected), fmt.Sprintf("unexpected commands %v", m.cmds))
+	_, err = os.Stat(filepath.Join(h.hostRootfs, "/dev/kfd"))
+	Assert(t, err == nil, fmt.Sprintf("mount point of /dev/kfd not created: %v", err))
+}
+
+func TestSetupGPUsRootlessNoAccess(t *testing.T) {
+	m := &mockHost{}
+	h := setupBundle(t, []string{"AMD_VISIBLE_DEVICES=0"}, "12:devices:/user.slice/abc\n")
+	h.mknod = m.mknod
+	h.nsExec = m.nsExec
+	h.releaseGPUs = m.releaseGPUs
+	h.rootless = true
+	h.canAccess = func(string) bool { return false }
+
+	err := h.Run()
+	Assert(t, err != nil && strings.Contains(err.Error(), "GPU access is impossible for this user"), fmt.Sprintf("Run() returned error %v", err))
+	Assert(t, len(m.cmds) == 0, fmt.Sprintf("expected no commands, got %v", m.cmds))
+	Assert(t, slices.Equal(m.released, []string{TEST_CONTAINER_ID}), fmt.Sprintf("expected GPUs of %v to be released, got %v", TEST_CONTAINER_ID, m.released))
+}
+
+func TestSetupGPUsFailure(t *testing.T) {
+	m := &mockHost{}
+	h := setupBundle(t, []string{"AMD_VISIBLE_DEVICES=0"}, "0::/\n")
+	h.mknod = func(string, uint32, uint64) error {
+		return fmt.Errorf("operation not permitted")
+	}
+	h.nsExec = m.nsExec
+	h.releaseGPUs = m.releaseGPUs
+
+	err := h.Run()
+	Assert(t, err != nil, "Run() did not return error when expected")
+	Assert(t, slices.Equal(m.released, []string{TEST_CONTAINER_ID}), fmt.Sprintf("expected GPUs of %v to be released, got %v", TEST_CONTAINER_ID, m.released))
+}
+
+func TestSetupUnknownGPU(t *testing.T) {
+	m := &mockHost{}
+	h := setupBundle(t, []string{"AMD_VISIBLE_DEVICES=0,7"}, "0::/\n")
+	h.mknod = m.mknod
+	h.nsExec = m.nsExec
+	h.releaseGPUs = m.releaseGPUs
+
+	// The GPU list changed since the reservation
+	err := h.Run()
+	Assert(t, err != nil && strings.Contains(err.Error(), "GPU 7 not found"), fmt.Sprintf("Run() returned error %v", err))
+	Assert(t, len(m.nodes) == 0, fmt.Sprintf("expected no device nodes, got %v", m.nodes))
+	Assert(t, slices.Equal(m.released, []string{TEST_CONTAINER_ID}), fmt.Sprintf("expected GPUs of %v to be released, got %v", TEST_CONTAINER_ID, m.released))
+}
+
+func TestReleaseOnStop(t *testing.T) {
+	m := &mockHost{}
+	h := &hook_t{
+		state: specs.State{
+			ID:     TEST_CONTAINER_ID,
+			Status: specs.StateStopped,
+			Bundle: "/nonexistent",
+		},
+		releaseGPUs: m.releaseGPUs,
+	}
+
+	err := h.Run()
+	Assert(t, err == nil, fmt.Sprintf("Run() returned error %v", err))
+	Assert(t, slices.Equal(m.released, []string{TEST_CONTAINER_ID}), fmt.Sprintf("expected GPUs of %v to be released, got %v", TEST_CONTAINER_ID, m.released))
+
+	// The container did not request GPUs, there is nothing to release
+	h = setupBundle(t, []string{"PATH=/usr/bin"}, "0::/system.slice\n")
+	h.state.Status = specs.StateStopped
+	h.releaseGPUs = m.releaseGPUs
+	m.released = nil
+
+	err = h.Run()
+	Assert(t, err == nil, fmt.Sprintf("Run() returned error %v", err))
+	Assert(t, len(m.released) == 0, fmt.Sprintf("expected no GPUs to be released, got %v", m.released))
+}
+
+func TestEngineHook(t *testing.T) {
+	m := &mockHost{}
+	h := setupBundle(t, []string{"AMD_VISIBLE_DEVICES=0"}, "12:devices:/user.slice/abc\n")
+	h.mknod = m.mknod
+	h.nsExec = m.nsExec
+	h.releaseGPUs = m.releaseGPUs
+	h.rootless = true
+	h.engineHook = true
+
+	// amd-container-runtime added its own hook, the engine hook skips the
+	// container
+	testSpec := `{
+		"root": {"path": "rootfs"},
+		"process": {"env": ["AMD_VISIBLE_DEVICES=0"]},
+		"hooks": {
+			"createRuntime": [{"path": "/usr/local/bin/amd-container-runtime-hook"}],
+			"poststop": [{"path": "/usr/local/bin/amd-container-runtime-hook"}]
+		}
+	}`
+	err := os.WriteFile(filepath.Join(h.state.Bundle, "config.json"), []byte(testSpec), 0644)
+	Assert(t, err == nil, fmt.Sprintf("failed to write test spec, Err: %v", err))
+
+	err = h.Run()
+	Assert(t, err == nil, fmt.Sprintf("Run() returned error %v", err))
+	Assert(t, len(m.cmds) == 0, fmt.Sprintf("expected no commands, got %v", m.cmds))
+
+	h.state.Status = specs.StateStopped
+	err = h.Run()
+	Assert(t, err == nil, fmt.Sprintf("Run() returned error %v", err))
+	Assert(t, len(m.released) == 0, fmt.Sprintf("expected no GPUs to be released, got %v", m.released))
+
+	// Only the engine hook is set up, it handles the container
+	testSpec = `{
+		"root": {"path": "rootfs"},
+		"process": {"env": ["AMD_VISIBLE_DEVICES=0"]},
+		"hooks": {
+			"createRuntime": [{"path": "/usr/local/bin/amd-container-runtime-hook", "args": ["amd-container-runtime-hook", "--engine-hook"]}]
+		}
+	}`
+	err = os.WriteFile(filepath.Join(h.state.Bundle, "config.json"), []byte(testSpec), 0644)
+	Assert(t, err == nil, fmt.Sprintf("failed to write test spec, Err: %v", err))
+
+	h.state.Status = specs.StateCreating
+	err = h.Run()
+	Assert(t, err == nil, fmt.Sprintf("Run() returned error %v", err))
+	Assert(t, len(m.cmds) == 3, fmt.Sprintf("expected 3 device nodes to be mounted, got %v", m.cmds))
+
+	h.state.Status = specs.StateStopped
+	err = h.Run()
+	Assert(t, err == nil, fmt.Sprintf("Run() returned error %v", err))
+	Assert(t, slices.Equal(m.released, []string{TEST_CONTAINER_ID}), fmt.Sprintf("expected GPUs of %v to be released, got %v", TEST_CONTAINER_ID, m.released))
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
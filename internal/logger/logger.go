@@ -20,9 +20,10 @@ import (
 	"io"
 	"log/slog"
 	"os"
-	"os/user"
 	"path/filepath"
 	"sync"
+
+	"github.com/ROCm/container-toolkit/internal/rootless"
 )
 
 var (
@@ -42,6 +43,12 @@ func SetLogFile(file string) {
 	logfile = file
 }
 
+// SetDefaultLogDir sets the directory of logs, used when the LOGDIR
+// environment variable is not set
+func SetDefaultLogDir(dir string) {
+	logdir = dir
+}
+
 // SetLogDir sets the path to the directory of logs
 func SetLogDir() {
 
@@ -70,15 +77,14 @@ func SetLogDir() {
 		return
 	}
 
-	// Get the current user's information.
-	currentUser, err := user.Current()
-	if err != nil {
-		slog.Error("Failed to get current user", "error", err)
-		os.Exit(1)
-	}
-	// for root user, log dir is /var/log
-	if currentUser.Uid != "0" {
-		//Non-Root user, setting log directory to user's home directory
+	// for root user, log dir is the configured one, /var/log by default
+	if rootless.IsRootless() {
+		// Rootless users log to the directory set from the config,
+		// which defaults to their state directory, falling back to their
+		// home directory
+		if os.MkdirAll(logdir, 0755) == nil && isWriteable(logdir) {
+			return
+		}
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
 			slog.Error("Failed to get user home directory", "error", err)
@@ -0,0 +1,114 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package numa
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Constants
+const (
+	// Environment variable of the container selecting how it is pinned to
+	// the NUMA nodes local to its GPUs
+	PIN_ENV = "AMD_NUMA_PIN"
+
+	// Pins the CPUs and the memory of the container
+	PIN_STRICT = "strict"
+
+	// Pins the CPUs of the container, memory may still be allocated on
+	// any NUMA node
+	PIN_PREFERRED = "preferred"
+
+	// Disables the pinning, the same as not setting the variable
+	PIN_NONE = "none"
+)
+
+// GetPolicy returns the NUMA pinning policy requested in the environment
+// of a container, or an empty string if no pinning is requested
+func GetPolicy(env []string) (string, error) {
+	policy := ""
+	for _, e := range env {
+		pts := strings.SplitN(e, "=", 2)
+		if len(pts) == 2 && pts[0] == PIN_ENV {
+			policy = strings.ToLower(strings.TrimSpace(pts[1]))
+		}
+	}
+
+	switch policy {
+	case "", PIN_NONE:
+		return "", nil
+	case PIN_STRICT, PIN_PREFERRED:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unsupported %s value %q, must be either %q or %q", PIN_ENV, policy, PIN_STRICT, PIN_PREFERRED)
+	}
+}
+
+// ParseList parses a Linux CPU or node list such as "0-3,8,10-11"
+func ParseList(list string) ([]int, error) {
+	ret := []int{}
+	for _, item := range strings.Split(strings.TrimSpace(list), ",") {
+		if item == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(item, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid list %q", list)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(last)
+			if err != nil || end < start {
+				return nil, fmt.Errorf("invalid list %q", list)
+			}
+		}
+		for i := start; i <= end; i++ {
+			ret = append(ret, i)
+		}
+	}
+
+	return ret, nil
+}
+
+// FormatList formats ids as a Linux CPU or node list, merging
+// consecutive ids into ranges
+func FormatList(ids []int) string {
+	ids = slices.Clone(ids)
+	sort.Ints(ids)
+	ids = slices.Compact(ids)
+
+	items := []string{}
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		if i == j {
+			items = append(items, strconv.Itoa(ids[i]))
+		} else {
+			items = append(items, fmt.Sprintf("%d-%d", ids[i], ids[j]))
+		}
+		i = j + 1
+	}
+
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,51 @@
+package numa
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestGetPolicy(t *testing.T) {
+	tests := []struct {
+		env      []string
+		expected string
+		wantErr  bool
+	}{
+		{[]string{"PATH=/usr/bin"}, "", false},
+		{[]string{"AMD_NUMA_PIN=strict"}, PIN_STRICT, false},
+		{[]string{"AMD_NUMA_PIN=Preferred"}, PIN_PREFERRED, false},
+		{[]string{"AMD_NUMA_PIN=none"}, "", false},
+		{[]string{"AMD_NUMA_PIN=always"}, "", true},
+	}
+
+	for _, tt := range tests {
+		policy, err := GetPolicy(tt.env)
+		Assert(t, (err != nil) == tt.wantErr, fmt.Sprintf("%v: unexpected error %v", tt.env, err))
+		Assert(t, policy == tt.expected, fmt.Sprintf("%v: expected %q, got %q", tt.env, tt.expected, policy))
+	}
+}
+
+func TestParseFormatList(t *testing.T) {
+	ids, err := ParseList("0-3,8,10-11\n")
+	Assert(t, err == nil, fmt.Sprintf("ParseList returned error %v", err))
+	Assert(t, slices.Equal(ids, []int{0, 1, 2, 3, 8, 10, 11}), fmt.Sprintf("unexpected ids %v", ids))
+
+	ids, err = ParseList("")
+	Assert(t, err == nil && len(ids) == 0, fmt.Sprintf("unexpected result %v, %v", ids, err))
+
+	for _, list := range []string{"a", "3-1", "-1", "1-"} {
+		_, err = ParseList(list)
+		Assert(t, err != nil, fmt.Sprintf("ParseList accepted %q", list))
+	}
+
+	list := FormatList([]int{11, 0, 1, 2, 3, 8, 10, 2})
+	Assert(t, list == "0-3,8,10-11", fmt.Sprintf("unexpected list %q", list))
+	Assert(t, FormatList(nil) == "", "empty list should be formatted as an empty string")
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
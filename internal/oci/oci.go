@@ -21,17 +21,19 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/ROCm/container-toolkit/internal/amdgpu"
+	"github.com/ROCm/container-toolkit/internal/cdi"
+	"github.com/ROCm/container-toolkit/internal/config"
 	gpuTracker "github.com/ROCm/container-toolkit/internal/gpu-tracker"
+	"github.com/ROCm/container-toolkit/internal/numa"
+	"github.com/ROCm/container-toolkit/internal/rocm"
+	"github.com/ROCm/container-toolkit/internal/rootless"
 	"github.com/opencontainers/runtime-spec/specs-go"
-)
-
-// Constants
-const (
-	// Default path for AMD Container Runtime OCI hook
-	DEFAULT_HOOK_PATH = "/usr/bin/amd-container-runtime-hook"
+	cdispecs "tags.cncf.io/container-device-interface/specs-go"
 )
 
 // Interface for OCI package
@@ -62,7 +64,16 @@ type GetGPU func(string) (amdgpu.AMDGPU, error)
 type GetUniqueIdToDeviceIndexMap func() (map[string][]int, error)
 
 // ReserveGPUs is the type for functions that return a list of reserved GPUs
-type ReserveGPUs func(string, string) ([]int, error)
+type ReserveGPUs func(string, string, gpuTracker.Lease) ([]int, error)
+
+// ReleaseGPUs is the type for functions that release the GPUs held by a container
+type ReleaseGPUs func(string) error
+
+// GetCDIEdits is the type for functions that return the container edits of the given CDI devices
+type GetCDIEdits func([]string) (*cdispecs.ContainerEdits, error)
+
+// GetGPUInventory is the type for functions that return the GPU inventory
+type GetGPUInventory func() ([]amdgpu.GPUInfo, error)
 
 // oci_t implements the OCI interface
 type oci_t struct {
@@ -86,7 +97,19 @@ type oci_t struct {
 	// hookPath is the where the OCI hook executable is on the disk
 	hookPath string
 
-	// origSpecPath is where the input OCI spec is on the disk
+	// amdCtkPath is where the amd-ctk executable is on the disk
+	amdCtkPath string
+
+	// mode is how the GPU devices are added to the spec, either from sysfs
+	// or from the CDI spec on the disk
+	mode string
+
+	// bundle is the bundle directory of the container, passed with the
+	// --bundle argument
+	bundle string
+
+	// origSpecPath is the directory of the input OCI spec config.json,
+	// the bundle directory by default
 	origSpecPath string
 
 	// updatedSpecPath is where the updated OCI spec is put on the disk
@@ -106,6 +129,25 @@ type oci_t struct {
 
 	// reserveGPUs is the function that returns a list of reserved GPUs
 	reserveGPUs ReserveGPUs
+
+	// releaseGPUs is the function that releases the GPUs of a container
+	releaseGPUs ReleaseGPUs
+
+	// getCDIEdits is the function that returns the container edits of CDI devices
+	getCDIEdits GetCDIEdits
+
+	// rocm discovers the host ROCm files mounted into the container
+	rocm rocm.Interface
+
+	// getGPUInventory is the function that returns the GPU inventory
+	getGPUInventory GetGPUInventory
+
+	// rootless specifies if the runtime runs without the privileges of
+	// the host root user, e.g. under rootless podman or docker
+	rootless bool
+
+	// canAccess reports whether the user can read and write a device node
+	canAccess func(string) bool
 }
 
 // SpecUpdateOp specifies type of update operation on the OCI spec
@@ -138,9 +180,9 @@ func (oci *oci_t) parseArgs() {
 		parts := strings.SplitN(args[i], "=", 2)
 		if isBundlePathOption(parts[0]) {
 			if len(parts) == 2 {
-				oci.origSpecPath = parts[1]
+				oci.bundle = parts[1]
 			} else {
-				oci.origSpecPath = args[i+1]
+				oci.bundle = args[i+1]
 				i++
 			}
 		} else if isHelpOption(args[i]) {
@@ -153,20 +195,38 @@ func (oci *oci_t) parseArgs() {
 		oci.containerId = args[len(args)-1]
 	}
 
-	// By default, updateSpecPath is the same as origSpecPath
+	// The spec is read from and, by default, written to the bundle
+	oci.origSpecPath = oci.bundle
 	oci.updatedSpecPath = oci.origSpecPath
 }
 
 // getAMDEnv reads the value of "AMD_VISIBLE_DEVICES" or "DOCKER_RESOURCE_*" environment variables
-// in the spec. Supports both device indices and hex unique IDs.
+// in the spec. Supports device indices, hex unique IDs and CDI device names like "amd.com/gpu=0".
 func (oci *oci_t) getAMDEnv() error {
 	if oci.spec != nil && oci.spec.Process != nil {
 		envs := oci.spec.Process.Env
 		for _, env := range envs {
 			pts := strings.SplitN(env, "=", 2)
 			if len(pts) == 2 && (pts[0] == "AMD_VISIBLE_DEVICES" || strings.HasPrefix(pts[0], "DOCKER_RESOURCE_")) {
-				var err error
-				oci.amdDevices, err = oci.reserveGPUs(pts[1], oci.containerId)
+				// The lease settings are only read for containers
+				// requesting GPUs
+				lease, err := gpuTracker.LeaseFromEnv(envs)
+				if err != nil {
+					return err
+				}
+				lease.Pid = os.Getpid()
+				lease.Bundle = oci.bundle
+
+				gpus := strings.Split(pts[1], ",")
+				for i := range gpus {
+					name, err := cdi.ParseDeviceName(strings.TrimSpace(gpus[i]))
+					if err != nil {
+						return err
+					}
+					gpus[i] = name
+				}
+
+				oci.amdDevices, err = oci.reserveGPUs(strings.Join(gpus, ","), oci.containerId, lease)
 				if err != nil {
 					return err
 				}
@@ -204,12 +264,95 @@ func (oci *oci_t) getSpec() error {
 	return nil
 }
 
-// addHook adds the AMD runtime OCI hook into the spec
+// requestsGPUs returns true if the spec requests GPUs via the
+// "AMD_VISIBLE_DEVICES" or "DOCKER_RESOURCE_*" environment variables
+func (oci *oci_t) requestsGPUs() bool {
+	if oci.spec == nil || oci.spec.Process == nil {
+		return false
+	}
+
+	for _, env := range oci.spec.Process.Env {
+		pts := strings.SplitN(env, "=", 2)
+		if len(pts) == 2 && (pts[0] == "AMD_VISIBLE_DEVICES" || strings.HasPrefix(pts[0], "DOCKER_RESOURCE_")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addHookDeviceRules allows the device majors of the AMD GPUs in the
+// devices cgroup of the container. The hook only knows the GPUs once it
+// has reserved them, and cannot add device rules on cgroup v2 hosts,
+// where the low-level runtime filters the devices with the rules of the
+// spec.
+func (oci *oci_t) addHookDeviceRules() error {
+	if oci.rootless {
+		slog.Debug("Running rootless, skipping the devices cgroup rules of the hook")
+		return nil
+	}
+
+	devs, err := oci.getGPUs()
+	if err != nil {
+		return err
+	}
+
+	paths := []string{}
+	for _, dev := range devs {
+		paths = append(paths, dev.DrmDevices...)
+	}
+	paths = append(paths, "/dev/kfd")
+
+	if oci.spec.Linux == nil {
+		oci.spec.Linux = &specs.Linux{}
+	}
+	if oci.spec.Linux.Resources == nil {
+		oci.spec.Linux.Resources = &specs.LinuxResources{}
+	}
+
+	allowed := map[string]bool{}
+	for _, path := range paths {
+		gpu, err := oci.getGPU(path)
+		if err != nil {
+			return err
+		}
+
+		key := fmt.Sprintf("%s %d", gpu.DevType, gpu.Major)
+		if allowed[key] {
+			continue
+		}
+		allowed[key] = true
+
+		major := gpu.Major
+		oci.spec.Linux.Resources.Devices = append(oci.spec.Linux.Resources.Devices, specs.LinuxDeviceCgroup{
+			Allow:  true,
+			Type:   gpu.DevType,
+			Major:  &major,
+			Access: "rwm",
+		})
+		slog.Debug("Allowed GPU devices for the OCI runtime hook", "type", gpu.DevType, "major", major)
+	}
+
+	return nil
+}
+
+// addHook adds the AMD runtime OCI hook into the spec of containers
+// requesting GPUs. The hook sets up the GPUs at createRuntime and
+// releases them at poststop.
 func (oci *oci_t) addHook() error {
 	if oci.spec == nil {
 		return fmt.Errorf("OCI spec is nil")
 	}
 
+	if !oci.requestsGPUs() {
+		slog.Debug("No GPUs requested, skipping OCI runtime hook")
+		return nil
+	}
+
+	if err := oci.addHookDeviceRules(); err != nil {
+		return err
+	}
+
 	if oci.spec.Hooks == nil {
 		oci.spec.Hooks = &specs.Hooks{}
 	}
@@ -217,8 +360,12 @@ func (oci *oci_t) addHook() error {
 	hook := specs.Hook{
 		Path: oci.hookPath,
 	}
+	if oci.rootless {
+		hook.Env = rootless.Env()
+	}
 
 	oci.spec.Hooks.CreateRuntime = append(oci.spec.Hooks.CreateRuntime, hook)
+	oci.spec.Hooks.Poststop = append(oci.spec.Hooks.Poststop, hook)
 	slog.Debug("Added OCI runtime hook", "path", oci.hookPath)
 
 	return nil
@@ -235,6 +382,75 @@ func (oci *oci_t) isAddNoGPUs() bool {
 
 // addGPUDevices adds requested GPUs to the OCI spec
 func (oci *oci_t) addGPUDevices() error {
+	err := oci.getAMDEnv()
+	if err != nil {
+		return err
+	}
+
+	if oci.isAddNoGPUs() {
+		slog.Debug("No GPUs to be added to OCI spec")
+		return nil
+	}
+
+	slog.Info("Requested GPUs for container", "gpu_indices", oci.amdDevices, "mode", oci.mode)
+
+	// The container is not created if its spec cannot be updated, so the
+	// GPUs reserved for it are released
+	if err := oci.addReservedGPUs(); err != nil {
+		if e := oci.releaseGPUs(oci.containerId); e != nil {
+			slog.Error("Failed to release GPUs", "container", oci.containerId, "error", e)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// addReservedGPUs adds the GPUs reserved for the container to the OCI
+// spec, along with the ROCm files, the NUMA pinning and the hook releasing
+// the GPUs
+func (oci *oci_t) addReservedGPUs() error {
+	var err error
+	if oci.mode == config.RUNTIME_MODE_CDI {
+		err = oci.addCDIDevices()
+	} else {
+		err = oci.addSysfsDevices()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := oci.addROCmFiles(); err != nil {
+		return err
+	}
+
+	if err := oci.addNUMAPinning(); err != nil {
+		return err
+	}
+
+	if oci.spec.Hooks == nil {
+		oci.spec.Hooks = &specs.Hooks{}
+	}
+	hook1 := specs.Hook{
+		Path: oci.amdCtkPath,
+		Args: []string{
+			"amd-ctk",
+			"gpu-tracker",
+			"release",
+			oci.containerId,
+		},
+	}
+	if oci.rootless {
+		hook1.Env = rootless.Env()
+	}
+	oci.spec.Hooks.Poststop = append(oci.spec.Hooks.Poststop, hook1)
+
+	return nil
+}
+
+// addSysfsDevices adds the device nodes of the requested GPUs, as found
+// on the host, to the OCI spec
+func (oci *oci_t) addSysfsDevices() error {
 	addGpus := func(gpus []string) error {
 		for _, gpu := range gpus {
 			amdGPU, err := oci.getGPU(gpu)
@@ -251,50 +467,230 @@ func (oci *oci_t) addGPUDevices() error {
 		return nil
 	}
 
-	err := oci.getAMDEnv()
+	devs, err := oci.getGPUs()
 	if err != nil {
 		return err
 	}
 
-	if oci.isAddNoGPUs() {
-		slog.Debug("No GPUs to be added to OCI spec")
-		return nil
+	for _, idx := range oci.amdDevices {
+		if idx < 0 || idx >= len(devs) {
+			return fmt.Errorf("GPU %d not found in the GPU list", idx)
+		}
+		if err := addGpus(devs[idx].DrmDevices); err != nil {
+			return err
+		}
 	}
 
-	devs, err := oci.getGPUs()
+	kfd, err := oci.getGPU("/dev/kfd")
 	if err != nil {
 		return err
 	}
 
-	slog.Info("Requested GPUs for container", "gpu_indices", oci.amdDevices)
+	return oci.addGPUDevice(kfd)
+}
 
+// addCDIDevices applies the container edits of the requested GPUs in the
+// CDI spec on the disk to the OCI spec
+func (oci *oci_t) addCDIDevices() error {
+	names := []string{}
 	for _, idx := range oci.amdDevices {
-		if err := addGpus(devs[idx].DrmDevices); err != nil {
+		names = append(names, strconv.Itoa(idx))
+	}
+
+	edits, err := oci.getCDIEdits(names)
+	if err != nil {
+		return fmt.Errorf("getting CDI edits: %w", err)
+	}
+
+	for _, dn := range edits.DeviceNodes {
+		gpu := amdgpu.AMDGPU{
+			Path:    dn.Path,
+			Major:   dn.Major,
+			Minor:   dn.Minor,
+			Allow:   true,
+			DevType: dn.Type,
+			Access:  dn.Permissions,
+		}
+		if gpu.DevType == "" {
+			gpu.DevType = "c"
+		}
+		if gpu.Access == "" {
+			gpu.Access = "rwm"
+		}
+		if dn.FileMode != nil {
+			gpu.FileMode = *dn.FileMode
+		}
+		if dn.UID != nil {
+			gpu.Uid = *dn.UID
+		}
+		if dn.GID != nil {
+			gpu.Gid = *dn.GID
+		}
+		if err := oci.addGPUDevice(gpu); err != nil {
 			return err
 		}
 	}
 
-	kfd, err := oci.getGPU("/dev/kfd")
+	if len(edits.Env) > 0 {
+		if oci.spec.Process == nil {
+			oci.spec.Process = &specs.Process{}
+		}
+		oci.spec.Process.Env = append(oci.spec.Process.Env, edits.Env...)
+	}
+
+	for _, m := range edits.Mounts {
+		oci.spec.Mounts = append(oci.spec.Mounts, specs.Mount{
+			Destination: m.ContainerPath,
+			Source:      m.HostPath,
+			Type:        m.Type,
+			Options:     m.Options,
+		})
+	}
+
+	if len(edits.Hooks) > 0 && oci.spec.Hooks == nil {
+		oci.spec.Hooks = &specs.Hooks{}
+	}
+	for _, h := range edits.Hooks {
+		hook := specs.Hook{
+			Path:    h.Path,
+			Args:    h.Args,
+			Env:     h.Env,
+			Timeout: h.Timeout,
+		}
+		switch h.HookName {
+		case "prestart":
+			oci.spec.Hooks.Prestart = append(oci.spec.Hooks.Prestart, hook)
+		case "createRuntime":
+			oci.spec.Hooks.CreateRuntime = append(oci.spec.Hooks.CreateRuntime, hook)
+		case "createContainer":
+			oci.spec.Hooks.CreateContainer = append(oci.spec.Hooks.CreateContainer, hook)
+		case "startContainer":
+			oci.spec.Hooks.StartContainer = append(oci.spec.Hooks.StartContainer, hook)
+		case "poststart":
+			oci.spec.Hooks.Poststart = append(oci.spec.Hooks.Poststart, hook)
+		case "poststop":
+			oci.spec.Hooks.Poststop = append(oci.spec.Hooks.Poststop, hook)
+		default:
+			return fmt.Errorf("unsupported CDI hook %q", h.HookName)
+		}
+	}
+
+	slog.Debug("Applied CDI edits to OCI spec", "devices", names)
+
+	return nil
+}
+
+// addROCmFiles adds read-only bind mounts of the host ROCm libraries and
+// tools requested through AMD_DRIVER_CAPABILITIES to the OCI spec, along
+// with the matching LD_LIBRARY_PATH and PATH entries
+func (oci *oci_t) addROCmFiles() error {
+	if oci.spec.Process == nil || oci.rocm == nil {
+		return nil
+	}
+
+	caps, err := rocm.GetCapabilities(oci.spec.Process.Env)
 	if err != nil {
 		return err
 	}
-	if err := oci.addGPUDevice(kfd); err != nil {
+	if caps.IsEmpty() {
+		return nil
+	}
+
+	files, err := oci.rocm.Discover(caps)
+	if err != nil {
+		return fmt.Errorf("discovering ROCm files: %w", err)
+	}
+	if len(files.All()) == 0 {
+		slog.Warn("Host ROCm files not found", "capabilities", caps.String())
+		return nil
+	}
+
+	for _, f := range files.All() {
+		if slices.ContainsFunc(oci.spec.Mounts, func(m specs.Mount) bool { return m.Destination == f }) {
+			continue
+		}
+		oci.spec.Mounts = append(oci.spec.Mounts, specs.Mount{
+			Destination: f,
+			Source:      f,
+			Type:        "bind",
+			Options:     []string{"bind", "ro", "nosuid", "nodev"},
+		})
+	}
+
+	oci.spec.Process.Env = rocm.AppendPathEnv(oci.spec.Process.Env, "LD_LIBRARY_PATH", files.LibDirs())
+	// An image without PATH relies on the default search path, which must
+	// not be replaced by the tool directories alone
+	if slices.ContainsFunc(oci.spec.Process.Env, func(e string) bool { return strings.HasPrefix(e, "PATH=") }) {
+		oci.spec.Process.Env = rocm.AppendPathEnv(oci.spec.Process.Env, "PATH", files.ToolDirs())
+	}
+	slog.Debug("Added ROCm files to OCI spec", "capabilities", caps.String(), "files", files.All())
+
+	return nil
+}
+
+// addNUMAPinning restricts the container to the CPUs local to its GPUs, and
+// with the strict policy to the memory of their NUMA nodes, as requested
+// through AMD_NUMA_PIN. A cpuset set by the user is never overridden.
+func (oci *oci_t) addNUMAPinning() error {
+	if oci.spec.Process == nil || oci.getGPUInventory == nil {
+		return nil
+	}
+
+	policy, err := numa.GetPolicy(oci.spec.Process.Env)
+	if err != nil {
 		return err
 	}
+	if policy == "" {
+		return nil
+	}
 
-	if oci.spec.Hooks == nil {
-		oci.spec.Hooks = &specs.Hooks{}
+	if oci.spec.Linux != nil && oci.spec.Linux.Resources != nil && oci.spec.Linux.Resources.CPU != nil &&
+		(oci.spec.Linux.Resources.CPU.Cpus != "" || oci.spec.Linux.Resources.CPU.Mems != "") {
+		slog.Info("Keeping the cpuset set for the container", "cpus", oci.spec.Linux.Resources.CPU.Cpus,
+			"mems", oci.spec.Linux.Resources.CPU.Mems)
+		return nil
 	}
-	hook1 := specs.Hook{
-		Path: "/usr/local/bin/amd-ctk",
-		Args: []string{
-			"amd-ctk",
-			"gpu-tracker",
-			"release",
-			oci.containerId,
-		},
+
+	gpus, err := oci.getGPUInventory()
+	if err != nil {
+		return fmt.Errorf("getting AMD GPU inventory: %w", err)
 	}
-	oci.spec.Hooks.Poststop = append(oci.spec.Hooks.Poststop, hook1)
+
+	cpus := []int{}
+	nodes := []int{}
+	for _, gpuId := range oci.amdDevices {
+		if gpuId < 0 || gpuId >= len(gpus) {
+			return fmt.Errorf("GPU %d not found in the GPU inventory", gpuId)
+		}
+		gpu := gpus[gpuId]
+		if gpu.NUMANode < 0 || gpu.LocalCPUList == "" {
+			slog.Warn("NUMA node of GPU is unknown, not pinning the container", "gpu", gpuId)
+			return nil
+		}
+		localCPUs, err := numa.ParseList(gpu.LocalCPUList)
+		if err != nil {
+			return fmt.Errorf("local CPUs of GPU %d: %w", gpuId, err)
+		}
+		cpus = append(cpus, localCPUs...)
+		nodes = append(nodes, gpu.NUMANode)
+	}
+
+	if oci.spec.Linux == nil {
+		oci.spec.Linux = &specs.Linux{}
+	}
+	if oci.spec.Linux.Resources == nil {
+		oci.spec.Linux.Resources = &specs.LinuxResources{}
+	}
+	if oci.spec.Linux.Resources.CPU == nil {
+		oci.spec.Linux.Resources.CPU = &specs.LinuxCPU{}
+	}
+
+	oci.spec.Linux.Resources.CPU.Cpus = numa.FormatList(cpus)
+	if policy == numa.PIN_STRICT {
+		oci.spec.Linux.Resources.CPU.Mems = numa.FormatList(nodes)
+	}
+	slog.Info("Pinned container to the NUMA nodes of its GPUs", "policy", policy,
+		"cpus", oci.spec.Linux.Resources.CPU.Cpus, "mems", oci.spec.Linux.Resources.CPU.Mems)
 
 	return nil
 }
@@ -319,8 +715,24 @@ func (oci *oci_t) addGPUDevice(gpu amdgpu.AMDGPU) error {
 		oci.spec.Linux = &specs.Linux{}
 	}
 
+	if oci.rootless {
+		oci.mapDeviceOwner(&dev)
+		if oci.canAccess != nil && !oci.canAccess(gpu.Path) {
+			slog.Error("GPU device is not accessible to the user, the container will not be able to use it. "+
+				"Add the user to the group owning the device, usually render or video.",
+				"device", gpu.Path, "uid", os.Getuid(), "device_gid", gpu.Gid)
+		}
+	}
+
 	oci.spec.Linux.Devices = append(oci.spec.Linux.Devices, dev)
 
+	if oci.rootless {
+		// A rootless runtime cannot manage the devices cgroup. The device
+		// node is bind mounted and its file permissions decide the access.
+		slog.Debug("Added GPU device to OCI spec without a cgroup rule", "device", gpu.Path)
+		return nil
+	}
+
 	rdev := specs.LinuxDeviceCgroup{
 		Allow:  gpu.Allow,
 		Type:   gpu.DevType,
@@ -339,8 +751,40 @@ func (oci *oci_t) addGPUDevice(gpu amdgpu.AMDGPU) error {
 	return nil
 }
 
+// mapDeviceOwner translates the owner of a device node into the user
+// namespace of the container. An owner that is not mapped is left unset,
+// and the node keeps the owner it has on the host.
+func (oci *oci_t) mapDeviceOwner(dev *specs.LinuxDevice) {
+	if dev.UID != nil {
+		uid, ok := rootless.MapID(*dev.UID, oci.spec.Linux.UIDMappings)
+		if ok && *dev.UID != rootless.OverflowUID() {
+			dev.UID = &uid
+		} else {
+			dev.UID = nil
+		}
+	}
+
+	if dev.GID != nil {
+		gid, ok := rootless.MapID(*dev.GID, oci.spec.Linux.GIDMappings)
+		if ok && *dev.GID != rootless.OverflowGID() {
+			dev.GID = &gid
+		} else {
+			slog.Warn("Group owning the GPU device is not mapped into the container. "+
+				"Processes in the container need it as a supplementary group to use the GPU, "+
+				"e.g. with podman --group-add keep-groups.",
+				"device", dev.Path, "gid", *dev.GID)
+			dev.GID = nil
+		}
+	}
+}
+
 // New creates an OCI instance
 func New(argv []string) (Interface, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+
 	gpuTracker, err := gpuTracker.New()
 	if err != nil {
 		return nil, err
@@ -348,11 +792,26 @@ func New(argv []string) (Interface, error) {
 
 	oci := &oci_t{
 		args:                        argv,
-		hookPath:                    DEFAULT_HOOK_PATH,
+		hookPath:                    cfg.Hook.Path,
+		amdCtkPath:                  cfg.AmdCtk.Path,
+		mode:                        cfg.Runtime.Mode,
+		rocm:                        rocm.New(cfg.ROCm.Path),
 		getGPUs:                     amdgpu.GetAMDGPUs,
 		getGPU:                      amdgpu.GetAMDGPU,
 		getUniqueIdToDeviceIndexMap: amdgpu.GetUniqueIdToDeviceIndexMap,
 		reserveGPUs:                 gpuTracker.ReserveGPUs,
+		releaseGPUs:                 gpuTracker.ReleaseGPUs,
+		getGPUInventory:             amdgpu.GetGPUInventory,
+		rootless:                    rootless.IsRootless(),
+		canAccess:                   rootless.CanAccess,
+	}
+
+	if cfg.Runtime.Mode == config.RUNTIME_MODE_CDI {
+		cdiHandler, err := cdi.New(cfg.Runtime.CDISpecPath)
+		if err != nil {
+			return nil, err
+		}
+		oci.getCDIEdits = cdiHandler.GetContainerEdits
 	}
 
 	oci.parseArgs()
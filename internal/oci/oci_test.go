@@ -3,13 +3,20 @@ package oci
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ROCm/container-toolkit/internal/amdgpu"
+	"github.com/ROCm/container-toolkit/internal/config"
+	gpuTracker "github.com/ROCm/container-toolkit/internal/gpu-tracker"
+	"github.com/ROCm/container-toolkit/internal/rocm"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	cdispecs "tags.cncf.io/container-device-interface/specs-go"
 )
 
 // Constants
@@ -65,7 +72,7 @@ func mockGetAMDGPU(dev string) (amdgpu.AMDGPU, error) {
 	return gpu, nil
 }
 
-func mockReserveGPUs(gpus string, containerId string) ([]int, error) {
+func mockReserveGPUs(gpus string, containerId string, lease gpuTracker.Lease) ([]int, error) {
 	parseGPUsList := func(gpus string) ([]int, []string, []string, error) {
 		// isHexString checks if a string contains only hexadecimal characters
 		isHexString := func(s string) bool {
@@ -157,6 +164,10 @@ func mockReserveGPUs(gpus string, containerId string) ([]int, error) {
 	return validGPUs, err
 }
 
+func mockReleaseGPUs(containerId string) error {
+	return nil
+}
+
 func TestParseArgs(t *testing.T) {
 	oci := &oci_t{}
 
@@ -175,7 +186,8 @@ func TestParseArgs(t *testing.T) {
 	oci.parseArgs()
 	Assert(t, len(oci.args) > 0, fmt.Sprintf("empty args, %v", oci.args))
 	Assert(t, oci.isCreate, "isCreate is False")
-	Assert(t, oci.origSpecPath != "", "empty origSpecPath")
+	Assert(t, oci.bundle == "../../tests", fmt.Sprintf("unexpected bundle %v", oci.bundle))
+	Assert(t, oci.origSpecPath == oci.bundle, fmt.Sprintf("origSpecPath %v is different from the bundle", oci.origSpecPath))
 	Assert(t, oci.updatedSpecPath == oci.origSpecPath, fmt.Sprintf("updateSpecPath %v is different from origSpecPath %v", oci.updatedSpecPath, oci.origSpecPath))
 
 	// Bundle arg ("--bundle=xyz") arg with create command
@@ -184,7 +196,9 @@ func TestParseArgs(t *testing.T) {
 	Assert(t, len(oci.args) > 0, fmt.Sprintf("empty args, %v", oci.args))
 	Assert(t, oci.IsCreate(), "isCreate is False")
 	Assert(t, !oci.HasHelpOption(), "hasHelpOption is True")
-	Assert(t, oci.origSpecPath != "", "empty origSpecPath")
+	Assert(t, oci.bundle == "/run/containerd/io.containerd.runtime.v2.task/moby/f936e9ab998d8dd8000f9f61180754ae669ac89aa594d195ec8a5ef16e1a9919",
+		fmt.Sprintf("unexpected bundle %v", oci.bundle))
+	Assert(t, oci.origSpecPath == oci.bundle, fmt.Sprintf("origSpecPath %v is different from the bundle", oci.origSpecPath))
 	Assert(t, oci.updatedSpecPath == oci.origSpecPath, fmt.Sprintf("updateSpecPath %v is different from origSpecPath %v", oci.updatedSpecPath, oci.origSpecPath))
 
 	oci = &oci_t{}
@@ -197,6 +211,7 @@ func TestGetAMDEnv(t *testing.T) {
 		getGPU:                      mockGetAMDGPU,
 		getUniqueIdToDeviceIndexMap: mockGetUniqueIdToDeviceIndexMap,
 		reserveGPUs:                 mockReserveGPUs,
+		releaseGPUs:                 mockReleaseGPUs,
 	}
 	err := oci.getSpec()
 	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
@@ -212,6 +227,31 @@ func TestGetAMDEnv(t *testing.T) {
 	oci = &oci_t{}
 }
 
+func TestAddGPUDevicesUnknownGPU(t *testing.T) {
+	released := []string{}
+	oci := &oci_t{
+		containerId:                 "abc",
+		origSpecPath:                TEST_OCI_SPEC_PATH,
+		getGPUs:                     mockGetAMDGPUs,
+		getGPU:                      mockGetAMDGPU,
+		getUniqueIdToDeviceIndexMap: mockGetUniqueIdToDeviceIndexMap,
+		reserveGPUs: func(string, string, gpuTracker.Lease) ([]int, error) {
+			return []int{0, 7}, nil
+		},
+		releaseGPUs: func(containerId string) error {
+			released = append(released, containerId)
+			return nil
+		},
+	}
+	err := oci.getSpec()
+	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
+
+	// The GPU list changed since the reservation
+	err = oci.addGPUDevices()
+	Assert(t, err != nil && strings.Contains(err.Error(), "GPU 7 not found"), fmt.Sprintf("addGPUDevices returned error %v", err))
+	Assert(t, slices.Equal(released, []string{"abc"}), fmt.Sprintf("expected GPUs of abc to be released, got %v", released))
+}
+
 func TestAddGPUDevice(t *testing.T) {
 	oci := &oci_t{
 		origSpecPath:                TEST_OCI_SPEC_PATH,
@@ -264,6 +304,90 @@ func TestAddGPUDevice(t *testing.T) {
 	Assert(t, resDevFound, fmt.Sprintf("dev %v,%v not found in spec", gpu.Major, gpu.Minor))
 }
 
+func TestAddGPUDeviceRootless(t *testing.T) {
+	accessed := []string{}
+	oci := &oci_t{
+		origSpecPath: TEST_OCI_SPEC_PATH,
+		rootless:     true,
+		canAccess: func(path string) bool {
+			accessed = append(accessed, path)
+			return false
+		},
+	}
+	err := oci.getSpec()
+	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
+
+	oci.spec.Linux.UIDMappings = []specs.LinuxIDMapping{{ContainerID: 0, HostID: 0, Size: 1}}
+	oci.spec.Linux.GIDMappings = []specs.LinuxIDMapping{{ContainerID: 0, HostID: 0, Size: 1}, {ContainerID: 1, HostID: 1, Size: 40}}
+	rules := 0
+	if oci.spec.Linux.Resources != nil {
+		rules = len(oci.spec.Linux.Resources.Devices)
+	}
+
+	gpus := []amdgpu.AMDGPU{
+		{Path: "/dev/kfd", Major: 235, Minor: 0, FileMode: 438, Gid: 40, Uid: 0, Allow: true, DevType: "c", Access: "rwm"},
+		{Path: "/dev/dri/renderD128", Major: 226, Minor: 128, FileMode: 432, Gid: 65534, Uid: 65534, Allow: true, DevType: "c", Access: "rwm"},
+	}
+	for _, gpu := range gpus {
+		err = oci.addGPUDevice(gpu)
+		Assert(t, err == nil, fmt.Sprintf("addGpuDevice returned error %v", err))
+	}
+	Assert(t, slices.Equal(accessed, []string{"/dev/kfd", "/dev/dri/renderD128"}), fmt.Sprintf("unexpected access checks %v", accessed))
+
+	devices := map[string]specs.LinuxDevice{}
+	for _, d := range oci.spec.Linux.Devices {
+		devices[d.Path] = d
+	}
+	kfd, render := devices["/dev/kfd"], devices["/dev/dri/renderD128"]
+	Assert(t, kfd.UID != nil && *kfd.UID == 0 && kfd.GID != nil && *kfd.GID == 40, fmt.Sprintf("unexpected owner of %+v", kfd))
+	Assert(t, render.UID == nil && render.GID == nil, fmt.Sprintf("the unmapped owner of %+v should be unset", render))
+
+	Assert(t, oci.spec.Linux.Resources == nil || len(oci.spec.Linux.Resources.Devices) == rules, "no cgroup rules should be added in rootless mode")
+
+	t.Setenv("HOME", "/home/user")
+	t.Setenv("XDG_STATE_HOME", "")
+	oci.hookPath = "/usr/local/bin/amd-container-runtime-hook"
+	err = oci.addHook()
+	Assert(t, err == nil, fmt.Sprintf("addHook returned error %v", err))
+	hooks := oci.spec.Hooks.CreateRuntime
+	Assert(t, slices.Equal(hooks[len(hooks)-1].Env, []string{"HOME=/home/user"}), fmt.Sprintf("unexpected hook env %v", hooks[len(hooks)-1].Env))
+}
+
+func TestAddHook(t *testing.T) {
+	oci := &oci_t{
+		origSpecPath: TEST_OCI_SPEC_PATH,
+		hookPath:     "/usr/local/bin/amd-container-runtime-hook",
+		getGPUs:      mockGetAMDGPUs,
+		getGPU:       mockGetAMDGPU,
+	}
+	err := oci.getSpec()
+	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
+	oci.spec.Hooks = nil
+	rules := 0
+	if oci.spec.Linux != nil && oci.spec.Linux.Resources != nil {
+		rules = len(oci.spec.Linux.Resources.Devices)
+	}
+
+	err = oci.addHook()
+	Assert(t, err == nil, fmt.Sprintf("addHook returned error %v", err))
+	Assert(t, len(oci.spec.Hooks.CreateRuntime) == 1 && oci.spec.Hooks.CreateRuntime[0].Path == oci.hookPath,
+		fmt.Sprintf("unexpected createRuntime hooks %+v", oci.spec.Hooks.CreateRuntime))
+	Assert(t, len(oci.spec.Hooks.Poststop) == 1 && oci.spec.Hooks.Poststop[0].Path == oci.hookPath,
+		fmt.Sprintf("unexpected poststop hooks %+v", oci.spec.Hooks.Poststop))
+
+	// The devices of all the GPUs are allowed by major, as the hook cannot
+	// add device rules on cgroup v2
+	added := oci.spec.Linux.Resources.Devices[rules:]
+	Assert(t, len(added) == 1 && added[0].Allow && added[0].Type == "c" && *added[0].Major == 226 && added[0].Minor == nil,
+		fmt.Sprintf("unexpected device rules %+v", added))
+
+	oci.spec.Hooks = nil
+	oci.spec.Process.Env = []string{"PATH=/usr/bin"}
+	err = oci.addHook()
+	Assert(t, err == nil, fmt.Sprintf("addHook returned error %v", err))
+	Assert(t, oci.spec.Hooks == nil, fmt.Sprintf("hook should not be added without GPUs requested, got %+v", oci.spec.Hooks))
+}
+
 func TestNew(t *testing.T) {
 	_, err := New(strings.Split(CREATE_ARGS, " "))
 	Assert(t, err == nil, fmt.Sprintf("New() returned error %v", err))
@@ -276,6 +400,7 @@ func TestInterface(t *testing.T) {
 		getGPU:                      mockGetAMDGPU,
 		getUniqueIdToDeviceIndexMap: mockGetUniqueIdToDeviceIndexMap,
 		reserveGPUs:                 mockReserveGPUs,
+		releaseGPUs:                 mockReleaseGPUs,
 	}
 	err := oci.getSpec()
 	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
@@ -311,6 +436,40 @@ func mockGetUniqueIdToDeviceIndexMap() (map[string][]int, error) {
 	}, nil
 }
 
+func TestGetAMDEnvWithLeaseTTL(t *testing.T) {
+	tmpDir := t.TempDir()
+	testSpec := `{"process": {"env": ["AMD_VISIBLE_DEVICES=0", "AMD_GPU_LEASE_TTL=2h"]}}`
+	err := os.WriteFile(tmpDir+"/config.json", []byte(testSpec), 0644)
+	Assert(t, err == nil, fmt.Sprintf("failed to write test spec, Err: %v", err))
+
+	var leases []gpuTracker.Lease
+	oci := &oci_t{
+		bundle:       tmpDir,
+		origSpecPath: tmpDir,
+		reserveGPUs: func(gpus string, containerId string, lease gpuTracker.Lease) ([]int, error) {
+			leases = append(leases, lease)
+			return mockReserveGPUs(gpus, containerId, lease)
+		},
+	}
+	err = oci.getSpec()
+	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
+
+	err = oci.getAMDEnv()
+	Assert(t, err == nil, fmt.Sprintf("getAMDEnv returned error %v", err))
+	Assert(t, len(leases) == 1 && leases[0].TTL == 2*time.Hour && leases[0].Bundle == tmpDir && leases[0].Pid == os.Getpid(),
+		fmt.Sprintf("unexpected leases %+v", leases))
+
+	oci.spec.Process.Env = []string{"AMD_VISIBLE_DEVICES=0", "AMD_GPU_LEASE_TTL=forever"}
+	err = oci.getAMDEnv()
+	Assert(t, err != nil, "getAMDEnv did not return error for an invalid lease TTL")
+
+	// The lease settings of containers without GPUs are not read
+	leases = nil
+	oci.spec.Process.Env = []string{"AMD_GPU_LEASE_TTL=forever"}
+	err = oci.getAMDEnv()
+	Assert(t, err == nil && len(leases) == 0, fmt.Sprintf("getAMDEnv without GPUs returned error %v, leases %+v", err, leases))
+}
+
 func TestGetAMDEnvWithUUID(t *testing.T) {
 	// Test with hex UUID in AMD_VISIBLE_DEVICES
 	testSpec := `{
@@ -333,6 +492,7 @@ func TestGetAMDEnvWithUUID(t *testing.T) {
 		getGPU:                      mockGetAMDGPU,
 		getUniqueIdToDeviceIndexMap: mockGetUniqueIdToDeviceIndexMap,
 		reserveGPUs:                 mockReserveGPUs,
+		releaseGPUs:                 mockReleaseGPUs,
 	}
 	err = oci.getSpec()
 	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
@@ -364,6 +524,7 @@ func TestGetAMDEnvWithDockerResource(t *testing.T) {
 		getGPU:                      mockGetAMDGPU,
 		getUniqueIdToDeviceIndexMap: mockGetUniqueIdToDeviceIndexMap,
 		reserveGPUs:                 mockReserveGPUs,
+		releaseGPUs:                 mockReleaseGPUs,
 	}
 	err = oci.getSpec()
 	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
@@ -395,6 +556,7 @@ func TestGetAMDEnvWithMixedDevices(t *testing.T) {
 		getGPU:                      mockGetAMDGPU,
 		getUniqueIdToDeviceIndexMap: mockGetUniqueIdToDeviceIndexMap,
 		reserveGPUs:                 mockReserveGPUs,
+		releaseGPUs:                 mockReleaseGPUs,
 	}
 	err = oci.getSpec()
 	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
@@ -426,6 +588,7 @@ func TestGetAMDEnvWithInvalidUUID(t *testing.T) {
 		getGPU:                      mockGetAMDGPU,
 		getUniqueIdToDeviceIndexMap: mockGetUniqueIdToDeviceIndexMap,
 		reserveGPUs:                 mockReserveGPUs,
+		releaseGPUs:                 mockReleaseGPUs,
 	}
 	err = oci.getSpec()
 	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
@@ -457,6 +620,7 @@ func TestGetAMDEnvWithDuplicateDevices(t *testing.T) {
 		getGPU:                      mockGetAMDGPU,
 		getUniqueIdToDeviceIndexMap: mockGetUniqueIdToDeviceIndexMap,
 		reserveGPUs:                 mockReserveGPUs,
+		releaseGPUs:                 mockReleaseGPUs,
 	}
 	err = oci.getSpec()
 	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
@@ -465,3 +629,202 @@ func TestGetAMDEnvWithDuplicateDevices(t *testing.T) {
 	expectedDevs := []int{0, 0} // Both device index 0 and UUID that maps to 0 should result in [0, 0] - duplicates allowed
 	Assert(t, slices.Equal(oci.amdDevices, expectedDevs), fmt.Sprintf("expected amdDevices %v, got %v", expectedDevs, oci.amdDevices))
 }
+
+func TestGetAMDEnvWithCDINames(t *testing.T) {
+	testSpec := `{
+		"process": {
+			"env": [
+				"AMD_VISIBLE_DEVICES=amd.com/gpu=1,amd.com/gpu=0"
+			]
+		}
+	}`
+
+	tmpDir := t.TempDir()
+	err := os.WriteFile(tmpDir+"/config.json", []byte(testSpec), 0644)
+	Assert(t, err == nil, fmt.Sprintf("failed to write test spec, Err: %v", err))
+
+	oci := &oci_t{
+		origSpecPath: tmpDir,
+		reserveGPUs:  mockReserveGPUs,
+		releaseGPUs:  mockReleaseGPUs,
+	}
+	err = oci.getSpec()
+	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
+
+	err = oci.getAMDEnv()
+	Assert(t, err == nil, fmt.Sprintf("getAMDEnv returned error %v", err))
+	expectedDevs := []int{0, 1}
+	Assert(t, slices.Equal(oci.amdDevices, expectedDevs), fmt.Sprintf("expected amdDevices %v, got %v", expectedDevs, oci.amdDevices))
+
+	oci.spec.Process.Env = []string{"AMD_VISIBLE_DEVICES=nvidia.com/gpu=0"}
+	err = oci.getAMDEnv()
+	Assert(t, err != nil, "getAMDEnv did not return error for a foreign CDI device kind")
+}
+
+func TestAddCDIDevices(t *testing.T) {
+	testSpec := `{
+		"process": {
+			"env": [
+				"AMD_VISIBLE_DEVICES=1"
+			]
+		}
+	}`
+
+	tmpDir := t.TempDir()
+	err := os.WriteFile(tmpDir+"/config.json", []byte(testSpec), 0644)
+	Assert(t, err == nil, fmt.Sprintf("failed to write test spec, Err: %v", err))
+
+	requested := []string{}
+	mode := os.FileMode(432)
+	gid := uint32(44)
+	oci := &oci_t{
+		origSpecPath: tmpDir,
+		mode:         config.RUNTIME_MODE_CDI,
+		amdCtkPath:   "/usr/bin/amd-ctk",
+		reserveGPUs:  mockReserveGPUs,
+		releaseGPUs:  mockReleaseGPUs,
+		getGPUs: func() ([]amdgpu.DeviceInfo, error) {
+			return nil, fmt.Errorf("sysfs must not be walked in CDI mode")
+		},
+		getCDIEdits: func(devices []string) (*cdispecs.ContainerEdits, error) {
+			requested = append(requested, devices...)
+			return &cdispecs.ContainerEdits{
+				DeviceNodes: []*cdispecs.DeviceNode{
+					{Path: "/dev/dri/renderD129", Type: "c", Major: 226, Minor: 129, FileMode: &mode, GID: &gid},
+					{Path: "/dev/kfd", Type: "c", Major: 235, Minor: 0, FileMode: &mode, GID: &gid},
+				},
+				Env: []string{"HSA_ENABLE_SDMA=0"},
+				Mounts: []*cdispecs.Mount{
+					{HostPath: "/opt/rocm/lib", ContainerPath: "/opt/rocm/lib", Options: []string{"ro", "bind"}},
+				},
+			}, nil
+		},
+	}
+	err = oci.getSpec()
+	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
+
+	err = oci.addGPUDevices()
+	Assert(t, err == nil, fmt.Sprintf("addGPUDevices returned error %v", err))
+	Assert(t, slices.Equal(requested, []string{"1"}), fmt.Sprintf("expected CDI device 1 to be requested, got %v", requested))
+
+	paths := []string{}
+	for _, d := range oci.spec.Linux.Devices {
+		paths = append(paths, d.Path)
+		Assert(t, d.GID != nil && *d.GID == gid, fmt.Sprintf("unexpected GID for %v", d.Path))
+	}
+	Assert(t, slices.Equal(paths, []string{"/dev/dri/renderD129", "/dev/kfd"}), fmt.Sprintf("unexpected devices %v", paths))
+	Assert(t, len(oci.spec.Linux.Resources.Devices) == 2, fmt.Sprintf("expected 2 device cgroup rules, got %v", oci.spec.Linux.Resources.Devices))
+	Assert(t, slices.Contains(oci.spec.Process.Env, "HSA_ENABLE_SDMA=0"), fmt.Sprintf("CDI env not applied, got %v", oci.spec.Process.Env))
+	Assert(t, len(oci.spec.Mounts) == 1 && oci.spec.Mounts[0].Destination == "/opt/rocm/lib", fmt.Sprintf("CDI mount not applied, got %v", oci.spec.Mounts))
+	Assert(t, len(oci.spec.Hooks.Poststop) == 1 && oci.spec.Hooks.Poststop[0].Path == "/usr/bin/amd-ctk",
+		fmt.Sprintf("release hook not added, got %v", oci.spec.Hooks.Poststop))
+}
+
+func TestAddROCmFiles(t *testing.T) {
+	testSpec := `{
+		"process": {
+			"env": [
+				"PATH=/usr/bin",
+				"AMD_VISIBLE_DEVICES=0",
+				"AMD_DRIVER_CAPABILITIES=all"
+			]
+		}
+	}`
+
+	tmpDir := t.TempDir()
+	err := os.WriteFile(tmpDir+"/config.json", []byte(testSpec), 0644)
+	Assert(t, err == nil, fmt.Sprintf("failed to write test spec, Err: %v", err))
+
+	root := filepath.Join(tmpDir, "host")
+	for _, f := range []string{"/opt/rocm/lib/libamdhip64.so.6", "/opt/rocm/bin/amd-smi"} {
+		err = os.MkdirAll(filepath.Dir(root+f), 0755)
+		Assert(t, err == nil, fmt.Sprintf("failed to create directory for %v, Err: %v", f, err))
+		err = os.WriteFile(root+f, []byte{}, 0755)
+		Assert(t, err == nil, fmt.Sprintf("failed to create %v, Err: %v", f, err))
+	}
+
+	oci := &oci_t{
+		origSpecPath:                tmpDir,
+		getGPUs:                     mockGetAMDGPUs,
+		getGPU:                      mockGetAMDGPU,
+		getUniqueIdToDeviceIndexMap: mockGetUniqueIdToDeviceIndexMap,
+		reserveGPUs:                 mockReserveGPUs,
+		releaseGPUs:                 mockReleaseGPUs,
+		rocm:                        rocm.NewWithRoot(root, "/opt/rocm"),
+	}
+	err = oci.getSpec()
+	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
+
+	err = oci.addGPUDevices()
+	Assert(t, err == nil, fmt.Sprintf("addGPUDevices returned error %v", err))
+
+	mounts := []string{}
+	for _, m := range oci.spec.Mounts {
+		mounts = append(mounts, m.Destination)
+		Assert(t, m.Source == m.Destination && slices.Contains(m.Options, "ro"), fmt.Sprintf("unexpected mount %+v", m))
+	}
+	Assert(t, slices.Equal(mounts, []string{"/opt/rocm/lib/libamdhip64.so.6", "/opt/rocm/bin/amd-smi"}), fmt.Sprintf("unexpected mounts %v", mounts))
+	Assert(t, slices.Contains(oci.spec.Process.Env, "LD_LIBRARY_PATH=/opt/rocm/lib"), fmt.Sprintf("LD_LIBRARY_PATH not set, got %v", oci.spec.Process.Env))
+	Assert(t, slices.Contains(oci.spec.Process.Env, "PATH=/usr/bin:/opt/rocm/bin"), fmt.Sprintf("PATH not updated, got %v", oci.spec.Process.Env))
+
+	// Without driver capabilities only the devices are added
+	err = oci.getSpec()
+	Assert(t, err == nil, fmt.Sprintf("failed to get OCI spec, Err: %v", err))
+	oci.spec.Process.Env = []string{"AMD_VISIBLE_DEVICES=0"}
+	err = oci.addGPUDevices()
+	Assert(t, err == nil, fmt.Sprintf("addGPUDevices returned error %v", err))
+	Assert(t, len(oci.spec.Mounts) == 0, fmt.Sprintf("expected no mounts, got %v", oci.spec.Mounts))
+}
+
+func mockGetGPUInventory() ([]amdgpu.GPUInfo, error) {
+	return []amdgpu.GPUInfo{
+		{Index: 0, NUMANode: 0, LocalCPUList: "0-7,16-23"},
+		{Index: 1, NUMANode: 1, LocalCPUList: "8-15,24-31"},
+	}, nil
+}
+
+func TestAddNUMAPinning(t *testing.T) {
+	tests := []struct {
+		name string
+		env  []string
+		cpu  *specs.LinuxCPU
+		cpus string
+		mems string
+		err  bool
+	}{
+		{"no pinning", []string{"AMD_VISIBLE_DEVICES=0"}, nil, "", "", false},
+		{"strict", []string{"AMD_VISIBLE_DEVICES=0", "AMD_NUMA_PIN=strict"}, nil, "0-7,16-23", "0", false},
+		{"preferred", []string{"AMD_VISIBLE_DEVICES=0", "AMD_NUMA_PIN=preferred"}, nil, "0-7,16-23", "", false},
+		{"strict on two nodes", []string{"AMD_VISIBLE_DEVICES=all", "AMD_NUMA_PIN=strict"}, nil, "0-31", "0-1", false},
+		{"user cpuset", []string{"AMD_VISIBLE_DEVICES=0", "AMD_NUMA_PIN=strict"}, &specs.LinuxCPU{Cpus: "2-3"}, "2-3", "", false},
+		{"invalid policy", []string{"AMD_VISIBLE_DEVICES=0", "AMD_NUMA_PIN=always"}, nil, "", "", true},
+	}
+
+	for _, tt := range tests {
+		oci := &oci_t{
+			getGPUs:                     mockGetAMDGPUs,
+			getGPU:                      mockGetAMDGPU,
+			getUniqueIdToDeviceIndexMap: mockGetUniqueIdToDeviceIndexMap,
+			reserveGPUs:                 mockReserveGPUs,
+			releaseGPUs:                 mockReleaseGPUs,
+			getGPUInventory:             mockGetGPUInventory,
+			spec: &specs.Spec{
+				Process: &specs.Process{Env: tt.env},
+				Linux:   &specs.Linux{Resources: &specs.LinuxResources{CPU: tt.cpu}},
+			},
+		}
+
+		err := oci.addGPUDevices()
+		Assert(t, (err != nil) == tt.err, fmt.Sprintf("%s: unexpected error %v", tt.name, err))
+		if err != nil {
+			continue
+		}
+
+		cpus, mems := "", ""
+		if cpu := oci.spec.Linux.Resources.CPU; cpu != nil {
+			cpus, mems = cpu.Cpus, cpu.Mems
+		}
+		Assert(t, cpus == tt.cpus, fmt.Sprintf("%s: expected cpus %q, got %q", tt.name, tt.cpus, cpus))
+		Assert(t, mems == tt.mems, fmt.Sprintf("%s: expected mems %q, got %q", tt.name, tt.mems, mems))
+	}
+}
@@ -0,0 +1,462 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ROCm/container-toolkit/internal/amdgpu"
+	"github.com/ROCm/container-toolkit/internal/cdi"
+	gpuTracker "github.com/ROCm/container-toolkit/internal/gpu-tracker"
+	"gopkg.in/yaml.v3"
+)
+
+// Constants
+const (
+	// Human readable table, the default format
+	FORMAT_TABLE = "table"
+
+	// Table with additional columns
+	FORMAT_WIDE = "wide"
+
+	// JSON document
+	FORMAT_JSON = "json"
+
+	// YAML document
+	FORMAT_YAML = "yaml"
+
+	// Version of the schema of the JSON and YAML documents. Fields may be
+	// added within a version, but never renamed or removed.
+	API_VERSION = "v1"
+)
+
+// GPUList is the document of amd-ctk gpu list
+type GPUList struct {
+	APIVersion string           `json:"apiVersion" yaml:"apiVersion"`
+	GPUs       []amdgpu.GPUInfo `json:"gpus" yaml:"gpus"`
+}
+
+// CDIDevice is a CDI device with the device nodes it injects
+type CDIDevice struct {
+	// Name is the fully qualified CDI device name, e.g. amd.com/gpu=0
+	Name string `json:"name" yaml:"name"`
+
+	// DeviceNodes are the paths of the device nodes of the device
+	DeviceNodes []string `json:"deviceNodes" yaml:"deviceNodes"`
+}
+
+// CDIDeviceList is the document of amd-ctk cdi list
+type CDIDeviceList struct {
+	APIVersion string      `json:"apiVersion" yaml:"apiVersion"`
+	Devices    []CDIDevice `json:"devices" yaml:"devices"`
+}
+
+// GPUTrackerStatus is the document of amd-ctk gpu-tracker status
+type GPUTrackerStatus struct {
+	APIVersion string                      `json:"apiVersion" yaml:"apiVersion"`
+	Enabled    bool                        `json:"enabled" yaml:"enabled"`
+	GPUs       []gpuTracker.GPUStatusEntry `json:"gpus" yaml:"gpus"`
+	Queue      []gpuTracker.QueueEntry     `json:"queue" yaml:"queue"`
+}
+
+// EngineStatus is the integration of the AMD runtime with a container
+// engine, as read from the configuration file of the engine
+type EngineStatus struct {
+	// Name is the name of the engine, e.g. docker
+	Name string `json:"name" yaml:"name"`
+
+	// ConfigPath is the configuration file of the engine
+	ConfigPath string `json:"configPath" yaml:"configPath"`
+
+	// ConfigFound is false when the configuration file does not exist
+	ConfigFound bool `json:"configFound" yaml:"configFound"`
+
+	// Registered is true when the amd runtime is in the configuration
+	Registered bool `json:"registered" yaml:"registered"`
+
+	// Default is true when amd is the default runtime of the engine
+	Default bool `json:"default" yaml:"default"`
+
+	// CDIEnabled is true when the engine resolves CDI devices
+	CDIEnabled bool `json:"cdiEnabled" yaml:"cdiEnabled"`
+
+	// RuntimePath is the executable of the amd runtime as configured
+	RuntimePath string `json:"runtimePath" yaml:"runtimePath"`
+
+	// ResolvedRuntimePath is the absolute path of RuntimePath, empty if
+	// it is not found
+	ResolvedRuntimePath string `json:"resolvedRuntimePath" yaml:"resolvedRuntimePath"`
+}
+
+// CDISpecStatus is the state of the CDI spec used by the runtime
+type CDISpecStatus struct {
+	Path    string `json:"path" yaml:"path"`
+	Present bool   `json:"present" yaml:"present"`
+
+	// Valid is true when the spec matches the GPUs of the host
+	Valid bool `json:"valid" yaml:"valid"`
+}
+
+// RuntimeStatus is the document of amd-ctk runtime status
+type RuntimeStatus struct {
+	APIVersion string         `json:"apiVersion" yaml:"apiVersion"`
+	Engines    []EngineStatus `json:"engines" yaml:"engines"`
+
+	// LowLevelRuntime is the runtime the containers are handed off to,
+	// empty if none is found
+	LowLevelRuntime string `json:"lowLevelRuntime" yaml:"lowLevelRuntime"`
+
+	// HookPath is the absolute path of amd-container-runtime-hook, empty
+	// if it is not found
+	HookPath string `json:"hookPath" yaml:"hookPath"`
+
+	CDISpec           CDISpecStatus `json:"cdiSpec" yaml:"cdiSpec"`
+	GPUTrackerEnabled bool          `json:"gpuTrackerEnabled" yaml:"gpuTrackerEnabled"`
+
+	// Problems lists what prevents containers from using AMD GPUs
+	Problems []string `json:"problems" yaml:"problems"`
+}
+
+// Formats returns the supported output formats
+func Formats() []string {
+	return []string{FORMAT_TABLE, FORMAT_WIDE, FORMAT_JSON, FORMAT_YAML}
+}
+
+// ValidateFormat checks that format is a supported output format
+func ValidateFormat(format string) error {
+	if !slices.Contains(Formats(), format) {
+		return fmt.Errorf("unsupported output format %q, must be one of %s", format, strings.Join(Formats(), ", "))
+	}
+	return nil
+}
+
+// Write writes doc to w as a JSON or YAML document
+func Write(w io.Writer, format string, doc interface{}) error {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(doc); err != nil {
+			return fmt.Errorf("encoding JSON output: %w", err)
+		}
+	case FORMAT_YAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return fmt.Errorf("encoding YAML output: %w", err)
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("%q is not a document format", format)
+	}
+
+	return nil
+}
+
+// NewCDIDeviceList returns the CDI devices generated for the given GPUs,
+// in the same order as in the CDI spec
+func NewCDIDeviceList(gpus []amdgpu.DeviceInfo) CDIDeviceList {
+	doc := CDIDeviceList{
+		APIVersion: API_VERSION,
+		Devices:    []CDIDevice{},
+	}
+	if len(gpus) == 0 {
+		return doc
+	}
+
+	all := CDIDevice{
+		Name:        cdi.CDI_KIND + "=all",
+		DeviceNodes: []string{},
+	}
+	for _, gpu := range gpus {
+		all.DeviceNodes = append(all.DeviceNodes, gpu.DrmDevices...)
+	}
+	all.DeviceNodes = append(all.DeviceNodes, "/dev/kfd")
+	doc.Devices = append(doc.Devices, all)
+
+	for i, gpu := range gpus {
+		doc.Devices = append(doc.Devices, CDIDevice{
+			Name:        fmt.Sprintf("%s=%d", cdi.CDI_KIND, i),
+			DeviceNodes: append(slices.Clone(gpu.DrmDevices), "/dev/kfd"),
+		})
+	}
+
+	return doc
+}
+
+// PrintGPUList writes the GPU inventory in the given format
+func PrintGPUList(w io.Writer, format string, gpus []amdgpu.GPUInfo) error {
+	if format == FORMAT_JSON || format == FORMAT_YAML {
+		if gpus == nil {
+			gpus = []amdgpu.GPUInfo{}
+		}
+		return Write(w, format, GPUList{APIVersion: API_VERSION, GPUs: gpus})
+	}
+
+	suffix := "devices"
+	if len(gpus) == 1 {
+		suffix = "device"
+	}
+	fmt.Fprintf(w, "Found %v AMD GPU %s\n", len(gpus), suffix)
+
+	if format == FORMAT_WIDE {
+		fmt.Fprintln(w, strings.Repeat("-", 135))
+		fmt.Fprintf(w, "%-10s%-25s%-16s%-10s%-8s%-14s%-12s%-40s\n", "GPU Id", "UUID", "PCI Bus Id", "GFX", "NUMA", "Partition", "VRAM", "DRM Devices")
+		fmt.Fprintln(w, strings.Repeat("-", 135))
+	} else {
+		fmt.Fprintln(w, strings.Repeat("-", 75))
+		fmt.Fprintf(w, "%-10s%-25s%-40s\n", "GPU Id", "UUID", "DRM Devices")
+		fmt.Fprintln(w, strings.Repeat("-", 75))
+	}
+	for _, gpu := range gpus {
+		uuid := "N/A"
+		if gpu.UniqueID != "" {
+			uuid = "0x" + strings.ToUpper(strings.TrimPrefix(gpu.UniqueID, "0x"))
+		}
+		drmStr := strings.Join(renderDevices(gpu.DrmDevices), ", ")
+
+		if format != FORMAT_WIDE {
+			fmt.Fprintf(w, "%-10v%-25s%-40s\n", gpu.Index, uuid, drmStr)
+			continue
+		}
+
+		partition := "-"
+		if gpu.ComputePartition != "" || gpu.MemoryPartition != "" {
+			partition = gpu.ComputePartition + "/" + gpu.MemoryPartition
+		}
+		fmt.Fprintf(w, "%-10v%-25s%-16s%-10s%-8s%-14s%-12s%-40s\n", gpu.Index, uuid, orNA(gpu.PCIBusID),
+			orNA(gpu.GfxTargetVersion), numaString(gpu.NUMANode), partition, vramString(gpu.VRAMSize), drmStr)
+	}
+
+	return nil
+}
+
+// PrintCDIDevices writes the CDI devices in the given format
+func PrintCDIDevices(w io.Writer, format string, doc CDIDeviceList) error {
+	if format == FORMAT_JSON || format == FORMAT_YAML {
+		return Write(w, format, doc)
+	}
+
+	gpus := len(doc.Devices) - 1
+	if gpus < 0 {
+		gpus = 0
+	}
+	suffix := "devices"
+	if gpus == 1 {
+		suffix = "device"
+	}
+	fmt.Fprintf(w, "Found %v AMD GPU %s\n", gpus, suffix)
+	for idx, dev := range doc.Devices {
+		fmt.Fprintf(w, "%s\n", dev.Name)
+		// The nodes of amd.com/gpu=all are only shown in the wide format
+		if idx == 0 && format != FORMAT_WIDE {
+			continue
+		}
+		nodes := dev.DeviceNodes
+		if format != FORMAT_WIDE {
+			nodes = renderDevices(nodes)
+		}
+		for _, n := range nodes {
+			fmt.Fprintf(w, "  %s\n", n)
+		}
+	}
+
+	return nil
+}
+
+// PrintGPUTrackerStatus writes the GPU Tracker status in the given format
+func PrintGPUTrackerStatus(w io.Writer, format string, enabled bool, entries []gpuTracker.GPUStatusEntry, queue []gpuTracker.QueueEntry) error {
+	if format == FORMAT_JSON || format == FORMAT_YAML {
+		doc := GPUTrackerStatus{
+			APIVersion: API_VERSION,
+			Enabled:    enabled,
+			GPUs:       []gpuTracker.GPUStatusEntry{},
+			Queue:      []gpuTracker.QueueEntry{},
+		}
+		for _, e := range entries {
+			if e.ContainerIds == nil {
+				e.ContainerIds = []string{}
+			}
+			if e.Leases == nil {
+				e.Leases = []gpuTracker.LeaseEntry{}
+			}
+			doc.GPUs = append(doc.GPUs, e)
+		}
+		for _, e := range queue {
+			if e.GPUs == nil {
+				e.GPUs = []int{}
+			}
+			doc.Queue = append(doc.Queue, e)
+		}
+		return Write(w, format, doc)
+	}
+
+	if !enabled {
+		fmt.Fprintln(w, "GPU Tracker is disabled")
+		return nil
+	}
+
+	fmt.Fprintln(w, strings.Repeat("-", 140))
+	fmt.Fprintf(w, "%-10s%-25s%-20s%-65s%-20s\n", "GPU Id", "UUID", "Accessibility", "Container Ids", "Age")
+	fmt.Fprintln(w, strings.Repeat("-", 140))
+	for _, entry := range entries {
+		if len(entry.ContainerIds) > 0 {
+			for idx, id := range entry.ContainerIds {
+				if idx == 0 {
+					fmt.Fprintf(w, "%-10v%-25v%-20v%-65v%-20v\n", entry.GPUId, entry.UUID, entry.Accessibility, id, leaseAge(entry, idx))
+				} else {
+					fmt.Fprintf(w, "%-10v%-25v%-20v%-65v%-20v\n", "", "", "", id, leaseAge(entry, idx))
+				}
+			}
+		} else {
+			fmt.Fprintf(w, "%-10v%-25v%-20v%-65v%-20v\n", entry.GPUId, entry.UUID, entry.Accessibility, "-", "-")
+		}
+	}
+
+	if len(queue) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, strings.Repeat("-", 140))
+		fmt.Fprintf(w, "%-10s%-65s%-25s%-20s%-20s\n", "Position", "Waiting Container Ids", "GPU Ids", "Waiting", "Remaining")
+		fmt.Fprintln(w, strings.Repeat("-", 140))
+		for _, entry := range queue {
+			gpuIds := make([]string, len(entry.GPUs))
+			for i, gpuId := range entry.GPUs {
+				gpuIds[i] = strconv.Itoa(gpuId)
+			}
+			fmt.Fprintf(w, "%-10v%-65v%-25v%-20v%-20v\n", entry.Position, entry.ContainerId, strings.Join(gpuIds, ","),
+				time.Duration(entry.WaitingSeconds)*time.Second, time.Duration(entry.RemainingSeconds)*time.Second)
+		}
+	}
+
+	return nil
+}
+
+// leaseAge returns the age of the lease of the idx-th container of a GPU,
+// "-" when it is unknown
+func leaseAge(entry gpuTracker.GPUStatusEntry, idx int) string {
+	if idx >= len(entry.Leases) || entry.Leases[idx].AgeSeconds < 0 {
+		return "-"
+	}
+	lease := entry.Leases[idx]
+	age := (time.Duration(lease.AgeSeconds) * time.Second).String()
+	if lease.Expired {
+		age += " (expired)"
+	}
+	return age
+}
+
+// PrintRuntimeStatus writes the status of the AMD runtime integration in
+// the given format
+func PrintRuntimeStatus(w io.Writer, format string, doc RuntimeStatus) error {
+	if format == FORMAT_JSON || format == FORMAT_YAML {
+		doc.APIVersion = API_VERSION
+		if doc.Engines == nil {
+			doc.Engines = []EngineStatus{}
+		}
+		if doc.Problems == nil {
+			doc.Problems = []string{}
+		}
+		return Write(w, format, doc)
+	}
+
+	fmt.Fprintln(w, strings.Repeat("-", 130))
+	fmt.Fprintf(w, "%-12s%-60s%-12s%-9s%-6s%-31s\n", "Engine", "Config", "Registered", "Default", "CDI", "Runtime Path")
+	fmt.Fprintln(w, strings.Repeat("-", 130))
+	for _, e := range doc.Engines {
+		if !e.ConfigFound {
+			fmt.Fprintf(w, "%-12s%-60s%-12s%-9s%-6s%-31s\n", e.Name, e.ConfigPath+" (not found)", "-", "-", "-", "-")
+			continue
+		}
+		runtimePath := "-"
+		if e.Registered {
+			runtimePath = orNA(e.ResolvedRuntimePath)
+		}
+		fmt.Fprintf(w, "%-12s%-60s%-12s%-9s%-6s%-31s\n", e.Name, e.ConfigPath, yesNo(e.Registered), yesNo(e.Default),
+			yesNo(e.CDIEnabled), runtimePath)
+	}
+	fmt.Fprintln(w, strings.Repeat("-", 130))
+
+	spec := "not found"
+	if doc.CDISpec.Present && doc.CDISpec.Valid {
+		spec = "valid"
+	} else if doc.CDISpec.Present {
+		spec = "invalid"
+	}
+	tracker := "disabled"
+	if doc.GPUTrackerEnabled {
+		tracker = "enabled"
+	}
+	fmt.Fprintf(w, "Low-level runtime: %s\n", orNA(doc.LowLevelRuntime))
+	fmt.Fprintf(w, "Runtime hook: %s\n", orNA(doc.HookPath))
+	fmt.Fprintf(w, "CDI spec: %s (%s)\n", doc.CDISpec.Path, spec)
+	fmt.Fprintf(w, "GPU Tracker: %s\n", tracker)
+
+	if len(doc.Problems) == 0 {
+		fmt.Fprintln(w, "No problems found")
+		return nil
+	}
+	fmt.Fprintln(w, "Problems:")
+	for _, p := range doc.Problems {
+		fmt.Fprintf(w, "  - %s\n", p)
+	}
+	return nil
+}
+
+// renderDevices returns the DRM render nodes of the device nodes
+func renderDevices(nodes []string) []string {
+	ret := []string{}
+	for _, n := range nodes {
+		if strings.HasPrefix(n, "/dev/dri/renderD") {
+			ret = append(ret, n)
+		}
+	}
+	return ret
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func orNA(s string) string {
+	if s == "" {
+		return "N/A"
+	}
+	return s
+}
+
+func numaString(node int) string {
+	if node < 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%d", node)
+}
+
+func vramString(size uint64) string {
+	if size == 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%d MiB", size>>20)
+}
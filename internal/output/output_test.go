@@ -0,0 +1,183 @@
+package output
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ROCm/container-toolkit/internal/amdgpu"
+	gpuTracker "github.com/ROCm/container-toolkit/internal/gpu-tracker"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+var testGPUs = []amdgpu.GPUInfo{
+	{
+		Index:             0,
+		DrmDevices:        []string{"/dev/dri/card1", "/dev/dri/renderD128"},
+		RenderMinor:       128,
+		PCIBusID:          "0000:05:00.0",
+		VendorID:          "0x1002",
+		DeviceID:          "0x74a1",
+		SubsystemVendorID: "0x1002",
+		SubsystemDeviceID: "0x74a1",
+		MarketingName:     "AMD Instinct MI300X",
+		GfxTargetVersion:  "gfx942",
+		UniqueID:          "0x1234567890abcdef",
+		KFDNodeID:         1,
+		NUMANode:          0,
+		LocalCPUList:      "0-47,96-143",
+		VRAMSize:          206141652992,
+		ComputePartition:  "spx",
+		MemoryPartition:   "nps1",
+		IOLinks:           []amdgpu.IOLink{{NodeTo: 0, Type: "pcie", Weight: 20}},
+	},
+	{
+		Index:             1,
+		DrmDevices:        []string{"/dev/dri/card2", "/dev/dri/renderD129"},
+		RenderMinor:       129,
+		PCIBusID:          "0000:85:00.0",
+		VendorID:          "0x1002",
+		DeviceID:          "0x74a1",
+		SubsystemVendorID: "0x1002",
+		SubsystemDeviceID: "0x74a1",
+		GfxTargetVersion:  "gfx942",
+		UniqueID:          "0x89ad28434ab2622f",
+		KFDNodeID:         2,
+		NUMANode:          -1,
+		VRAMSize:          25769803776,
+		ComputePartition:  "cpx",
+		MemoryPartition:   "nps1",
+		HiveID:            "0x4252874d42efcdfd",
+		Parent:            "0000:85:00.0",
+		PartitionID:       0,
+		IOLinks:           []amdgpu.IOLink{{NodeTo: 0, Type: "pcie", Weight: 20}, {NodeTo: 3, Type: "xgmi", Weight: 15}},
+	},
+}
+
+var testStatus = []gpuTracker.GPUStatusEntry{
+	{GPUId: 0, UUID: "0x1234567890abcdef", Accessibility: gpuTracker.SHARED_ACCESS, ContainerIds: []string{"c1", "c2"},
+		Leases: []gpuTracker.LeaseEntry{
+			{ContainerId: "c1", CreatedAt: "2025-06-01T10:00:00Z", AgeSeconds: 5400, Pid: 4242, Bundle: "/run/containerd/c1", TTLSeconds: 3600, Expired: true},
+			{ContainerId: "c2", AgeSeconds: -1},
+		}},
+	{GPUId: 1, UUID: "0x89ad28434ab2622f", Accessibility: gpuTracker.EXCLUSIVE_ACCESS, MaxContainers: 1},
+	{GPUId: 2, UUID: "0x5f1e9d4c3b2a1908", Accessibility: gpuTracker.SharedLimitedAccess(4), MaxContainers: 4, ContainerIds: []string{"c3"},
+		Leases: []gpuTracker.LeaseEntry{
+			{ContainerId: "c3", CreatedAt: "2025-06-01T11:28:00Z", AgeSeconds: 120, Pid: 4343, Bundle: "/run/containerd/c3"},
+		}},
+}
+
+var testQueue = []gpuTracker.QueueEntry{
+	{Position: 1, ContainerId: "c4", GPUs: []int{1}, Pid: 4444, WaitingSeconds: 45, RemainingSeconds: 255},
+	{Position: 2, ContainerId: "c5", GPUs: []int{1, 2}, Pid: 4545, WaitingSeconds: 10, RemainingSeconds: 50},
+}
+
+// checkGolden compares the output with the golden file of the test
+func checkGolden(t *testing.T, name string, out []byte) {
+	golden := filepath.Join("../../tests/output", name+".golden")
+	if *update {
+		if err := os.WriteFile(golden, out, 0644); err != nil {
+			t.Fatalf("updating %s: %v", golden, err)
+		}
+	}
+
+	expected, err := os.ReadFile(golden)
+	Assert(t, err == nil, fmt.Sprintf("reading %s: %v", golden, err))
+	Assert(t, bytes.Equal(expected, out), fmt.Sprintf("%s: expected:\n%s\ngot:\n%s", name, expected, out))
+}
+
+func TestPrintGPUList(t *testing.T) {
+	for _, format := range Formats() {
+		var buf bytes.Buffer
+		err := PrintGPUList(&buf, format, testGPUs)
+		Assert(t, err == nil, fmt.Sprintf("%s: unexpected error: %v", format, err))
+		checkGolden(t, "gpu-list."+format, buf.Bytes())
+	}
+}
+
+func TestPrintCDIDevices(t *testing.T) {
+	devs := []amdgpu.DeviceInfo{}
+	for _, gpu := range testGPUs {
+		devs = append(devs, amdgpu.DeviceInfo{DrmDevices: gpu.DrmDevices})
+	}
+
+	for _, format := range Formats() {
+		var buf bytes.Buffer
+		err := PrintCDIDevices(&buf, format, NewCDIDeviceList(devs))
+		Assert(t, err == nil, fmt.Sprintf("%s: unexpected error: %v", format, err))
+		checkGolden(t, "cdi-list."+format, buf.Bytes())
+	}
+}
+
+func TestPrintGPUTrackerStatus(t *testing.T) {
+	for _, format := range Formats() {
+		var buf bytes.Buffer
+		err := PrintGPUTrackerStatus(&buf, format, true, testStatus, testQueue)
+		Assert(t, err == nil, fmt.Sprintf("%s: unexpected error: %v", format, err))
+		checkGolden(t, "gpu-tracker-status."+format, buf.Bytes())
+	}
+
+	for _, format := range []string{FORMAT_TABLE, FORMAT_JSON} {
+		var buf bytes.Buffer
+		err := PrintGPUTrackerStatus(&buf, format, false, nil, nil)
+		Assert(t, err == nil, fmt.Sprintf("%s: unexpected error: %v", format, err))
+		checkGolden(t, "gpu-tracker-status-disabled."+format, buf.Bytes())
+	}
+}
+
+var testRuntimeStatus = RuntimeStatus{
+	Engines: []EngineStatus{
+		{Name: "docker", ConfigPath: "/etc/docker/daemon.json", ConfigFound: true, Registered: true, Default: true,
+			CDIEnabled: true, RuntimePath: "amd-container-runtime", ResolvedRuntimePath: "/usr/bin/amd-container-runtime"},
+		{Name: "containerd", ConfigPath: "/etc/containerd/config.toml", ConfigFound: true, RuntimePath: ""},
+		{Name: "crio", ConfigPath: "/etc/crio/crio.conf.d/99-amd.toml"},
+	},
+	LowLevelRuntime: "/usr/bin/runc",
+	HookPath:        "/usr/bin/amd-container-runtime-hook",
+	CDISpec:         CDISpecStatus{Path: "/etc/cdi/amd.json", Present: true},
+	Problems:        []string{"CDI spec /etc/cdi/amd.json does not match the GPUs of the host"},
+}
+
+func TestPrintRuntimeStatus(t *testing.T) {
+	for _, format := range Formats() {
+		var buf bytes.Buffer
+		err := PrintRuntimeStatus(&buf, format, testRuntimeStatus)
+		Assert(t, err == nil, fmt.Sprintf("%s: unexpected error: %v", format, err))
+		checkGolden(t, "runtime-status."+format, buf.Bytes())
+	}
+
+	var buf bytes.Buffer
+	err := PrintRuntimeStatus(&buf, FORMAT_TABLE, RuntimeStatus{CDISpec: CDISpecStatus{Path: "/etc/cdi/amd.json"}})
+	Assert(t, err == nil, fmt.Sprintf("unexpected error: %v", err))
+	checkGolden(t, "runtime-status-healthy.table", buf.Bytes())
+}
+
+func TestEmptyDocuments(t *testing.T) {
+	var buf bytes.Buffer
+	err := PrintGPUList(&buf, FORMAT_JSON, nil)
+	Assert(t, err == nil, fmt.Sprintf("unexpected error: %v", err))
+	Assert(t, buf.String() == "{\n  \"apiVersion\": \"v1\",\n  \"gpus\": []\n}\n", fmt.Sprintf("unexpected output %q", buf.String()))
+
+	buf.Reset()
+	err = PrintCDIDevices(&buf, FORMAT_JSON, NewCDIDeviceList(nil))
+	Assert(t, err == nil, fmt.Sprintf("unexpected error: %v", err))
+	Assert(t, buf.String() == "{\n  \"apiVersion\": \"v1\",\n  \"devices\": []\n}\n", fmt.Sprintf("unexpected output %q", buf.String()))
+}
+
+func TestValidateFormat(t *testing.T) {
+	for _, format := range Formats() {
+		Assert(t, ValidateFormat(format) == nil, fmt.Sprintf("%s should be valid", format))
+	}
+	Assert(t, ValidateFormat("xml") != nil, "xml should be invalid")
+	Assert(t, Write(&bytes.Buffer{}, FORMAT_TABLE, GPUList{}) != nil, "table is not a document format")
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
@@ -0,0 +1,264 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rocm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Constants
+const (
+	// Environment variable of the container selecting the ROCm user-space
+	// components mounted into it
+	DRIVER_CAPABILITIES_ENV = "AMD_DRIVER_CAPABILITIES"
+
+	// Capability for the HIP and HSA runtime libraries
+	CAPABILITY_COMPUTE = "compute"
+
+	// Capability for the management libraries and tools
+	CAPABILITY_UTILITY = "utility"
+
+	// Capability for all the ROCm user-space components
+	CAPABILITY_ALL = "all"
+)
+
+// Capabilities are the ROCm user-space components requested for a container
+type Capabilities struct {
+	Compute bool
+	Utility bool
+}
+
+// computeLibs are the library name prefixes mounted for the compute capability
+var computeLibs = []string{"libamdhip64", "libhsa-runtime64", "libamd_comgr", "librocprofiler-register"}
+
+// utilityLibs are the library name prefixes mounted for the utility capability
+var utilityLibs = []string{"librocm_smi64", "libamd_smi", "libhsa-runtime64"}
+
+// utilityTools are the executables mounted for the utility capability
+var utilityTools = []string{"rocminfo", "amd-smi"}
+
+// Files are the ROCm user-space files discovered on the host
+type Files struct {
+	// Libraries are the paths of the shared libraries, including their
+	// versioned names
+	Libraries []string
+
+	// Tools are the paths of the executables
+	Tools []string
+}
+
+// Interface for ROCm package
+type Interface interface {
+	// Discover returns the host files of the requested capabilities
+	Discover(caps Capabilities) (*Files, error)
+}
+
+// rocm_t implements the ROCm interface
+type rocm_t struct {
+	// root is prepended to every host path looked up
+	root string
+
+	// rocmPath is the ROCm installation directory
+	rocmPath string
+}
+
+// IsEmpty returns true if no capability is requested
+func (caps Capabilities) IsEmpty() bool {
+	return !caps.Compute && !caps.Utility
+}
+
+// String returns the capabilities as a comma separated list
+func (caps Capabilities) String() string {
+	ret := []string{}
+	if caps.Compute {
+		ret = append(ret, CAPABILITY_COMPUTE)
+	}
+	if caps.Utility {
+		ret = append(ret, CAPABILITY_UTILITY)
+	}
+	return strings.Join(ret, ",")
+}
+
+// ParseCapabilities parses a comma separated list of capabilities
+func ParseCapabilities(value string) (Capabilities, error) {
+	caps := Capabilities{}
+	for _, c := range strings.Split(value, ",") {
+		switch strings.TrimSpace(c) {
+		case "":
+		case CAPABILITY_COMPUTE:
+			caps.Compute = true
+		case CAPABILITY_UTILITY:
+			caps.Utility = true
+		case CAPABILITY_ALL:
+			caps.Compute = true
+			caps.Utility = true
+		default:
+			return Capabilities{}, fmt.Errorf("unsupported driver capability %q", c)
+		}
+	}
+
+	return caps, nil
+}
+
+// GetCapabilities returns the capabilities requested in the environment
+// of a container
+func GetCapabilities(env []string) (Capabilities, error) {
+	caps := Capabilities{}
+	for _, e := range env {
+		pts := strings.SplitN(e, "=", 2)
+		if len(pts) == 2 && pts[0] == DRIVER_CAPABILITIES_ENV {
+			var err error
+			caps, err = ParseCapabilities(pts[1])
+			if err != nil {
+				return Capabilities{}, err
+			}
+		}
+	}
+
+	return caps, nil
+}
+
+// LibDirs returns the directories of the libraries
+func (f *Files) LibDirs() []string {
+	return dirs(f.Libraries)
+}
+
+// ToolDirs returns the directories of the tools
+func (f *Files) ToolDirs() []string {
+	return dirs(f.Tools)
+}
+
+// All returns the paths of all the files
+func (f *Files) All() []string {
+	return append(slices.Clone(f.Libraries), f.Tools...)
+}
+
+func dirs(paths []string) []string {
+	ret := []string{}
+	for _, p := range paths {
+		if d := filepath.Dir(p); !slices.Contains(ret, d) {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+// findLibs returns the paths of the libraries with the given name prefixes
+func (r *rocm_t) findLibs(names []string) ([]string, error) {
+	ret := []string{}
+	for _, dir := range []string{"lib", "lib64"} {
+		for _, name := range names {
+			pattern := filepath.Join(r.root, r.rocmPath, dir, name+".so*")
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("searching %s: %w", pattern, err)
+			}
+			for _, m := range matches {
+				p := "/" + strings.TrimPrefix(m, filepath.Clean(r.root))
+				p = filepath.Clean(p)
+				if !slices.Contains(ret, p) {
+					ret = append(ret, p)
+				}
+			}
+		}
+	}
+
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// findTools returns the paths of the given executables
+func (r *rocm_t) findTools(names []string) []string {
+	ret := []string{}
+	for _, name := range names {
+		p := filepath.Join(r.rocmPath, "bin", name)
+		if info, err := os.Stat(filepath.Join(r.root, p)); err == nil && !info.IsDir() {
+			ret = append(ret, p)
+		}
+	}
+
+	return ret
+}
+
+func (r *rocm_t) Discover(caps Capabilities) (*Files, error) {
+	files := &Files{
+		Libraries: []string{},
+		Tools:     []string{},
+	}
+
+	names := []string{}
+	if caps.Compute {
+		names = append(names, computeLibs...)
+	}
+	if caps.Utility {
+		names = append(names, utilityLibs...)
+		files.Tools = r.findTools(utilityTools)
+	}
+
+	libs, err := r.findLibs(names)
+	if err != nil {
+		return nil, err
+	}
+	files.Libraries = libs
+
+	return files, nil
+}
+
+// New creates a ROCm instance discovering the files of the ROCm
+// installation at rocmPath on the host
+func New(rocmPath string) Interface {
+	return NewWithRoot("/", rocmPath)
+}
+
+// NewWithRoot creates a ROCm instance looking up the host files under root.
+// This allows the discovery to be tested against a fake directory tree.
+func NewWithRoot(root string, rocmPath string) Interface {
+	return &rocm_t{
+		root:     root,
+		rocmPath: rocmPath,
+	}
+}
+
+// AppendPathEnv appends the directories missing in the colon separated
+// list of the environment variable name, adding the variable if needed
+func AppendPathEnv(env []string, name string, dirs []string) []string {
+	ret := slices.Clone(env)
+	idx := slices.IndexFunc(ret, func(e string) bool { return strings.HasPrefix(e, name+"=") })
+
+	cur := []string{}
+	if idx >= 0 && ret[idx] != name+"=" {
+		cur = strings.Split(strings.TrimPrefix(ret[idx], name+"="), ":")
+	}
+	for _, d := range dirs {
+		if !slices.Contains(cur, d) {
+			cur = append(cur, d)
+		}
+	}
+
+	if idx >= 0 {
+		ret[idx] = name + "=" + strings.Join(cur, ":")
+	} else if len(cur) > 0 {
+		ret = append(ret, name+"="+strings.Join(cur, ":"))
+	}
+
+	return ret
+}
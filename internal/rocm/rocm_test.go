@@ -0,0 +1,92 @@
+package rocm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// setupROCm creates a fake ROCm installation with the given files under a
+// temporary root
+func setupROCm(t *testing.T, files []string) string {
+	root := t.TempDir()
+	for _, f := range files {
+		p := filepath.Join(root, f)
+		err := os.MkdirAll(filepath.Dir(p), 0755)
+		Assert(t, err == nil, fmt.Sprintf("failed to create directory for %v, Err: %v", f, err))
+		err = os.WriteFile(p, []byte{}, 0755)
+		Assert(t, err == nil, fmt.Sprintf("failed to create %v, Err: %v", f, err))
+	}
+	return root
+}
+
+func TestParseCapabilities(t *testing.T) {
+	caps, err := ParseCapabilities("compute")
+	Assert(t, err == nil && caps.Compute && !caps.Utility, fmt.Sprintf("unexpected capabilities %+v, Err: %v", caps, err))
+
+	caps, err = ParseCapabilities("all")
+	Assert(t, err == nil && caps.String() == "compute,utility", fmt.Sprintf("unexpected capabilities %+v, Err: %v", caps, err))
+
+	caps, err = ParseCapabilities("")
+	Assert(t, err == nil && caps.IsEmpty(), fmt.Sprintf("unexpected capabilities %+v, Err: %v", caps, err))
+
+	_, err = ParseCapabilities("compute,graphics")
+	Assert(t, err != nil, "ParseCapabilities() did not return error for unsupported capability")
+
+	caps, err = GetCapabilities([]string{"PATH=/usr/bin", "AMD_DRIVER_CAPABILITIES=utility"})
+	Assert(t, err == nil && caps.Utility && !caps.Compute, fmt.Sprintf("unexpected capabilities %+v, Err: %v", caps, err))
+}
+
+func TestDiscover(t *testing.T) {
+	root := setupROCm(t, []string{
+		"/opt/rocm/lib/libamdhip64.so",
+		"/opt/rocm/lib/libamdhip64.so.6",
+		"/opt/rocm/lib/libhsa-runtime64.so.1",
+		"/opt/rocm/lib/librocm_smi64.so.7",
+		"/opt/rocm/lib/libunrelated.so",
+		"/opt/rocm/bin/rocminfo",
+	})
+	r := NewWithRoot(root, "/opt/rocm")
+
+	files, err := r.Discover(Capabilities{Compute: true})
+	Assert(t, err == nil, fmt.Sprintf("Discover() returned error %v", err))
+	expected := []string{
+		"/opt/rocm/lib/libamdhip64.so",
+		"/opt/rocm/lib/libamdhip64.so.6",
+		"/opt/rocm/lib/libhsa-runtime64.so.1",
+	}
+	Assert(t, slices.Equal(files.Libraries, expected), fmt.Sprintf("expected libraries %v, got %v", expected, files.Libraries))
+	Assert(t, len(files.Tools) == 0, fmt.Sprintf("expected no tools, got %v", files.Tools))
+	Assert(t, slices.Equal(files.LibDirs(), []string{"/opt/rocm/lib"}), fmt.Sprintf("unexpected library dirs %v", files.LibDirs()))
+
+	files, err = r.Discover(Capabilities{Utility: true})
+	Assert(t, err == nil, fmt.Sprintf("Discover() returned error %v", err))
+	expected = []string{
+		"/opt/rocm/lib/libhsa-runtime64.so.1",
+		"/opt/rocm/lib/librocm_smi64.so.7",
+	}
+	Assert(t, slices.Equal(files.Libraries, expected), fmt.Sprintf("expected libraries %v, got %v", expected, files.Libraries))
+	Assert(t, slices.Equal(files.Tools, []string{"/opt/rocm/bin/rocminfo"}), fmt.Sprintf("unexpected tools %v", files.Tools))
+
+	files, err = NewWithRoot(t.TempDir(), "/opt/rocm").Discover(Capabilities{Compute: true, Utility: true})
+	Assert(t, err == nil && len(files.All()) == 0, fmt.Sprintf("expected no files without ROCm, got %v, Err: %v", files, err))
+}
+
+func TestAppendPathEnv(t *testing.T) {
+	env := AppendPathEnv([]string{"LD_LIBRARY_PATH=/usr/lib:/opt/rocm/lib"}, "LD_LIBRARY_PATH", []string{"/opt/rocm/lib", "/opt/rocm/lib64"})
+	Assert(t, slices.Equal(env, []string{"LD_LIBRARY_PATH=/usr/lib:/opt/rocm/lib:/opt/rocm/lib64"}), fmt.Sprintf("unexpected env %v", env))
+
+	env = AppendPathEnv([]string{"HOME=/root"}, "LD_LIBRARY_PATH", []string{"/opt/rocm/lib"})
+	Assert(t, slices.Equal(env, []string{"HOME=/root", "LD_LIBRARY_PATH=/opt/rocm/lib"}), fmt.Sprintf("unexpected env %v", env))
+
+	env = AppendPathEnv([]string{"HOME=/root"}, "LD_LIBRARY_PATH", []string{})
+	Assert(t, slices.Equal(env, []string{"HOME=/root"}), fmt.Sprintf("unexpected env %v", env))
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
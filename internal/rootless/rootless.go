@@ -0,0 +1,150 @@
+/**
+# Copyright (c) Advanced Micro Devices, Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+**/
+
+package rootless
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Directory of the toolkit state of a rootless user, under the XDG
+	// state directory
+	STATE_DIR_NAME = "amd-container-toolkit"
+
+	// ID an unmapped host ID shows as in a user namespace, unless the
+	// kernel is configured otherwise
+	DEFAULT_OVERFLOW_ID = 65534
+)
+
+var (
+	// uidMapFile holds the UID mappings of the user namespace of the process
+	uidMapFile = "/proc/self/uid_map"
+
+	// overflowUIDFile and overflowGIDFile hold the IDs unmapped host IDs
+	// show as in a user namespace
+	overflowUIDFile = "/proc/sys/kernel/overflowuid"
+	overflowGIDFile = "/proc/sys/kernel/overflowgid"
+
+	// geteuid returns the effective UID of the process
+	geteuid = os.Geteuid
+)
+
+// IsRootless reports whether the process lacks the privileges of the host
+// root user, either because it runs as another user or because it runs in
+// a user namespace, as rootless podman and docker run the runtime
+func IsRootless() bool {
+	if geteuid() != 0 {
+		return true
+	}
+	return InUserNamespace()
+}
+
+// InUserNamespace reports whether the process runs in a user namespace
+// other than the initial one
+func InUserNamespace() bool {
+	data, err := os.ReadFile(uidMapFile)
+	if err != nil {
+		return false
+	}
+	return !isInitialMapping(string(data))
+}
+
+// isInitialMapping reports whether a uid_map file maps the whole ID range
+// onto itself, which only the initial user namespace does
+func isInitialMapping(uidMap string) bool {
+	fields := strings.Fields(uidMap)
+	return len(fields) == 3 && fields[0] == "0" && fields[1] == "0" && fields[2] == "4294967295"
+}
+
+// StateDir returns the directory of the toolkit state of a rootless user,
+// $XDG_STATE_HOME/amd-container-toolkit or, when XDG_STATE_HOME is not set,
+// ~/.local/state/amd-container-toolkit
+func StateDir() (string, error) {
+	if stateHome := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(stateHome) {
+		return filepath.Join(stateHome, STATE_DIR_NAME), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding the state directory: %w", err)
+	}
+	if !filepath.IsAbs(home) {
+		return "", fmt.Errorf("finding the state directory: home directory %q is not absolute", home)
+	}
+	return filepath.Join(home, ".local", "state", STATE_DIR_NAME), nil
+}
+
+// Env returns the environment variables StateDir depends on, for the hooks
+// the runtime adds to a container, which do not inherit its environment
+func Env() []string {
+	env := []string{}
+	for _, name := range []string{"HOME", "XDG_STATE_HOME"} {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			env = append(env, name+"="+v)
+		}
+	}
+	return env
+}
+
+// OverflowUID returns the UID an unmapped host UID shows as
+func OverflowUID() uint32 {
+	return readOverflowID(overflowUIDFile)
+}
+
+// OverflowGID returns the GID an unmapped host GID shows as
+func OverflowGID() uint32 {
+	return readOverflowID(overflowGIDFile)
+}
+
+func readOverflowID(path string) uint32 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return DEFAULT_OVERFLOW_ID
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return DEFAULT_OVERFLOW_ID
+	}
+	return uint32(id)
+}
+
+// MapID returns the ID in a container of an ID of the runtime, following
+// the ID mappings of the container, and false if the ID is not mapped. A
+// container without mappings shares the IDs of the runtime.
+func MapID(id uint32, mappings []specs.LinuxIDMapping) (uint32, bool) {
+	if len(mappings) == 0 {
+		return id, true
+	}
+	for _, m := range mappings {
+		if id >= m.HostID && uint64(id) < uint64(m.HostID)+uint64(m.Size) {
+			return m.ContainerID + (id - m.HostID), true
+		}
+	}
+	return 0, false
+}
+
+// CanAccess reports whether the process can read and write a device node
+func CanAccess(path string) bool {
+	return unix.Access(path, unix.R_OK|unix.W_OK) == nil
+}
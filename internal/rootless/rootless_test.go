@@ -0,0 +1,87 @@
+package rootless
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestIsRootless(t *testing.T) {
+	dir := t.TempDir()
+	origUIDMapFile, origGeteuid := uidMapFile, geteuid
+	defer func() { uidMapFile, geteuid = origUIDMapFile, origGeteuid }()
+
+	uidMapFile = filepath.Join(dir, "uid_map")
+	geteuid = func() int { return 0 }
+
+	Assert(t, os.WriteFile(uidMapFile, []byte("         0          0 4294967295\n"), 0644) == nil, "writing uid_map failed")
+	Assert(t, !IsRootless(), "root in the initial user namespace is not rootless")
+
+	Assert(t, os.WriteFile(uidMapFile, []byte("         0       1000          1\n         1     100000      65536\n"), 0644) == nil, "writing uid_map failed")
+	Assert(t, IsRootless(), "root in a user namespace is rootless")
+
+	geteuid = func() int { return 1000 }
+	Assert(t, os.WriteFile(uidMapFile, []byte("0 0 4294967295\n"), 0644) == nil, "writing uid_map failed")
+	Assert(t, IsRootless(), "a non-root user is rootless")
+}
+
+func TestStateDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/run/user/1000/state")
+	dir, err := StateDir()
+	Assert(t, err == nil && dir == "/run/user/1000/state/amd-container-toolkit", fmt.Sprintf("unexpected state dir %v, err %v", dir, err))
+
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("HOME", "/home/user")
+	dir, err = StateDir()
+	Assert(t, err == nil && dir == "/home/user/.local/state/amd-container-toolkit", fmt.Sprintf("unexpected state dir %v, err %v", dir, err))
+
+	t.Setenv("HOME", "")
+	_, err = StateDir()
+	Assert(t, err != nil, "StateDir should fail without a home directory")
+}
+
+func TestMapID(t *testing.T) {
+	mappings := []specs.LinuxIDMapping{
+		{ContainerID: 0, HostID: 1000, Size: 1},
+		{ContainerID: 1, HostID: 100000, Size: 65536},
+	}
+
+	tests := []struct {
+		id       uint32
+		expected uint32
+		ok       bool
+	}{
+		{1000, 0, true},
+		{100000, 1, true},
+		{100044, 45, true},
+		{44, 0, false},
+		{165536, 0, false},
+	}
+	for _, tc := range tests {
+		id, ok := MapID(tc.id, mappings)
+		Assert(t, id == tc.expected && ok == tc.ok, fmt.Sprintf("MapID(%v) = %v, %v, expected %v, %v", tc.id, id, ok, tc.expected, tc.ok))
+	}
+
+	id, ok := MapID(44, nil)
+	Assert(t, id == 44 && ok, "IDs are not changed without mappings")
+}
+
+func TestOverflowGID(t *testing.T) {
+	origOverflowGIDFile := overflowGIDFile
+	defer func() { overflowGIDFile = origOverflowGIDFile }()
+
+	overflowGIDFile = filepath.Join(t.TempDir(), "overflowgid")
+	Assert(t, OverflowGID() == DEFAULT_OVERFLOW_ID, "expected the default overflow GID without the file")
+
+	Assert(t, os.WriteFile(overflowGIDFile, []byte("65000\n"), 0644) == nil, "writing overflowgid failed")
+	Assert(t, OverflowGID() == 65000, fmt.Sprintf("unexpected overflow GID %v", OverflowGID()))
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}
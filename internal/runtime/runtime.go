@@ -21,17 +21,13 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"syscall"
 
+	"github.com/ROCm/container-toolkit/internal/config"
 	"github.com/ROCm/container-toolkit/internal/oci"
 )
 
-// Constants
-const (
-	// runc executable
-	RUNC = "runc"
-)
-
 // Interface for runtime package
 type Interface interface {
 	// Run starts the runtime
@@ -44,6 +40,59 @@ type runtm struct {
 	args []string
 	// oci is the handle for oci operations
 	oci oci.Interface
+	// lowLevelRuntime is the path of the runtime the container is handed off to
+	lowLevelRuntime string
+	// mode is how the GPUs are set up for the container, see config.RuntimeConfig
+	mode string
+}
+
+// isExecutable returns true if path is a regular file with an execute bit set
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
+
+// FindLowLevelRuntime returns the absolute path of the first candidate
+// runtime found on the system. Candidates with an absolute path are used
+// as is, others are looked up in PATH.
+func FindLowLevelRuntime(candidates []string) (string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		self = ""
+	}
+
+	for _, c := range candidates {
+		path := c
+		if !filepath.IsAbs(c) {
+			path, err = exec.LookPath(c)
+			if err != nil {
+				slog.Debug("Low-level runtime not found in PATH", "runtime", c)
+				continue
+			}
+			path, err = filepath.Abs(path)
+			if err != nil {
+				continue
+			}
+		}
+
+		if !isExecutable(path) {
+			slog.Debug("Low-level runtime is not executable", "runtime", path)
+			continue
+		}
+
+		// Never hand the container back to ourselves
+		if resolved, err := filepath.EvalSymlinks(path); err == nil && resolved == self {
+			slog.Warn("Skipping low-level runtime pointing to amd-container-runtime", "runtime", path)
+			continue
+		}
+
+		return path, nil
+	}
+
+	return "", fmt.Errorf("none of the low-level runtimes %v found", candidates)
 }
 
 // New creates a runtime instance
@@ -54,6 +103,18 @@ func New(args []string) (Interface, error) {
 		args: args,
 	}
 
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	rt.mode = cfg.Runtime.Mode
+
+	rt.lowLevelRuntime, err = FindLowLevelRuntime(cfg.Runtime.Runtimes)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Selected low-level runtime", "path", rt.lowLevelRuntime, "candidates", cfg.Runtime.Runtimes)
+
 	rt.oci, err = oci.New(rt.args[1:])
 	if err != nil {
 		return nil, fmt.Errorf("creating OCI handler: %w", err)
@@ -67,14 +128,23 @@ func (rt *runtm) Run() error {
 	var err error
 
 	if rt.oci.HasHelpOption() {
-		fmt.Printf("\nAMD Container Runtime is a wrapper over runc. Below is the help for runc.\n\n")
+		name := filepath.Base(rt.lowLevelRuntime)
+		fmt.Printf("\nAMD Container Runtime is a wrapper over %s. Below is the help for %s.\n\n", name, name)
 	}
 
 	if rt.oci.IsCreate() {
-		// Add GPUs
-		err = rt.oci.UpdateSpec(oci.AddGPUDevices)
-		if err != nil {
-			return fmt.Errorf("update OCI spec (add GPU devices): %w", err)
+		if rt.mode == config.RUNTIME_MODE_HOOK {
+			// Leave the GPU setup to the OCI hook
+			err = rt.oci.UpdateSpec(oci.AddHook)
+			if err != nil {
+				return fmt.Errorf("update OCI spec (add hook): %w", err)
+			}
+		} else {
+			// Add GPUs
+			err = rt.oci.UpdateSpec(oci.AddGPUDevices)
+			if err != nil {
+				return fmt.Errorf("update OCI spec (add GPU devices): %w", err)
+			}
 		}
 
 		/*
@@ -93,17 +163,12 @@ func (rt *runtm) Run() error {
 		slog.Info("Container configured for GPU access")
 	}
 
-	// Call runc with updated oci spec
-	runc, err := exec.LookPath(RUNC)
-	if err != nil {
-		return fmt.Errorf("unable to find runc in PATH: %w", err)
-	}
-
+	// Call the low-level runtime with updated oci spec
 	slog.Info("Launching container")
-	slog.Debug("Running runc", "args", rt.args, "environ", os.Environ())
-	err = syscall.Exec(runc, rt.args, os.Environ())
+	slog.Debug("Running low-level runtime", "runtime", rt.lowLevelRuntime, "args", rt.args, "environ", os.Environ())
+	err = syscall.Exec(rt.lowLevelRuntime, rt.args, os.Environ())
 	if err != nil {
-		return fmt.Errorf("calling runc: %w", err)
+		return fmt.Errorf("calling %s: %w", rt.lowLevelRuntime, err)
 	}
 
 	return nil
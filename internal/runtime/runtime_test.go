@@ -0,0 +1,56 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeExecutable(t *testing.T, dir, name string, mode os.FileMode) string {
+	f := filepath.Join(dir, name)
+	err := os.WriteFile(f, []byte("#!/bin/sh\n"), mode)
+	Assert(t, err == nil, fmt.Sprintf("failed to write %s, Err: %v", f, err))
+	return f
+}
+
+func TestFindLowLevelRuntime(t *testing.T) {
+	dir := t.TempDir()
+	crun := writeExecutable(t, dir, "crun", 0755)
+	notExec := writeExecutable(t, dir, "runsc", 0644)
+	t.Setenv("PATH", dir)
+
+	// Name looked up in PATH
+	path, err := FindLowLevelRuntime([]string{"runc", "crun"})
+	Assert(t, err == nil, fmt.Sprintf("FindLowLevelRuntime returned error %v", err))
+	Assert(t, path == crun, fmt.Sprintf("expected %v, got %v", crun, path))
+
+	// Absolute paths are checked for the execute bit
+	path, err = FindLowLevelRuntime([]string{notExec, filepath.Join(dir, "youki"), crun})
+	Assert(t, err == nil, fmt.Sprintf("FindLowLevelRuntime returned error %v", err))
+	Assert(t, path == crun, fmt.Sprintf("expected %v, got %v", crun, path))
+
+	// No candidate found
+	_, err = FindLowLevelRuntime([]string{"runc", notExec})
+	Assert(t, err != nil, "FindLowLevelRuntime did not return error when expected")
+}
+
+func TestFindLowLevelRuntimeSkipsSelf(t *testing.T) {
+	self, err := os.Executable()
+	Assert(t, err == nil, fmt.Sprintf("failed to get executable, Err: %v", err))
+
+	dir := t.TempDir()
+	link := filepath.Join(dir, "runc")
+	err = os.Symlink(self, link)
+	Assert(t, err == nil, fmt.Sprintf("failed to create symlink, Err: %v", err))
+	t.Setenv("PATH", dir)
+
+	_, err = FindLowLevelRuntime([]string{"runc"})
+	Assert(t, err != nil, "FindLowLevelRuntime did not skip itself")
+}
+
+func Assert(t *testing.T, b bool, errString string) {
+	if !b {
+		t.Errorf(errString)
+	}
+}